@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"smart-money/internal/analysis"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/response"
+)
+
+func GetAddressProfile(c *gin.Context) {
+	address := c.Param("address")
+	if address == "" {
+		response.BadRequest(c, errcode.AddressProfileParamsError, fmt.Errorf("address is empty"))
+		return
+	}
+
+	profile, err := analysis.BuildProfile(address)
+	if err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+	if profile.TxCount == 0 && len(profile.RoundTrips) == 0 {
+		response.NotFound(c, errcode.AddressProfileNotExistError, fmt.Errorf("address has no collected trade"))
+		return
+	}
+
+	response.OK(c, profile)
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"smart-money/internal/backtest"
+	"smart-money/internal/exitstrategy"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/response"
+	"smart-money/pkg/util"
+)
+
+type BacktestReq struct {
+	ChainName string   `json:"chain_name"`
+	Addresses []string `json:"addresses"`
+	// 日期格式 2006-01-02，不传表示不限制
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	EntryFilter    string  `json:"entry_filter"`
+	EntryValue     float64 `json:"entry_value"`
+	SizingStrategy string  `json:"sizing_strategy"`
+	SizingValue    float64 `json:"sizing_value"`
+	DelaySeconds   int64   `json:"delay_seconds"`
+	ExitStrategy   string  `json:"exit_strategy"`
+	ExitValue      float64 `json:"exit_value"`
+	InitialUsd     float64 `json:"initial_usd"`
+	Slippage       float64 `json:"slippage"`
+	GasUsd         float64 `json:"gas_usd"`
+}
+
+// Backtest 用收集到的历史交易回测跟单关注地址的收益
+func Backtest(c *gin.Context) {
+	var req BacktestReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, errcode.BacktestParamsError, err)
+		return
+	}
+
+	if !util.CheckChainName(req.ChainName) {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("chain name error"))
+		return
+	}
+
+	if len(req.Addresses) == 0 {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("addresses error"))
+		return
+	}
+
+	params := &backtest.Params{
+		ChainName:      req.ChainName,
+		Addresses:      req.Addresses,
+		EntryFilter:    req.EntryFilter,
+		EntryValue:     req.EntryValue,
+		SizingStrategy: req.SizingStrategy,
+		SizingValue:    req.SizingValue,
+		DelaySeconds:   req.DelaySeconds,
+		ExitStrategy:   req.ExitStrategy,
+		ExitValue:      req.ExitValue,
+		InitialUsd:     req.InitialUsd,
+		Slippage:       req.Slippage,
+		GasUsd:         req.GasUsd,
+	}
+	if req.Start != "" {
+		st, err := time.Parse("2006-01-02", req.Start)
+		if err != nil {
+			response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("start time is invalid"))
+			return
+		}
+		params.Start = st.Unix()
+	}
+	if req.End != "" {
+		et, err := time.Parse("2006-01-02", req.End)
+		if err != nil {
+			response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("end time is invalid"))
+			return
+		}
+		params.End = et.AddDate(0, 0, 1).Unix() - 1
+	}
+	if params.Start > 0 && params.End > 0 && params.Start > params.End {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("start time is greater than end time"))
+		return
+	}
+
+	if !backtest.CheckEntryFilter(req.EntryFilter) || req.EntryValue < 0 {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("entry filter error"))
+		return
+	}
+
+	if !backtest.CheckSizing(req.SizingStrategy) || req.SizingValue < 0 {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("sizing error"))
+		return
+	}
+
+	if !exitstrategy.Check(req.ExitStrategy) || req.ExitValue < 0 {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("exit strategy error"))
+		return
+	}
+
+	if req.DelaySeconds < 0 || req.InitialUsd < 0 || req.Slippage < 0 || req.Slippage >= 1 || req.GasUsd < 0 {
+		response.BadRequest(c, errcode.BacktestParamsError, fmt.Errorf("params error"))
+		return
+	}
+
+	result, err := backtest.Run(params)
+	if err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	response.OK(c, result)
+}
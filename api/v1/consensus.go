@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/model"
+	"smart-money/pkg/response"
+)
+
+type ConsensusSignalDetail struct {
+	ID            uint   `json:"id"`
+	ChainName     string `json:"chain_name"`
+	TokenAddress  string `json:"token_address"`
+	Symbol        string `json:"symbol"`
+	AddressCount  int    `json:"address_count"`
+	Addresses     string `json:"addresses"`
+	TxHashes      string `json:"tx_hashes"`
+	FirstBuyTime  int64  `json:"first_buy_time"`
+	LastBuyTime   int64  `json:"last_buy_time"`
+	WindowSeconds int64  `json:"window_seconds"`
+	Status        int    `json:"status"`
+	FailReason    string `json:"fail_reason"`
+}
+
+type ListConsensusSignalReq struct {
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	ChainName string `form:"chain_name"`
+}
+
+type ListConsensusSignalResp []*ConsensusSignalDetail
+
+func ListConsensusSignal(c *gin.Context) {
+	var req ListConsensusSignalReq
+	if err := c.Bind(&req); err != nil {
+		response.BadRequest(c, errcode.ListConsensusSignalParamsError, err)
+		return
+	}
+
+	page := req.Page
+	pageSize := req.PageSize
+	if page == 0 {
+		page = defaultPage
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	query := model.GetDB().Model(&model.ConsensusSignal{})
+	if req.ChainName != "" {
+		query = query.Where("chain_name = ?", req.ChainName)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	var signals []*model.ConsensusSignal
+	err := query.Order("id desc").Offset(offset).Limit(pageSize).Find(&signals).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	resp := make(ListConsensusSignalResp, 0, len(signals))
+	for _, signal := range signals {
+		resp = append(resp, &ConsensusSignalDetail{
+			ID:            signal.ID,
+			ChainName:     signal.ChainName,
+			TokenAddress:  signal.TokenAddress,
+			Symbol:        signal.Symbol,
+			AddressCount:  signal.AddressCount,
+			Addresses:     signal.Addresses,
+			TxHashes:      signal.TxHashes,
+			FirstBuyTime:  signal.FirstBuyTime,
+			LastBuyTime:   signal.LastBuyTime,
+			WindowSeconds: signal.WindowSeconds,
+			Status:        signal.Status,
+			FailReason:    signal.FailReason,
+		})
+	}
+
+	response.OKList(c, count, resp)
+}
@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/model"
+	"smart-money/pkg/response"
+)
+
+const (
+	executionGroupByFollowAddress = "follow_address"
+	executionGroupByWallet        = "wallet"
+	executionGroupByChain         = "chain"
+)
+
+type ListExecutionStatsReq struct {
+	// follow_address(默认), wallet 或 chain
+	GroupBy   string `form:"group_by"`
+	ChainName string `form:"chain_name"`
+	// 不传时返回全部，0 实盘，1 模拟盘
+	IsPaper *int `form:"is_paper"`
+	// 日期格式 2006-01-02，按跟单创建时间过滤
+	Start string `form:"start"`
+	End   string `form:"end"`
+}
+
+// ExecutionStats 跟单执行质量，延迟都从关注地址交易上链开始计算，单位毫秒
+type ExecutionStats struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	// 收到信号的延迟
+	AvgSignalLatencyMs float64 `json:"avg_signal_latency_ms"`
+	// 发出兑换交易的延迟
+	AvgSubmitLatencyMs float64 `json:"avg_submit_latency_ms"`
+	P50SubmitLatencyMs float64 `json:"p50_submit_latency_ms"`
+	P90SubmitLatencyMs float64 `json:"p90_submit_latency_ms"`
+	// 兑换交易确认的延迟
+	AvgConfirmLatencyMs float64 `json:"avg_confirm_latency_ms"`
+	P50ConfirmLatencyMs float64 `json:"p50_confirm_latency_ms"`
+	P90ConfirmLatencyMs float64 `json:"p90_confirm_latency_ms"`
+	// 买入价相对关注地址买入价的溢价，0.1 表示贵 10%，只统计关注地址买入价已知的跟单
+	EntryPremiumCount int     `json:"entry_premium_count"`
+	AvgEntryPremium   float64 `json:"avg_entry_premium"`
+	P50EntryPremium   float64 `json:"p50_entry_premium"`
+	// 实际收到数量相对报价的偏差，负数表示少收
+	AvgFillDeviation float64 `json:"avg_fill_deviation"`
+	P50FillDeviation float64 `json:"p50_fill_deviation"`
+}
+
+type ListExecutionStatsResp []*ExecutionStats
+
+// executionSamples 一个分组内各项指标的样本
+type executionSamples struct {
+	count          int
+	signalLatency  []float64
+	submitLatency  []float64
+	confirmLatency []float64
+	entryPremium   []float64
+	fillDeviation  []float64
+}
+
+// ListExecutionStats 按关注地址、钱包或链汇总已买入跟单的延迟、买入价溢价和成交偏差
+func ListExecutionStats(c *gin.Context) {
+	var req ListExecutionStatsReq
+	if err := c.Bind(&req); err != nil {
+		response.BadRequest(c, errcode.ListExecutionStatsParamsError, err)
+		return
+	}
+	if req.GroupBy == "" {
+		req.GroupBy = executionGroupByFollowAddress
+	}
+	if req.GroupBy != executionGroupByFollowAddress && req.GroupBy != executionGroupByWallet && req.GroupBy != executionGroupByChain {
+		response.BadRequest(c, errcode.ListExecutionStatsParamsError, fmt.Errorf("group by error"))
+		return
+	}
+	if req.IsPaper != nil && *req.IsPaper != 0 && *req.IsPaper != 1 {
+		response.BadRequest(c, errcode.ListExecutionStatsParamsError, fmt.Errorf("is paper error"))
+		return
+	}
+
+	query := model.GetDB().Where("status in ?", []int{model.FollowTradeStatusSuccess, model.FollowTradeStatusExiting, model.FollowTradeStatusFinish})
+	if req.ChainName != "" {
+		query = query.Where("chain_name = ?", req.ChainName)
+	}
+	if req.IsPaper != nil {
+		query = query.Where("is_paper = ?", *req.IsPaper)
+	}
+	if req.Start != "" {
+		st, err := time.ParseInLocation("2006-01-02", req.Start, time.Local)
+		if err != nil {
+			response.BadRequest(c, errcode.ListExecutionStatsParamsError, fmt.Errorf("start time is invalid"))
+			return
+		}
+		query = query.Where("created_at >= ?", st)
+	}
+	if req.End != "" {
+		et, err := time.ParseInLocation("2006-01-02", req.End, time.Local)
+		if err != nil {
+			response.BadRequest(c, errcode.ListExecutionStatsParamsError, fmt.Errorf("end time is invalid"))
+			return
+		}
+		query = query.Where("created_at < ?", et.AddDate(0, 0, 1))
+	}
+
+	var followTrades []*model.FollowTrade
+	if err := query.Order("id").Find(&followTrades).Error; err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	groups := make(map[string]*executionSamples)
+	var keys []string
+	for _, followTrade := range followTrades {
+		key := executionGroupKey(req.GroupBy, followTrade)
+		samples, ok := groups[key]
+		if !ok {
+			samples = new(executionSamples)
+			groups[key] = samples
+			keys = append(keys, key)
+		}
+		samples.count++
+
+		// 时间没有记录的旧跟单不统计延迟
+		if blockTime := followTrade.FollowTxBlockTimeMs; blockTime > 0 {
+			if followTrade.SignalTimeMs > 0 {
+				samples.signalLatency = append(samples.signalLatency, float64(followTrade.SignalTimeMs-blockTime))
+			}
+			if followTrade.SubmitTimeMs > 0 {
+				samples.submitLatency = append(samples.submitLatency, float64(followTrade.SubmitTimeMs-blockTime))
+			}
+			if followTrade.ConfirmTimeMs > 0 {
+				samples.confirmLatency = append(samples.confirmLatency, float64(followTrade.ConfirmTimeMs-blockTime))
+			}
+		}
+		if followTrade.EntryPriceUsd > 0 && followTrade.FollowEntryPriceUsd > 0 {
+			samples.entryPremium = append(samples.entryPremium, followTrade.EntryPriceUsd/followTrade.FollowEntryPriceUsd-1)
+		}
+		if followTrade.QuotedAmount > 0 {
+			samples.fillDeviation = append(samples.fillDeviation, followTrade.WalletAddressBuyAmount/followTrade.QuotedAmount-1)
+		}
+	}
+
+	sort.Strings(keys)
+	resp := make(ListExecutionStatsResp, 0, len(keys))
+	for _, key := range keys {
+		samples := groups[key]
+		resp = append(resp, &ExecutionStats{
+			Key:                 key,
+			Count:               samples.count,
+			AvgSignalLatencyMs:  average(samples.signalLatency),
+			AvgSubmitLatencyMs:  average(samples.submitLatency),
+			P50SubmitLatencyMs:  percentile(samples.submitLatency, 0.5),
+			P90SubmitLatencyMs:  percentile(samples.submitLatency, 0.9),
+			AvgConfirmLatencyMs: average(samples.confirmLatency),
+			P50ConfirmLatencyMs: percentile(samples.confirmLatency, 0.5),
+			P90ConfirmLatencyMs: percentile(samples.confirmLatency, 0.9),
+			EntryPremiumCount:   len(samples.entryPremium),
+			AvgEntryPremium:     average(samples.entryPremium),
+			P50EntryPremium:     percentile(samples.entryPremium, 0.5),
+			AvgFillDeviation:    average(samples.fillDeviation),
+			P50FillDeviation:    percentile(samples.fillDeviation, 0.5),
+		})
+	}
+
+	response.OK(c, resp)
+}
+
+func executionGroupKey(groupBy string, followTrade *model.FollowTrade) string {
+	switch groupBy {
+	case executionGroupByWallet:
+		return followTrade.ChainName + ":" + strings.ToLower(followTrade.WalletAddress)
+	case executionGroupByChain:
+		return followTrade.ChainName
+	default:
+		return followTrade.ChainName + ":" + strings.ToLower(followTrade.FollowAddress)
+	}
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// percentile 按最近排名法取分位数，p 取值 0-1
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"smart-money/internal/exitstrategy"
+	"smart-money/internal/sizing"
 	"smart-money/pkg/errcode"
 	"smart-money/pkg/model"
 	"smart-money/pkg/oklink"
@@ -15,11 +19,27 @@ import (
 )
 
 type FollowAddressDetail struct {
-	ChainName       string `json:"chain_name"`
-	Address         string `json:"address"`
-	LastErc20TxHash string `json:"last_erc20_tx_hash"`
-	LastErc20TxTime int64  `json:"last_erc20_tx_time"`
-	Status          int    `json:"status"`
+	ID              uint    `json:"id"`
+	ChainName       string  `json:"chain_name"`
+	Address         string  `json:"address"`
+	LastErc20TxHash string  `json:"last_erc20_tx_hash"`
+	LastErc20TxTime int64   `json:"last_erc20_tx_time"`
+	Status          int     `json:"status"`
+	StopReason      string  `json:"stop_reason"`
+	SizingStrategy  string  `json:"sizing_strategy"`
+	SizingValue     float64 `json:"sizing_value"`
+	ExitStrategy    string  `json:"exit_strategy"`
+	ExitValue       float64 `json:"exit_value"`
+	IsPaper         int     `json:"is_paper"`
+	MaxTradeUsd     float64 `json:"max_trade_usd"`
+	MaxDailyUsd     float64 `json:"max_daily_usd"`
+	Slippage        float64 `json:"slippage"`
+	TokenWhitelist  string  `json:"token_whitelist"`
+	TokenBlacklist  string  `json:"token_blacklist"`
+	MinFollowBuyUsd float64 `json:"min_follow_buy_usd"`
+	MaxPriceMove    float64 `json:"max_price_move"`
+	CopyDelay       int64   `json:"copy_delay"`
+	WalletGroup     string  `json:"wallet_group"`
 }
 
 type ListFollowAddressReq struct {
@@ -63,11 +83,27 @@ func ListFollowAddress(c *gin.Context) {
 	resp := make(ListFollowAddressResp, 0, len(followAddresses))
 	for _, followAddress := range followAddresses {
 		resp = append(resp, &FollowAddressDetail{
+			ID:              followAddress.ID,
 			ChainName:       followAddress.ChainName,
 			Address:         followAddress.Address,
 			Status:          followAddress.Status,
 			LastErc20TxHash: followAddress.LastErc20TxHash,
 			LastErc20TxTime: followAddress.LastErc20TxTime,
+			StopReason:      followAddress.StopReason,
+			SizingStrategy:  followAddress.SizingStrategy,
+			SizingValue:     followAddress.SizingValue,
+			ExitStrategy:    followAddress.ExitStrategy,
+			ExitValue:       followAddress.ExitValue,
+			IsPaper:         followAddress.IsPaper,
+			MaxTradeUsd:     followAddress.MaxTradeUsd,
+			MaxDailyUsd:     followAddress.MaxDailyUsd,
+			Slippage:        followAddress.Slippage,
+			TokenWhitelist:  followAddress.TokenWhitelist,
+			TokenBlacklist:  followAddress.TokenBlacklist,
+			MinFollowBuyUsd: followAddress.MinFollowBuyUsd,
+			MaxPriceMove:    followAddress.MaxPriceMove,
+			CopyDelay:       followAddress.CopyDelay,
+			WalletGroup:     followAddress.WalletGroup,
 		})
 	}
 
@@ -75,9 +111,24 @@ func ListFollowAddress(c *gin.Context) {
 }
 
 type CreateFollowAddressReq struct {
-	ChainName string `json:"chain_name"`
-	Address   string `json:"address"`
-	Status    int    `json:"status"`
+	ChainName      string  `json:"chain_name"`
+	Address        string  `json:"address"`
+	Status         int     `json:"status"`
+	SizingStrategy string  `json:"sizing_strategy"`
+	SizingValue    float64 `json:"sizing_value"`
+	ExitStrategy   string  `json:"exit_strategy"`
+	ExitValue      float64 `json:"exit_value"`
+	IsPaper        int     `json:"is_paper"`
+	// 跟单限制，0 或空表示不限制
+	MaxTradeUsd     float64 `json:"max_trade_usd"`
+	MaxDailyUsd     float64 `json:"max_daily_usd"`
+	Slippage        float64 `json:"slippage"`
+	TokenWhitelist  string  `json:"token_whitelist"`
+	TokenBlacklist  string  `json:"token_blacklist"`
+	MinFollowBuyUsd float64 `json:"min_follow_buy_usd"`
+	MaxPriceMove    float64 `json:"max_price_move"`
+	CopyDelay       int64   `json:"copy_delay"`
+	WalletGroup     string  `json:"wallet_group"`
 }
 
 type CreateFollowAddressResp struct {
@@ -105,6 +156,42 @@ func CreateFollowAddress(c *gin.Context) {
 		return
 	}
 
+	if !sizing.Check(req.SizingStrategy) || req.SizingValue < 0 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("sizing error"))
+		return
+	}
+
+	if req.IsPaper != 0 && req.IsPaper != 1 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("is paper error"))
+		return
+	}
+
+	if req.MaxTradeUsd < 0 || req.MaxDailyUsd < 0 || req.MinFollowBuyUsd < 0 || req.MaxPriceMove < 0 || req.CopyDelay < 0 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("trade config error"))
+		return
+	}
+
+	if req.Slippage < 0 || req.Slippage > maxSlippage {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("slippage error"))
+		return
+	}
+
+	whitelist, err := normalizeTokens(req.TokenWhitelist)
+	if err != nil {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("token whitelist error: %v", err))
+		return
+	}
+	blacklist, err := normalizeTokens(req.TokenBlacklist)
+	if err != nil {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("token blacklist error: %v", err))
+		return
+	}
+
+	if !exitstrategy.Check(req.ExitStrategy) || req.ExitValue < 0 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("exit strategy error"))
+		return
+	}
+
 	// 查找最新一条
 	latestTx, err := oklink.Api.GetToken20TransactionListByAddress(req.ChainName, req.Address, 1, 1)
 	if err != nil {
@@ -122,6 +209,20 @@ func CreateFollowAddress(c *gin.Context) {
 		Address:         req.Address,
 		Status:          req.Status,
 		LastErc20TxHash: latestTxTxHash,
+		SizingStrategy:  req.SizingStrategy,
+		SizingValue:     req.SizingValue,
+		ExitStrategy:    req.ExitStrategy,
+		ExitValue:       req.ExitValue,
+		IsPaper:         req.IsPaper,
+		MaxTradeUsd:     req.MaxTradeUsd,
+		MaxDailyUsd:     req.MaxDailyUsd,
+		Slippage:        req.Slippage,
+		TokenWhitelist:  whitelist,
+		TokenBlacklist:  blacklist,
+		MinFollowBuyUsd: req.MinFollowBuyUsd,
+		MaxPriceMove:    req.MaxPriceMove,
+		CopyDelay:       req.CopyDelay,
+		WalletGroup:     req.WalletGroup,
 	}
 
 	latestTxTime, err := strconv.Atoi(latestTx.Data[0].TransactionLists[0].TransactionTime)
@@ -142,6 +243,22 @@ func CreateFollowAddress(c *gin.Context) {
 type UpdateFollowAddressReq struct {
 	ID     int `json:"id"`
 	Status int `json:"status"`
+	// 不传时保持原来的策略
+	SizingStrategy *string  `json:"sizing_strategy"`
+	SizingValue    *float64 `json:"sizing_value"`
+	ExitStrategy   *string  `json:"exit_strategy"`
+	ExitValue      *float64 `json:"exit_value"`
+	IsPaper        *int     `json:"is_paper"`
+	// 不传时保持原来的限制
+	MaxTradeUsd     *float64 `json:"max_trade_usd"`
+	MaxDailyUsd     *float64 `json:"max_daily_usd"`
+	Slippage        *float64 `json:"slippage"`
+	TokenWhitelist  *string  `json:"token_whitelist"`
+	TokenBlacklist  *string  `json:"token_blacklist"`
+	MinFollowBuyUsd *float64 `json:"min_follow_buy_usd"`
+	MaxPriceMove    *float64 `json:"max_price_move"`
+	CopyDelay       *int64   `json:"copy_delay"`
+	WalletGroup     *string  `json:"wallet_group"`
 }
 
 type UpdateFollowAddressResp struct {
@@ -164,13 +281,108 @@ func UpdateFollowAddress(c *gin.Context) {
 		return
 	}
 
+	if (req.SizingStrategy != nil && !sizing.Check(*req.SizingStrategy)) || (req.SizingValue != nil && *req.SizingValue < 0) {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("sizing error"))
+		return
+	}
+
+	if (req.ExitStrategy != nil && !exitstrategy.Check(*req.ExitStrategy)) || (req.ExitValue != nil && *req.ExitValue < 0) {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("exit strategy error"))
+		return
+	}
+
+	if req.IsPaper != nil && *req.IsPaper != 0 && *req.IsPaper != 1 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("is paper error"))
+		return
+	}
+
+	for _, v := range []*float64{req.MaxTradeUsd, req.MaxDailyUsd, req.MinFollowBuyUsd, req.MaxPriceMove} {
+		if v != nil && *v < 0 {
+			response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("trade config error"))
+			return
+		}
+	}
+	if req.CopyDelay != nil && *req.CopyDelay < 0 {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("copy delay error"))
+		return
+	}
+
+	if req.Slippage != nil && (*req.Slippage < 0 || *req.Slippage > maxSlippage) {
+		response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("slippage error"))
+		return
+	}
+
+	var (
+		whitelist, blacklist string
+		err                  error
+	)
+	if req.TokenWhitelist != nil {
+		if whitelist, err = normalizeTokens(*req.TokenWhitelist); err != nil {
+			response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("token whitelist error: %v", err))
+			return
+		}
+	}
+	if req.TokenBlacklist != nil {
+		if blacklist, err = normalizeTokens(*req.TokenBlacklist); err != nil {
+			response.BadRequest(c, errcode.SaveFollowAddressParamsError, fmt.Errorf("token blacklist error: %v", err))
+			return
+		}
+	}
+
 	followAddress := new(model.FollowAddress)
-	err := model.GetDB().Where("id = ?", req.ID).First(followAddress).Error
+	err = model.GetDB().Where("id = ?", req.ID).First(followAddress).Error
 	if err != nil {
 		response.InternalServerError(c, err)
 		return
 	}
 	followAddress.Status = req.Status
+	if followAddress.Status == model.FollowAddressStatusNormal {
+		followAddress.StopReason = ""
+	} else if followAddress.StopReason == "" {
+		followAddress.StopReason = "manual"
+	}
+	if req.SizingStrategy != nil {
+		followAddress.SizingStrategy = *req.SizingStrategy
+	}
+	if req.SizingValue != nil {
+		followAddress.SizingValue = *req.SizingValue
+	}
+	if req.ExitStrategy != nil {
+		followAddress.ExitStrategy = *req.ExitStrategy
+	}
+	if req.ExitValue != nil {
+		followAddress.ExitValue = *req.ExitValue
+	}
+	if req.IsPaper != nil {
+		followAddress.IsPaper = *req.IsPaper
+	}
+	if req.MaxTradeUsd != nil {
+		followAddress.MaxTradeUsd = *req.MaxTradeUsd
+	}
+	if req.MaxDailyUsd != nil {
+		followAddress.MaxDailyUsd = *req.MaxDailyUsd
+	}
+	if req.Slippage != nil {
+		followAddress.Slippage = *req.Slippage
+	}
+	if req.TokenWhitelist != nil {
+		followAddress.TokenWhitelist = whitelist
+	}
+	if req.TokenBlacklist != nil {
+		followAddress.TokenBlacklist = blacklist
+	}
+	if req.MinFollowBuyUsd != nil {
+		followAddress.MinFollowBuyUsd = *req.MinFollowBuyUsd
+	}
+	if req.MaxPriceMove != nil {
+		followAddress.MaxPriceMove = *req.MaxPriceMove
+	}
+	if req.CopyDelay != nil {
+		followAddress.CopyDelay = *req.CopyDelay
+	}
+	if req.WalletGroup != nil {
+		followAddress.WalletGroup = *req.WalletGroup
+	}
 
 	if err := model.SaveFollowAddress(followAddress); err != nil {
 		response.InternalServerError(c, err)
@@ -206,3 +418,104 @@ func DeleteFollowAddress(c *gin.Context) {
 
 	response.OK(c, &DeleteFollowAddressResp{})
 }
+
+type ListFollowAddressSnapshotReq struct {
+	FollowAddressID uint `form:"follow_address_id"`
+	WindowDays      int  `form:"window_days"`
+	Page            int  `form:"page"`
+	PageSize        int  `form:"page_size"`
+}
+
+type FollowAddressSnapshotDetail struct {
+	FollowAddressID uint    `json:"follow_address_id"`
+	ChainName       string  `json:"chain_name"`
+	Address         string  `json:"address"`
+	WindowDays      int     `json:"window_days"`
+	TradeCount      int     `json:"trade_count"`
+	WinTotal        int     `json:"win_total"`
+	WinRate         float64 `json:"win_rate"`
+	WinRateLower    float64 `json:"win_rate_lower"`
+	ProfitTotalUsd  float64 `json:"profit_total_usd"`
+	MeanReturn      float64 `json:"mean_return"`
+	SnapshotTime    int64   `json:"snapshot_time"`
+}
+
+type ListFollowAddressSnapshotResp []*FollowAddressSnapshotDetail
+
+func ListFollowAddressSnapshot(c *gin.Context) {
+	var req ListFollowAddressSnapshotReq
+	if err := c.Bind(&req); err != nil {
+		response.BadRequest(c, errcode.ListFollowAddressParamsError, err)
+		return
+	}
+	if req.FollowAddressID == 0 {
+		response.BadRequest(c, errcode.ListFollowAddressParamsError, fmt.Errorf("follow address id error"))
+		return
+	}
+
+	page := req.Page
+	pageSize := req.PageSize
+	if page == 0 {
+		page = defaultPage
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	query := model.GetDB().Model(&model.FollowAddressSnapshot{}).Where("follow_address_id = ?", req.FollowAddressID)
+	if req.WindowDays > 0 {
+		query = query.Where("window_days = ?", req.WindowDays)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	var snapshots []*model.FollowAddressSnapshot
+	err := query.Order("id desc").Offset(offset).Limit(pageSize).Find(&snapshots).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	resp := make(ListFollowAddressSnapshotResp, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		resp = append(resp, &FollowAddressSnapshotDetail{
+			FollowAddressID: snapshot.FollowAddressID,
+			ChainName:       snapshot.ChainName,
+			Address:         snapshot.Address,
+			WindowDays:      snapshot.WindowDays,
+			TradeCount:      snapshot.TradeCount,
+			WinTotal:        snapshot.WinTotal,
+			WinRate:         snapshot.WinRate,
+			WinRateLower:    snapshot.WinRateLower,
+			ProfitTotalUsd:  snapshot.ProfitTotalUsd,
+			MeanReturn:      snapshot.MeanReturn,
+			SnapshotTime:    snapshot.SnapshotTime,
+		})
+	}
+
+	response.OKList(c, count, resp)
+}
+
+// maxSlippage 兑换滑点百分比的上限
+const maxSlippage = 50
+
+// normalizeTokens 校验逗号分隔的代币地址，去掉空白并转成小写
+func normalizeTokens(tokens string) (string, error) {
+	var result []string
+	for _, token := range strings.Split(tokens, ",") {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		if !common.IsHexAddress(token) {
+			return "", fmt.Errorf("token %s is not an address", token)
+		}
+		result = append(result, token)
+	}
+	return strings.Join(result, ","), nil
+}
@@ -2,12 +2,24 @@ package v1
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"smart-money/internal/cron"
+	"smart-money/internal/exchange"
+	"smart-money/internal/sizing"
+	inch "smart-money/pkg/1inch"
 	"smart-money/pkg/errcode"
+	"smart-money/pkg/log"
 	"smart-money/pkg/model"
 	"smart-money/pkg/response"
+	"smart-money/pkg/util"
 )
 
 type FollowTradeReq struct {
@@ -16,6 +28,7 @@ type FollowTradeReq struct {
 }
 
 type FollowTradeDetail struct {
+	ID                      uint    `json:"id"`
 	ChainName               string  `json:"chain_name"`
 	WalletAddress           string  `json:"wallet_address"`
 	FollowAddress           string  `json:"follow_address"`
@@ -34,6 +47,24 @@ type FollowTradeDetail struct {
 	SellPrincipalTxHash     string  `json:"sell_principal_tx_hash"`
 	Status                  int     `json:"status"`
 	FailReason              string  `json:"fail_reason"`
+	ConsensusSignalID       uint    `json:"consensus_signal_id"`
+	ExitedAmount            float64 `json:"exited_amount"`
+	SizingStrategy          string  `json:"sizing_strategy"`
+	SizeUsd                 float64 `json:"size_usd"`
+	HighPrice               float64 `json:"high_price"`
+	TakeProfitLevel         int     `json:"take_profit_level"`
+	IsPaper                 int     `json:"is_paper"`
+	Proceeds                float64 `json:"proceeds"`
+	RealizedPnl             float64 `json:"realized_pnl"`
+	ClosedAt                int64   `json:"closed_at"`
+	FollowTxBlockTimeMs     int64   `json:"follow_tx_block_time_ms"`
+	SignalTimeMs            int64   `json:"signal_time_ms"`
+	SubmitTimeMs            int64   `json:"submit_time_ms"`
+	ConfirmTimeMs           int64   `json:"confirm_time_ms"`
+	QuotedAmount            float64 `json:"quoted_amount"`
+	EntryPriceUsd           float64 `json:"entry_price_usd"`
+	FollowEntryPriceUsd     float64 `json:"follow_entry_price_usd"`
+	SwapProvider            string  `json:"swap_provider"`
 }
 
 type FollowTradeResp []*FollowTradeDetail
@@ -71,6 +102,7 @@ func ListFollowTrade(c *gin.Context) {
 	resp := make([]*FollowTradeDetail, 0, len(followTrades))
 	for _, followTrade := range followTrades {
 		resp = append(resp, &FollowTradeDetail{
+			ID:                      followTrade.ID,
 			ChainName:               followTrade.ChainName,
 			WalletAddress:           followTrade.WalletAddress,
 			FollowAddress:           followTrade.FollowAddress,
@@ -89,8 +121,388 @@ func ListFollowTrade(c *gin.Context) {
 			SellPrincipalTxHash:     followTrade.SellPrincipalTxHash,
 			Status:                  followTrade.Status,
 			FailReason:              followTrade.FailReason,
+			ConsensusSignalID:       followTrade.ConsensusSignalID,
+			ExitedAmount:            followTrade.ExitedAmount,
+			SizingStrategy:          followTrade.SizingStrategy,
+			SizeUsd:                 followTrade.SizeUsd,
+			HighPrice:               followTrade.HighPrice,
+			TakeProfitLevel:         followTrade.TakeProfitLevel,
+			IsPaper:                 followTrade.IsPaper,
+			Proceeds:                followTrade.Proceeds,
+			RealizedPnl:             followTrade.RealizedPnl,
+			ClosedAt:                followTrade.ClosedAt,
+			FollowTxBlockTimeMs:     followTrade.FollowTxBlockTimeMs,
+			SignalTimeMs:            followTrade.SignalTimeMs,
+			SubmitTimeMs:            followTrade.SubmitTimeMs,
+			ConfirmTimeMs:           followTrade.ConfirmTimeMs,
+			QuotedAmount:            followTrade.QuotedAmount,
+			EntryPriceUsd:           followTrade.EntryPriceUsd,
+			FollowEntryPriceUsd:     followTrade.FollowEntryPriceUsd,
+			SwapProvider:            followTrade.SwapProvider,
 		})
 	}
 
 	response.OKList(c, count, &resp)
 }
+
+type ListFollowTradeExitReq struct {
+	FollowTradeID uint `form:"follow_trade_id"`
+	Page          int  `form:"page"`
+	PageSize      int  `form:"page_size"`
+}
+
+type FollowTradeExitDetail struct {
+	ID                  uint    `json:"id"`
+	FollowTradeID       uint    `json:"follow_trade_id"`
+	ChainName           string  `json:"chain_name"`
+	WalletAddress       string  `json:"wallet_address"`
+	TokenAddress        string  `json:"token_address"`
+	Reason              string  `json:"reason"`
+	FollowAddressTxHash string  `json:"follow_address_tx_hash"`
+	Fraction            float64 `json:"fraction"`
+	SellAmount          float64 `json:"sell_amount"`
+	ReceiveAmount       float64 `json:"receive_amount"`
+	TxHash              string  `json:"tx_hash"`
+	Gas                 float64 `json:"gas"`
+	Status              int     `json:"status"`
+	FailReason          string  `json:"fail_reason"`
+	ExitTime            int64   `json:"exit_time"`
+	SwapProvider        string  `json:"swap_provider"`
+}
+
+type ListFollowTradeExitResp []*FollowTradeExitDetail
+
+// ListFollowTradeExit 跟单的卖出记录，包括跟随卖出和风控触发的卖出
+func ListFollowTradeExit(c *gin.Context) {
+	var req ListFollowTradeExitReq
+	if err := c.Bind(&req); err != nil {
+		response.BadRequest(c, errcode.ListFollowTradeExitParamsError, err)
+		return
+	}
+
+	page := req.Page
+	pageSize := req.PageSize
+	if page == 0 {
+		page = defaultPage
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	query := model.GetDB().Model(&model.FollowTradeExit{})
+	if req.FollowTradeID > 0 {
+		query = query.Where("follow_trade_id = ?", req.FollowTradeID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	var exits []*model.FollowTradeExit
+	err := query.Order("id desc").Offset(offset).Limit(pageSize).Find(&exits).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	resp := make(ListFollowTradeExitResp, 0, len(exits))
+	for _, exit := range exits {
+		resp = append(resp, &FollowTradeExitDetail{
+			ID:                  exit.ID,
+			FollowTradeID:       exit.FollowTradeID,
+			ChainName:           exit.ChainName,
+			WalletAddress:       exit.WalletAddress,
+			TokenAddress:        exit.TokenAddress,
+			Reason:              exit.Reason,
+			FollowAddressTxHash: exit.FollowAddressTxHash,
+			Fraction:            exit.Fraction,
+			SellAmount:          exit.SellAmount,
+			ReceiveAmount:       exit.ReceiveAmount,
+			TxHash:              exit.TxHash,
+			Gas:                 exit.Gas,
+			Status:              exit.Status,
+			FailReason:          exit.FailReason,
+			ExitTime:            exit.ExitTime,
+			SwapProvider:        exit.SwapProvider,
+		})
+	}
+
+	response.OKList(c, count, resp)
+}
+
+type ExitFollowTradeReq struct {
+	ID uint `json:"id"`
+}
+
+// ExitFollowTrade 手动卖出跟单的全部持仓，返回卖出记录
+func ExitFollowTrade(c *gin.Context) {
+	var req ExitFollowTradeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, errcode.ExitFollowTradeParamsError, err)
+		return
+	}
+	if req.ID == 0 {
+		response.BadRequest(c, errcode.ExitFollowTradeParamsError, fmt.Errorf("id is empty"))
+		return
+	}
+
+	exit, err := cron.ExitFollowTrade(req.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.BadRequest(c, errcode.ExitFollowTradeNotExistError, err)
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	response.OK(c, &FollowTradeExitDetail{
+		ID:                  exit.ID,
+		FollowTradeID:       exit.FollowTradeID,
+		ChainName:           exit.ChainName,
+		WalletAddress:       exit.WalletAddress,
+		TokenAddress:        exit.TokenAddress,
+		Reason:              exit.Reason,
+		FollowAddressTxHash: exit.FollowAddressTxHash,
+		Fraction:            exit.Fraction,
+		SellAmount:          exit.SellAmount,
+		ReceiveAmount:       exit.ReceiveAmount,
+		TxHash:              exit.TxHash,
+		Gas:                 exit.Gas,
+		Status:              exit.Status,
+		FailReason:          exit.FailReason,
+		ExitTime:            exit.ExitTime,
+		SwapProvider:        exit.SwapProvider,
+	})
+}
+
+type ListFollowTradePnlReq struct {
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	ChainName string `form:"chain_name"`
+	// 不传时返回全部，0 实盘，1 模拟盘
+	IsPaper *int `form:"is_paper"`
+}
+
+// FollowTradePnl 金额都以主流币计价，Usd 结尾的按当前主流币价格换算
+type FollowTradePnl struct {
+	FollowTradeID   uint    `json:"follow_trade_id"`
+	ChainName       string  `json:"chain_name"`
+	WalletAddress   string  `json:"wallet_address"`
+	BuyTokenAddress string  `json:"buy_token_address"`
+	BuySymbol       string  `json:"buy_symbol"`
+	IsPaper         int     `json:"is_paper"`
+	Status          int     `json:"status"`
+	Cost            float64 `json:"cost"`
+	Proceeds        float64 `json:"proceeds"`
+	HoldingAmount   float64 `json:"holding_amount"`
+	HoldingValue    float64 `json:"holding_value"`
+	RealizedPnl     float64 `json:"realized_pnl"`
+	Pnl             float64 `json:"pnl"`
+	PnlUsd          float64 `json:"pnl_usd"`
+}
+
+type FollowTradePnlSummary struct {
+	Count       int     `json:"count"`
+	OpenCount   int     `json:"open_count"`
+	WinCount    int     `json:"win_count"`
+	CostUsd     float64 `json:"cost_usd"`
+	ProceedsUsd float64 `json:"proceeds_usd"`
+	HoldingUsd  float64 `json:"holding_usd"`
+	PnlUsd      float64 `json:"pnl_usd"`
+}
+
+type ListFollowTradePnlResp struct {
+	Summary *FollowTradePnlSummary `json:"summary"`
+	Total   int64                  `json:"total"`
+	List    []*FollowTradePnl      `json:"list"`
+}
+
+// ListFollowTradePnl 跟单的盈亏，成本包括买入金额和买入 gas，卖出收入扣除卖出 gas，未卖出的持仓按实时报价估值
+// 汇总统计全部跟单，列表分页返回
+func ListFollowTradePnl(c *gin.Context) {
+	var req ListFollowTradePnlReq
+	if err := c.Bind(&req); err != nil {
+		response.BadRequest(c, errcode.ListFollowTradePnlParamsError, err)
+		return
+	}
+	if req.IsPaper != nil && *req.IsPaper != 0 && *req.IsPaper != 1 {
+		response.BadRequest(c, errcode.ListFollowTradePnlParamsError, fmt.Errorf("is paper error"))
+		return
+	}
+	page := req.Page
+	pageSize := req.PageSize
+	if page <= 0 {
+		page = defaultPage
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	offset := (page - 1) * pageSize
+
+	query := model.GetDB().Where("status in ?", []int{model.FollowTradeStatusSuccess, model.FollowTradeStatusExiting, model.FollowTradeStatusFinish})
+	if req.ChainName != "" {
+		query = query.Where("chain_name = ?", req.ChainName)
+	}
+	if req.IsPaper != nil {
+		query = query.Where("is_paper = ?", *req.IsPaper)
+	}
+	var followTrades []*model.FollowTrade
+	if err := query.Order("id").Find(&followTrades).Error; err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	ids := make([]uint, 0, len(followTrades))
+	for _, followTrade := range followTrades {
+		ids = append(ids, followTrade.ID)
+	}
+	var exits []*model.FollowTradeExit
+	err := model.GetDB().Where("follow_trade_id in ? and status = ?", ids, model.FollowTradeExitStatusSuccess).Find(&exits).Error
+	if err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+	proceeds := make(map[uint]float64)
+	for _, exit := range exits {
+		proceeds[exit.FollowTradeID] += exit.ReceiveAmount - exit.Gas
+	}
+
+	// 同一个代币的持仓合并报价，避免每个跟单都请求一次
+	holdingAmounts := make(map[string]float64)
+	for _, followTrade := range followTrades {
+		if followTrade.Status != model.FollowTradeStatusFinish {
+			holdingAmounts[holdingKey(followTrade)] += followTradeHoldingAmount(followTrade)
+		}
+	}
+	unitValues := make(map[string]float64)
+	for _, followTrade := range followTrades {
+		key := holdingKey(followTrade)
+		if _, ok := unitValues[key]; ok || holdingAmounts[key] <= 0 {
+			continue
+		}
+		unitValues[key], err = holdingUnitValue(followTrade, holdingAmounts[key])
+		if err != nil {
+			// 报价失败时持仓按 0 估值，不影响其他跟单
+			log.Errorf("ListFollowTradePnl: token %s: %v", key, err)
+		}
+	}
+
+	mainTokenPrices := make(map[string]float64)
+	resp := &ListFollowTradePnlResp{Summary: &FollowTradePnlSummary{}, Total: int64(len(followTrades)), List: make([]*FollowTradePnl, 0, pageSize)}
+	for i, followTrade := range followTrades {
+		price, ok := mainTokenPrices[followTrade.ChainName]
+		if !ok {
+			if price, err = sizing.MainTokenPriceUsd(followTrade.ChainName); err != nil {
+				response.InternalServerError(c, err)
+				return
+			}
+			mainTokenPrices[followTrade.ChainName] = price
+		}
+
+		pnl := &FollowTradePnl{
+			FollowTradeID:   followTrade.ID,
+			ChainName:       followTrade.ChainName,
+			WalletAddress:   followTrade.WalletAddress,
+			BuyTokenAddress: followTrade.BuyTokenAddress,
+			BuySymbol:       followTrade.BuySymbol,
+			IsPaper:         followTrade.IsPaper,
+			Status:          followTrade.Status,
+			Cost:            followTrade.WalletAddressSellAmount + followTrade.WalletAddressBuyGas,
+			Proceeds:        proceeds[followTrade.ID],
+		}
+		if followTrade.Status != model.FollowTradeStatusFinish {
+			pnl.HoldingAmount = followTradeHoldingAmount(followTrade)
+			pnl.HoldingValue = pnl.HoldingAmount * unitValues[holdingKey(followTrade)]
+		} else {
+			// 价值太低没有卖出的剩余持仓按结束时的估值计入
+			pnl.HoldingAmount = followTrade.DustAmount
+			pnl.HoldingValue = followTrade.DustValue
+		}
+		pnl.RealizedPnl = pnl.Proceeds - pnl.Cost
+		pnl.Pnl = pnl.Proceeds + pnl.HoldingValue - pnl.Cost
+		pnl.PnlUsd = pnl.Pnl * price
+		if i >= offset && i < offset+pageSize {
+			resp.List = append(resp.List, pnl)
+		}
+
+		summary := resp.Summary
+		summary.Count++
+		if followTrade.Status != model.FollowTradeStatusFinish {
+			summary.OpenCount++
+		}
+		if pnl.Pnl > 0 {
+			summary.WinCount++
+		}
+		summary.CostUsd += pnl.Cost * price
+		summary.ProceedsUsd += pnl.Proceeds * price
+		summary.HoldingUsd += pnl.HoldingValue * price
+		summary.PnlUsd += pnl.PnlUsd
+	}
+
+	response.OK(c, resp)
+}
+
+func followTradeHoldingAmount(followTrade *model.FollowTrade) float64 {
+	return math.Max(followTrade.WalletAddressBuyAmount-followTrade.ExitedAmount, 0)
+}
+
+func holdingKey(followTrade *model.FollowTrade) string {
+	return followTrade.ChainName + ":" + strings.ToLower(followTrade.BuyTokenAddress)
+}
+
+// holdingValueCacheTTL 持仓估值的缓存时间，盈亏接口频繁刷新时不重复报价
+const holdingValueCacheTTL = time.Minute
+
+type holdingValueCache struct {
+	unitValue float64
+	expiredAt time.Time
+}
+
+var (
+	holdingValueLock   sync.Mutex
+	holdingValueCaches = make(map[string]*holdingValueCache)
+)
+
+// holdingUnitValue 按代币的全部持仓报价，返回每个代币能换到的主流币，结果缓存 holdingValueCacheTTL
+func holdingUnitValue(followTrade *model.FollowTrade, amount float64) (float64, error) {
+	key := holdingKey(followTrade)
+	holdingValueLock.Lock()
+	cache, ok := holdingValueCaches[key]
+	holdingValueLock.Unlock()
+	if ok && time.Now().Before(cache.expiredAt) {
+		return cache.unitValue, nil
+	}
+
+	value, err := followTradeHoldingValue(followTrade, amount)
+	if err != nil {
+		return 0, err
+	}
+	unitValue := value / amount
+	holdingValueLock.Lock()
+	holdingValueCaches[key] = &holdingValueCache{unitValue: unitValue, expiredAt: time.Now().Add(holdingValueCacheTTL)}
+	holdingValueLock.Unlock()
+	return unitValue, nil
+}
+
+// followTradeHoldingValue 按兑换渠道的报价估算卖出持仓能收到的主流币
+func followTradeHoldingValue(followTrade *model.FollowTrade, amount float64) (float64, error) {
+	if amount <= 0 {
+		return 0, nil
+	}
+	rawAmount := decimal.NewFromFloat(amount).Mul(decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))).Floor()
+	mainToken := util.MainTokenInfo[followTrade.ChainName]
+	quote, err := exchange.Quote(followTrade.ChainName, &inch.SwapRequest{
+		FromTokenAddress: followTrade.BuyTokenAddress,
+		ToTokenAddress:   mainToken.ContractAddress,
+		Amount:           rawAmount.String(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get quote error: %v", err)
+	}
+	value, _ := decimal.NewFromBigInt(quote.ToAmount, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal)))).Float64()
+	return value, nil
+}
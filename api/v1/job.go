@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"smart-money/internal/cron"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/response"
+)
+
+func ListJob(c *gin.Context) {
+	response.OK(c, cron.Scheduler.Status())
+}
+
+type UpdateJobReq struct {
+	Name   string `json:"name"`
+	Enable bool   `json:"enable"`
+}
+
+type UpdateJobResp struct {
+}
+
+func UpdateJob(c *gin.Context) {
+	var req UpdateJobReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, errcode.UpdateJobParamsError, err)
+		return
+	}
+	if req.Name == "" {
+		response.BadRequest(c, errcode.UpdateJobParamsError, fmt.Errorf("name is empty"))
+		return
+	}
+
+	if err := cron.Scheduler.SetEnabled(req.Name, req.Enable); err != nil {
+		response.BadRequest(c, errcode.UpdateJobNotExistError, err)
+		return
+	}
+
+	response.OK(c, &UpdateJobResp{})
+}
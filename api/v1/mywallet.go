@@ -19,6 +19,7 @@ type WalletDetail struct {
 	ChainName      string    `json:"chain_name"`
 	EachSellAmount float64   `json:"each_sell_amount"`
 	Status         int       `json:"status"`
+	GroupName      string    `json:"group_name"`
 	CreateAt       time.Time `json:"create_at"`
 }
 
@@ -64,6 +65,7 @@ func ListWallet(c *gin.Context) {
 			ChainName:      wallet.ChainName,
 			EachSellAmount: wallet.EachSellAmount,
 			Status:         wallet.Status,
+			GroupName:      wallet.GroupName,
 			CreateAt:       wallet.CreatedAt,
 		})
 	}
@@ -78,6 +80,7 @@ type CreateWalletReq struct {
 	Name           string  `json:"name"`
 	EachSellAmount float64 `json:"each_sell_amount"`
 	Status         int     `json:"status"`
+	GroupName      string  `json:"group_name"`
 }
 
 type CreateWalletResp struct {
@@ -129,6 +132,7 @@ func CreateWallet(c *gin.Context) {
 		Name:           req.Name,
 		EachSellAmount: req.EachSellAmount,
 		Status:         model.MyWalletStatusEnable,
+		GroupName:      req.GroupName,
 	}
 
 	err = model.GetDB().Create(myWallet).Error
@@ -145,6 +149,7 @@ type UpdateWalletReq struct {
 	Name           string  `json:"name"`
 	EachSellAmount float64 `json:"each_sell_amount"`
 	Status         int     `json:"status"`
+	GroupName      string  `json:"group_name"`
 }
 
 type UpdateWalletResp struct {
@@ -184,6 +189,7 @@ func UpdateWallet(c *gin.Context) {
 	myWallet.Name = req.Name
 	myWallet.EachSellAmount = req.EachSellAmount
 	myWallet.Status = req.Status
+	myWallet.GroupName = req.GroupName
 
 	err = model.GetDB().Save(&myWallet).Error
 	if err != nil {
@@ -1,13 +1,18 @@
 package v1
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"smart-money/config"
 )
 
-func Start() {
+// Start serves the api until ctx is done.
+func Start(ctx context.Context) error {
 	r := gin.Default()
 
 	group := r.Group("/api/v1")
@@ -16,6 +21,7 @@ func Start() {
 			group.POST("/work", Work)
 			group.GET("/list_work_status", ListWorkStatus)
 			group.GET("/list_address_trade", ListAddressTrade)
+			group.GET("/address/:address/profile", GetAddressProfile)
 		}
 
 		{
@@ -30,12 +36,55 @@ func Start() {
 			group.POST("/create_follow_address", CreateFollowAddress)
 			group.POST("/update_follow_address", UpdateFollowAddress)
 			group.POST("/delete_follow_address", DeleteFollowAddress)
+			group.GET("/list_follow_address_snapshot", ListFollowAddressSnapshot)
 		}
 
 		{
 			group.GET("/list_follow_trade", ListFollowTrade)
+			group.GET("/list_follow_trade_exit", ListFollowTradeExit)
+			group.GET("/list_follow_trade_pnl", ListFollowTradePnl)
+			group.POST("/exit_follow_trade", ExitFollowTrade)
+			group.GET("/list_execution_stats", ListExecutionStats)
 		}
+
+		{
+			group.GET("/list_consensus_signal", ListConsensusSignal)
+		}
+
+		{
+			group.POST("/backtest", Backtest)
+		}
+
+		{
+			group.GET("/jobs", ListJob)
+			group.POST("/update_job", UpdateJob)
+		}
+
+		{
+			group.GET("/trading_control", GetTradingControl)
+			group.POST("/update_trading_control", UpdateTradingControl)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.CFG.Server.Port),
+		Handler: r,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
 	}
 
-	r.Run(fmt.Sprintf(":%d", config.CFG.Server.Port))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
 }
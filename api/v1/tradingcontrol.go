@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"smart-money/internal/control"
+	"smart-money/pkg/errcode"
+	"smart-money/pkg/log"
+	"smart-money/pkg/response"
+)
+
+type TradingControlResp struct {
+	Halted    bool   `json:"halted"`
+	Source    string `json:"source"`
+	Reason    string `json:"reason"`
+	Liquidate bool   `json:"liquidate"`
+	HaltedAt  int64  `json:"halted_at"`
+	// 当天实盘已实现盈亏
+	DailyRealizedPnlUsd float64 `json:"daily_realized_pnl_usd"`
+}
+
+// GetTradingControl 全局交易开关和当天已实现盈亏
+func GetTradingControl(c *gin.Context) {
+	state := control.Get()
+	resp := &TradingControlResp{
+		Halted:    state.Halted == 1,
+		Source:    state.Source,
+		Reason:    state.Reason,
+		Liquidate: state.Liquidate == 1,
+		HaltedAt:  state.HaltedAt,
+	}
+	pnl, err := control.DailyRealizedPnlUsd()
+	if err != nil {
+		log.Errorf("GetTradingControl: %v", err)
+	}
+	resp.DailyRealizedPnlUsd = pnl
+	response.OK(c, resp)
+}
+
+type UpdateTradingControlReq struct {
+	// true 停止买入，false 恢复交易
+	Halted bool `json:"halted"`
+	// 停止时卖出所有未卖出的跟单
+	Liquidate bool   `json:"liquidate"`
+	Reason    string `json:"reason"`
+}
+
+type UpdateTradingControlResp struct {
+}
+
+func UpdateTradingControl(c *gin.Context) {
+	var req UpdateTradingControlReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, errcode.UpdateTradingControlParamsError, err)
+		return
+	}
+
+	var err error
+	if req.Halted {
+		err = control.Halt(control.SourceManual, req.Reason, req.Liquidate)
+	} else {
+		err = control.Resume()
+	}
+	if err != nil {
+		response.InternalServerError(c, err)
+		return
+	}
+
+	response.OK(c, &UpdateTradingControlResp{})
+}
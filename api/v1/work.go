@@ -7,8 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/panjf2000/ants/v2"
+	"smart-money/internal/analysis"
 	ccollector "smart-money/internal/collector"
 	"smart-money/internal/hunter"
+	"smart-money/internal/notify"
 	"smart-money/pkg/errcode"
 	"smart-money/pkg/log"
 	"smart-money/pkg/model"
@@ -64,20 +66,36 @@ func Work(c *gin.Context) {
 		if err := ht.Work(); err != nil {
 			hunterStatus[req.TaskName].Status = 2
 			log.Errorf("hunter work error: %v", err)
+			notifyHunterFinished(req.ChainName, req.TaskName, err)
 			return
 		}
 		hunterStatus[req.TaskName].Status = 1
+		notifyHunterFinished(req.ChainName, req.TaskName, nil)
 	}()
 	response.OK(c, nil)
 }
 
+func notifyHunterFinished(chainName, taskName string, err error) {
+	event := &notify.Event{
+		Type:  notify.EventHunterFinished,
+		Title: fmt.Sprintf("hunter task %s finished", taskName),
+		Data:  map[string]interface{}{"chain_name": chainName},
+	}
+	if err != nil {
+		event.Title = fmt.Sprintf("hunter task %s failed", taskName)
+		event.Message = err.Error()
+	}
+	notify.Send(event)
+}
+
 func ListWorkStatus(c *gin.Context) {
 	response.OK(c, hunterStatus)
 }
 
 type ListAddressTradeRequest struct {
-	Start string `form:"start"`
-	End   string `form:"end"`
+	Start           string  `form:"start"`
+	End             string  `form:"end"`
+	MinWinRateLower float64 `form:"min_win_rate_lower"`
 }
 
 type AddressTradeDetail struct {
@@ -89,8 +107,18 @@ type AddressTradeDetail struct {
 	WinTotal           int     `json:"win_total"`
 	LoseTotal          int     `json:"lose_total"`
 	WinRate            float64 `json:"win_rate"`
+	WinRateLower       float64 `json:"win_rate_lower"`
+	WinRateUpper       float64 `json:"win_rate_upper"`
+	MeanReturn         float64 `json:"mean_return"`
+	MeanReturnLower    float64 `json:"mean_return_lower"`
+	MeanReturnUpper    float64 `json:"mean_return_upper"`
+	PValue             float64 `json:"p_value"`
 	MaxMultiPle        float64 `json:"max_multiple"`
 	HoldingAvgDuration int64   `json:"holding_avg_duration"`
+
+	AvgEntryBlocksAfterLaunch  float64 `json:"avg_entry_blocks_after_launch"`
+	AvgEntryMinutesAfterLaunch float64 `json:"avg_entry_minutes_after_launch"`
+	AvgEntryMarketCapUsd       float64 `json:"avg_entry_market_cap_usd"`
 }
 
 type ListAddressTradeResponse []*AddressTradeDetail
@@ -102,6 +130,11 @@ func ListAddressTrade(c *gin.Context) {
 		return
 	}
 
+	if req.MinWinRateLower < 0 || req.MinWinRateLower > 1 {
+		response.BadRequest(c, errcode.WorkParamsError, fmt.Errorf("min win rate lower is invalid"))
+		return
+	}
+
 	st, err := time.Parse("2006-01-02", req.Start)
 	if err != nil {
 		response.BadRequest(c, errcode.WorkParamsError, err)
@@ -160,55 +193,45 @@ func ListAddressTrade(c *gin.Context) {
 		defer wg.Done()
 		address := i.(string)
 
-		var (
-			trades               []*model.AddressTrade
-			tradeCount           int
-			buyTotalUsd          float64
-			sellTotalUsd         float64
-			profitTotalUsd       float64
-			winTotal             int
-			loseTotal            int
-			winRate              float64
-			holdingTotalDuration uint64
-		)
-
-		err = model.GetDB().Where("address=? and first_tx_time between ? and ?", address, startUnix, endUnix).Find(&trades).Error
+		var trades []*model.AddressTrade
+		err := model.GetDB().Where("address=? and first_tx_time between ? and ?", address, startUnix, endUnix).Find(&trades).Error
 		if err != nil {
-			response.InternalServerError(c, err)
+			log.Errorf("ListAddressTrade: get address trade error: %v", err)
+			return
+		}
+
+		summary := analysis.Summarize(trades)
+		if summary.WinRateLower < req.MinWinRateLower {
 			return
 		}
-		tradeCount = len(trades)
-		var maxMultiPle float64
-
-		for _, trade := range trades {
-			if trade.Profit > 0 {
-				winTotal++
-			} else {
-				loseTotal++
-			}
-
-			buyTotalUsd += trade.BuyTotalUsd
-			sellTotalUsd += trade.SellTotalUsd
-			profitTotalUsd += trade.Profit
-			winRate = float64(winTotal) / float64(tradeCount)
-			holdingTotalDuration += trade.LastTxTime - trade.FirstTxTime
-
-			if trade.SellTotalUsd/trade.BuyTotalUsd > maxMultiPle {
-				maxMultiPle = trade.SellTotalUsd / trade.BuyTotalUsd
-			}
+
+		pValue, err := analysis.RandomEntryPValue(trades)
+		if err != nil {
+			log.Errorf("ListAddressTrade: calc p-value error: %v", err)
+			return
 		}
 
 		detail := &AddressTradeDetail{
 			Address:            address,
-			TradeCount:         tradeCount,
-			BuyTotalUsd:        buyTotalUsd,
-			SellTotalUsd:       sellTotalUsd,
-			ProfitTotalUsd:     profitTotalUsd,
-			WinTotal:           winTotal,
-			LoseTotal:          loseTotal,
-			WinRate:            winRate,
-			MaxMultiPle:        maxMultiPle,
-			HoldingAvgDuration: int64(holdingTotalDuration) / int64(tradeCount),
+			TradeCount:         summary.TradeCount,
+			BuyTotalUsd:        summary.BuyTotalUsd,
+			SellTotalUsd:       summary.SellTotalUsd,
+			ProfitTotalUsd:     summary.ProfitTotalUsd,
+			WinTotal:           summary.WinTotal,
+			LoseTotal:          summary.LoseTotal,
+			WinRate:            summary.WinRate,
+			WinRateLower:       summary.WinRateLower,
+			WinRateUpper:       summary.WinRateUpper,
+			MeanReturn:         summary.MeanReturn,
+			MeanReturnLower:    summary.MeanReturnLower,
+			MeanReturnUpper:    summary.MeanReturnUpper,
+			PValue:             pValue,
+			MaxMultiPle:        summary.MaxMultiple,
+			HoldingAvgDuration: summary.HoldingAvgDuration,
+
+			AvgEntryBlocksAfterLaunch:  summary.AvgEntryBlocksAfterLaunch,
+			AvgEntryMinutesAfterLaunch: summary.AvgEntryMinutesAfterLaunch,
+			AvgEntryMarketCapUsd:       summary.AvgEntryMarketCapUsd,
 		}
 
 		mu.Lock()
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"github.com/shopspring/decimal"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/imroc/req/v3"
@@ -19,7 +21,11 @@ import (
 	"github.com/urfave/cli/v2"
 	v1 "smart-money/api/v1"
 	"smart-money/config"
+	"smart-money/internal/backtest"
 	"smart-money/internal/cron"
+	"smart-money/internal/notify"
+	"smart-money/internal/scheduler"
+	inch "smart-money/pkg/1inch"
 	"smart-money/pkg/eth"
 	"smart-money/pkg/log"
 	"smart-money/pkg/model"
@@ -62,6 +68,89 @@ func main() {
 				Name:   "listwork",
 				Action: listWork,
 			},
+			{
+				Name:   "listjob",
+				Action: listJob,
+			},
+			{
+				Name:   "updatejob",
+				Action: updateJob,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "job name",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "enable",
+						Usage: "enable or disable the job",
+					},
+				},
+			},
+			{
+				Name:   "exitfollowtrade",
+				Usage:  "sell the full remaining balance of a follow trade",
+				Action: exitFollowTrade,
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:     "id",
+						Usage:    "follow trade id",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:   "executionstats",
+				Usage:  "show copy-trade latency, entry premium and fill deviation",
+				Action: executionStats,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "group-by",
+						Usage: "follow_address, wallet or chain",
+						Value: "follow_address",
+					},
+					&cli.StringFlag{
+						Name:  "chain",
+						Usage: "chain name, empty for all",
+					},
+					&cli.StringFlag{
+						Name:  "mode",
+						Usage: "live, paper or empty for all",
+					},
+					&cli.StringFlag{
+						Name:  "start",
+						Usage: "start date",
+					},
+					&cli.StringFlag{
+						Name:  "end",
+						Usage: "end date",
+					},
+				},
+			},
+			{
+				Name:   "tradingcontrol",
+				Usage:  "show kill switch state and today's realized pnl",
+				Action: tradingControl,
+			},
+			{
+				Name:   "killswitch",
+				Usage:  "halt new buys, or resume trading without --enable",
+				Action: killSwitch,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "enable",
+						Usage: "halt trading, false to resume",
+					},
+					&cli.BoolFlag{
+						Name:  "liquidate",
+						Usage: "sell all open follow trades after halting",
+					},
+					&cli.StringFlag{
+						Name:  "reason",
+						Usage: "halt reason",
+					},
+				},
+			},
 			{
 				Name:   "listaddresstrade",
 				Action: listAddressTrade,
@@ -76,6 +165,117 @@ func main() {
 						Usage:    "end date",
 						Required: true,
 					},
+					&cli.Float64Flag{
+						Name:  "min-win-rate-lower",
+						Usage: "min lower bound of win rate",
+					},
+					&cli.BoolFlag{
+						Name:  "csv",
+						Usage: "export csv",
+					},
+				},
+			},
+			{
+				Name:   "createfollowaddress",
+				Usage:  "create follow address",
+				Action: createFollowAddress,
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "chain",
+						Usage:    "chain name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "address",
+						Usage:    "follow address",
+						Required: true,
+					},
+				}, followAddressConfigFlags...),
+			},
+			{
+				Name:   "updatefollowaddress",
+				Usage:  "update follow address, only the given flags are changed",
+				Action: updateFollowAddress,
+				Flags: append([]cli.Flag{
+					&cli.IntFlag{
+						Name:     "id",
+						Usage:    "follow address id",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:     "status",
+						Usage:    "1 normal, 2 stop",
+						Required: true,
+					},
+				}, followAddressConfigFlags...),
+			},
+			{
+				Name:   "backtest",
+				Usage:  "backtest following addresses with collected transactions",
+				Action: runBacktest,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "chain",
+						Usage:    "chain name",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:     "address",
+						Usage:    "followed address, can be repeated",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "start",
+						Usage: "start date",
+					},
+					&cli.StringFlag{
+						Name:  "end",
+						Usage: "end date",
+					},
+					&cli.StringFlag{
+						Name:  "entry-filter",
+						Usage: "all, first_buy or min_buy_usd",
+					},
+					&cli.Float64Flag{
+						Name:  "entry-value",
+						Usage: "entry filter param",
+					},
+					&cli.StringFlag{
+						Name:  "sizing",
+						Usage: "fixed_usd, proportional, equity_percent or each_sell_amount",
+					},
+					&cli.Float64Flag{
+						Name:  "sizing-value",
+						Usage: "sizing param",
+					},
+					&cli.Int64Flag{
+						Name:  "delay",
+						Usage: "follow delay seconds",
+					},
+					&cli.StringFlag{
+						Name:  "exit-strategy",
+						Usage: "sell_principal, exit_all or none",
+					},
+					&cli.Float64Flag{
+						Name:  "exit-value",
+						Usage: "exit strategy param",
+					},
+					&cli.Float64Flag{
+						Name:  "initial-usd",
+						Usage: "initial equity usd",
+					},
+					&cli.Float64Flag{
+						Name:  "slippage",
+						Usage: "slippage of each fill",
+					},
+					&cli.Float64Flag{
+						Name:  "gas-usd",
+						Usage: "gas usd of each fill",
+					},
+					&cli.BoolFlag{
+						Name:  "equity",
+						Usage: "show equity curve instead of fills",
+					},
 					&cli.BoolFlag{
 						Name:  "csv",
 						Usage: "export csv",
@@ -113,6 +313,8 @@ func cliBefore(c *cli.Context) error {
 	}
 
 	oklink.InitAPI(cfg.OkLink.ApiKey, cfg.OkLink.Host)
+	inch.SetAPIBase(cfg.Swap.OneInchUrl)
+	notify.Init()
 
 	if err = eth.InitClient(config.CFG.Web3.Rpc, config.CFG.Web3.ChainID); err != nil {
 		return err
@@ -122,22 +324,35 @@ func cliBefore(c *cli.Context) error {
 		return err
 	}
 
-	cron.Init()
-
 	return nil
 }
 
 func server(c *cli.Context) error {
-	v1.Start()
-	return nil
+	if err := cron.Init(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	err := v1.Start(ctx)
+
+	// 等待正在执行的任务结束
+	log.Infof("server stopping, wait for running jobs")
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if stopErr := cron.Stop(stopCtx); stopErr != nil {
+		log.Errorf("stop jobs error: %v", stopErr)
+	}
+	return err
 }
 
 func listAddressTrade(c *cli.Context) error {
 	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/list_address_trade", config.CFG.Server.Port)
 	reqC := req.C()
 	resp := reqC.Get(url).SetQueryParamsAnyType(map[string]interface{}{
-		"start": c.String("start"),
-		"end":   c.String("end"),
+		"start":              c.String("start"),
+		"end":                c.String("end"),
+		"min_win_rate_lower": c.Float64("min-win-rate-lower"),
 	}).Do()
 	if resp.Err != nil {
 		return resp.Err
@@ -156,16 +371,174 @@ func listAddressTrade(c *cli.Context) error {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 	t.AppendHeader(table.Row{"address", "trade_count", "buy_total_usd", "sell_total_usd", "profit_total_usd", "win_total",
-		"lose_total", "win_rate", "max_multiple", "holding_avg_duration"})
+		"lose_total", "win_rate", "win_rate_ci", "mean_return", "mean_return_ci", "p_value", "max_multiple", "holding_avg_duration",
+		"avg_entry_minutes", "avg_entry_market_cap"})
 
 	for _, detail := range response {
 		t.AppendRow(table.Row{detail.Address, detail.TradeCount, detail.BuyTotalUsd,
-			detail.SellTotalUsd, detail.ProfitTotalUsd, detail.WinTotal, detail.LoseTotal, detail.WinRate, detail.MaxMultiPle, detail.HoldingAvgDuration})
+			detail.SellTotalUsd, detail.ProfitTotalUsd, detail.WinTotal, detail.LoseTotal, detail.WinRate,
+			fmt.Sprintf("[%.4f, %.4f]", detail.WinRateLower, detail.WinRateUpper), detail.MeanReturn,
+			fmt.Sprintf("[%.4f, %.4f]", detail.MeanReturnLower, detail.MeanReturnUpper), detail.PValue,
+			detail.MaxMultiPle, detail.HoldingAvgDuration, detail.AvgEntryMinutesAfterLaunch, detail.AvgEntryMarketCapUsd})
+	}
+
+	if c.Bool("csv") {
+		t.RenderCSV()
+	} else {
+		t.Render()
+	}
+	return nil
+}
+
+// followAddressConfigFlags 关注地址的跟单配置，key 为接口字段名
+var followAddressConfigFlags = []cli.Flag{
+	&cli.StringFlag{Name: "sizing", Usage: "sizing strategy, empty for global config"},
+	&cli.Float64Flag{Name: "sizing-value", Usage: "sizing param"},
+	&cli.StringFlag{Name: "exit-strategy", Usage: "exit strategy, empty for global config"},
+	&cli.Float64Flag{Name: "exit-value", Usage: "exit strategy param"},
+	&cli.BoolFlag{Name: "paper", Usage: "paper trading"},
+	&cli.Float64Flag{Name: "max-trade-usd", Usage: "max spend usd per trade, 0 for no limit"},
+	&cli.Float64Flag{Name: "max-daily-usd", Usage: "max spend usd per day, 0 for no limit"},
+	&cli.Float64Flag{Name: "slippage", Usage: "swap slippage percent, 0 for default"},
+	&cli.StringFlag{Name: "token-whitelist", Usage: "only follow these tokens, comma separated"},
+	&cli.StringFlag{Name: "token-blacklist", Usage: "never follow these tokens, comma separated"},
+	&cli.Float64Flag{Name: "min-follow-buy-usd", Usage: "min usd of the followed buy"},
+	&cli.Float64Flag{Name: "max-price-move", Usage: "max price move since the followed buy, eg: 0.2"},
+	&cli.Int64Flag{Name: "copy-delay", Usage: "seconds to wait after the followed tx"},
+	&cli.StringFlag{Name: "wallet-group", Usage: "wallet group, empty for all wallets"},
+}
+
+// followAddressConfigBody onlySet 为 true 时只包含命令行传了的配置
+func followAddressConfigBody(c *cli.Context, onlySet bool) map[string]any {
+	body := make(map[string]any)
+	for _, flag := range followAddressConfigFlags {
+		name := flag.Names()[0]
+		if onlySet && !c.IsSet(name) {
+			continue
+		}
+		key := strings.ReplaceAll(name, "-", "_")
+		switch name {
+		case "sizing":
+			key = "sizing_strategy"
+		case "paper":
+			key = "is_paper"
+		}
+		switch flag.(type) {
+		case *cli.StringFlag:
+			body[key] = c.String(name)
+		case *cli.Float64Flag:
+			body[key] = c.Float64(name)
+		case *cli.Int64Flag:
+			body[key] = c.Int64(name)
+		case *cli.BoolFlag:
+			isPaper := 0
+			if c.Bool(name) {
+				isPaper = 1
+			}
+			body[key] = isPaper
+		}
+	}
+	return body
+}
+
+func createFollowAddress(c *cli.Context) error {
+	body := followAddressConfigBody(c, false)
+	body["chain_name"] = c.String("chain")
+	body["address"] = c.String("address")
+	body["status"] = model.FollowAddressStatusNormal
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/create_follow_address", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Post(url).SetBodyJsonMarshal(body).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func updateFollowAddress(c *cli.Context) error {
+	body := followAddressConfigBody(c, true)
+	body["id"] = c.Int("id")
+	body["status"] = c.Int("status")
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/update_follow_address", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Post(url).SetBodyJsonMarshal(body).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func runBacktest(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/backtest", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Post(url).SetBodyJsonMarshal(map[string]any{
+		"chain_name":      c.String("chain"),
+		"addresses":       c.StringSlice("address"),
+		"start":           c.String("start"),
+		"end":             c.String("end"),
+		"entry_filter":    c.String("entry-filter"),
+		"entry_value":     c.Float64("entry-value"),
+		"sizing_strategy": c.String("sizing"),
+		"sizing_value":    c.Float64("sizing-value"),
+		"delay_seconds":   c.Int64("delay"),
+		"exit_strategy":   c.String("exit-strategy"),
+		"exit_value":      c.Float64("exit-value"),
+		"initial_usd":     c.Float64("initial-usd"),
+		"slippage":        c.Float64("slippage"),
+		"gas_usd":         c.Float64("gas-usd"),
+	}).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+
+	result := gjson.Get(resp.String(), "data").String()
+
+	var response backtest.Result
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return err
+	}
+
+	s := response.Summary
+	st := table.NewWriter()
+	st.SetOutputMirror(os.Stdout)
+	st.AppendHeader(table.Row{"initial_usd", "final_equity_usd", "pnl_usd", "return", "realized_pnl_usd", "gas_usd", "max_drawdown",
+		"signal_count", "trade_count", "closed_count", "win_count", "win_rate", "skipped"})
+	st.AppendRow(table.Row{s.InitialUsd, s.FinalEquityUsd, s.PnlUsd, s.Return, s.RealizedPnlUsd, s.GasUsd, s.MaxDrawdown,
+		s.SignalCount, s.TradeCount, s.ClosedCount, s.WinCount, s.WinRate, s.Skipped})
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	if c.Bool("equity") {
+		t.AppendHeader(table.Row{"time", "equity_usd", "cash_usd"})
+		for _, point := range response.EquityCurve {
+			t.AppendRow(table.Row{time.Unix(point.Time, 0).Format(time.DateTime), point.EquityUsd, point.CashUsd})
+		}
+	} else {
+		t.AppendHeader(table.Row{"time", "side", "reason", "follow_address", "symbol", "token_address", "amount", "price_usd",
+			"value_usd", "gas_usd", "follow_tx_hash"})
+		for _, fill := range response.Fills {
+			t.AppendRow(table.Row{time.Unix(fill.Time, 0).Format(time.DateTime), fill.Side, fill.Reason, fill.FollowAddress,
+				fill.Symbol, fill.TokenAddress, fill.Amount, fill.PriceUsd, fill.ValueUsd, fill.GasUsd, fill.FollowTxHash})
+		}
 	}
 
 	if c.Bool("csv") {
 		t.RenderCSV()
 	} else {
+		st.Render()
 		t.Render()
 	}
 	return nil
@@ -210,6 +583,173 @@ func listWork(c *cli.Context) error {
 	return nil
 }
 
+func listJob(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/jobs", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Get(url).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+
+	result := gjson.Get(resp.String(), "data").String()
+
+	var statuses []*scheduler.JobStatus
+	if err := json.Unmarshal([]byte(result), &statuses); err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"name", "spec", "enabled", "running", "run_count", "last_run_at", "last_duration", "last_error", "next_run_at"})
+	for _, status := range statuses {
+		t.AppendRow(table.Row{status.Name, status.Spec, status.Enabled, status.Running, status.RunCount,
+			status.LastRunAt.Format(time.DateTime), status.LastDuration, status.LastError, status.NextRunAt.Format(time.DateTime)})
+	}
+	t.Render()
+
+	return nil
+}
+
+func updateJob(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/update_job", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Post(url).SetBodyJsonMarshal(map[string]any{
+		"name":   c.String("name"),
+		"enable": c.Bool("enable"),
+	}).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func exitFollowTrade(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/exit_follow_trade", config.CFG.Server.Port)
+	reqC := req.C().SetTimeout(5 * time.Minute)
+	resp := reqC.Post(url).SetBodyJsonMarshal(map[string]any{
+		"id": c.Uint("id"),
+	}).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+
+	data := gjson.Get(resp.String(), "data")
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"follow_trade_id", "sell_amount", "receive_amount", "gas", "tx_hash", "status", "fail_reason"})
+	t.AppendRow(table.Row{data.Get("follow_trade_id").Uint(), data.Get("sell_amount").Float(), data.Get("receive_amount").Float(),
+		data.Get("gas").Float(), data.Get("tx_hash").String(), data.Get("status").Int(), data.Get("fail_reason").String()})
+	t.Render()
+
+	return nil
+}
+
+func executionStats(c *cli.Context) error {
+	params := map[string]string{
+		"group_by":   c.String("group-by"),
+		"chain_name": c.String("chain"),
+		"start":      c.String("start"),
+		"end":        c.String("end"),
+	}
+	switch c.String("mode") {
+	case "":
+	case "live":
+		params["is_paper"] = "0"
+	case "paper":
+		params["is_paper"] = "1"
+	default:
+		return fmt.Errorf("mode %s error", c.String("mode"))
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/list_execution_stats", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Get(url).SetQueryParams(params).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"key", "count", "signal_ms", "submit_ms", "submit_p50", "submit_p90", "confirm_ms", "confirm_p50",
+		"confirm_p90", "premium_count", "premium", "premium_p50", "fill_dev", "fill_dev_p50"})
+	for _, item := range gjson.Get(resp.String(), "data").Array() {
+		t.AppendRow(table.Row{item.Get("key").String(), item.Get("count").Int(),
+			fmt.Sprintf("%.0f", item.Get("avg_signal_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("avg_submit_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("p50_submit_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("p90_submit_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("avg_confirm_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("p50_confirm_latency_ms").Float()),
+			fmt.Sprintf("%.0f", item.Get("p90_confirm_latency_ms").Float()),
+			item.Get("entry_premium_count").Int(),
+			fmt.Sprintf("%.2f%%", item.Get("avg_entry_premium").Float()*100),
+			fmt.Sprintf("%.2f%%", item.Get("p50_entry_premium").Float()*100),
+			fmt.Sprintf("%.2f%%", item.Get("avg_fill_deviation").Float()*100),
+			fmt.Sprintf("%.2f%%", item.Get("p50_fill_deviation").Float()*100)})
+	}
+	t.Render()
+
+	return nil
+}
+
+func tradingControl(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/trading_control", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Get(url).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+
+	data := gjson.Get(resp.String(), "data")
+	haltedAt := ""
+	if ts := data.Get("halted_at").Int(); ts > 0 {
+		haltedAt = time.Unix(ts, 0).Format(time.DateTime)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"halted", "source", "reason", "liquidate", "halted_at", "daily_realized_pnl_usd"})
+	t.AppendRow(table.Row{data.Get("halted").Bool(), data.Get("source").String(), data.Get("reason").String(),
+		data.Get("liquidate").Bool(), haltedAt, fmt.Sprintf("%.2f", data.Get("daily_realized_pnl_usd").Float())})
+	t.Render()
+
+	return nil
+}
+
+func killSwitch(c *cli.Context) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/update_trading_control", config.CFG.Server.Port)
+	reqC := req.C()
+	resp := reqC.Post(url).SetBodyJsonMarshal(map[string]any{
+		"halted":    c.Bool("enable"),
+		"liquidate": c.Bool("liquidate"),
+		"reason":    c.String("reason"),
+	}).Do()
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("get url failed, status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	fmt.Println("ok")
+	return nil
+}
+
 func work(c *cli.Context) error {
 	workUrl := fmt.Sprintf("http://127.0.0.1:%d/api/v1/work", config.CFG.Server.Port)
 	reqC := req.C()
@@ -12,6 +12,28 @@ type Config struct {
 	Web3   Web3   `ini:"web3"`
 	Server Server `ini:"server"`
 	Redis  Redis  `ini:"redis"`
+
+	Consensus Consensus `ini:"consensus"`
+	Rolling   Rolling   `ini:"rolling"`
+	Stream    Stream    `ini:"stream"`
+	Mempool   Mempool   `ini:"mempool"`
+	Scanner   Scanner   `ini:"scanner"`
+	Sizing    Sizing    `ini:"sizing"`
+	Allocator Allocator `ini:"allocator"`
+	Risk      Risk      `ini:"risk"`
+	Exit      Exit      `ini:"exit"`
+	Safety    Safety    `ini:"safety"`
+	Paper     Paper     `ini:"paper"`
+	Control   Control   `ini:"control"`
+	Swap      Swap      `ini:"swap"`
+
+	Notify         Notify         `ini:"notify"`
+	NotifyWebhook  NotifyWebhook  `ini:"notify_webhook"`
+	NotifyTelegram NotifyTelegram `ini:"notify_telegram"`
+	NotifyEmail    NotifyEmail    `ini:"notify_email"`
+
+	// Jobs 定时任务配置, key 为任务名, value 为执行间隔(如 10s)或 cron 表达式, off 表示关闭
+	Jobs map[string]string `ini:"-"`
 }
 
 type Server struct {
@@ -40,9 +62,204 @@ type Redis struct {
 	Addr string `ini:"addr"`
 }
 
+type Consensus struct {
+	// 至少 MinAddresses 个地址在 WindowSeconds 内买入同一代币才触发
+	MinAddresses  int   `ini:"min_addresses"`
+	WindowSeconds int64 `ini:"window_seconds"`
+	// 除关注地址外，额外监控排行榜前 N 名地址，0 表示不监控
+	LeaderboardTopN int `ini:"leaderboard_top_n"`
+	LeaderboardDays int `ini:"leaderboard_days"`
+	// 开启后只按共识信号跟单，不再按单个地址跟单
+	FollowTrigger bool `ini:"follow_trigger"`
+}
+
+type Rolling struct {
+	// 分析前是否重新收集关注地址的交易
+	Recollect bool `ini:"recollect"`
+	// 30 天胜率低于 MinWinRate30d 且交易数不少于 MinTrades30d 时停止跟单，0 表示不启用
+	MinWinRate30d float64 `ini:"min_win_rate_30d"`
+	MinTrades30d  int     `ini:"min_trades_30d"`
+	// 连续亏损的跟单数达到 MaxConsecutiveLosses 时停止跟单，0 表示不启用
+	MaxConsecutiveLosses int `ini:"max_consecutive_losses"`
+}
+
+type Stream struct {
+	// 开启后检测到的跟单信号发布到 redis stream, 由消费组中的执行者下单
+	Enable bool `ini:"enable"`
+	// 为空时使用默认值 follow:signal / follow-executor / follow:signal:dead
+	Key           string `ini:"key"`
+	Group         string `ini:"group"`
+	DeadLetterKey string `ini:"dead_letter_key"`
+	// 本进程启动的执行者数量，0 表示只发布不消费
+	Workers int `ini:"workers"`
+	// 消息超过 ClaimIdleSeconds 未确认时由其他执行者接管，默认 300
+	ClaimIdleSeconds int `ini:"claim_idle_seconds"`
+	// stream 保留的最大消息数，0 表示不裁剪
+	MaxLen int64 `ini:"max_len"`
+}
+
+type Mempool struct {
+	// 开启后订阅 pending 交易，关注地址的买入在确认前就发出跟单信号
+	Enable bool `ini:"enable"`
+	// websocket rpc 地址，为空时使用 web3.rpc
+	Ws string `ini:"ws"`
+}
+
+type Scanner struct {
+	// 开启后 web3 配置的链用 eth_getLogs 扫块发现关注地址的交易，其他链仍然轮询 oklink
+	Enable bool `ini:"enable"`
+	// 只扫描确认数达到 Confirmations 的区块，默认 12
+	Confirmations int64 `ini:"confirmations"`
+	// 每次最多扫描的区块数，默认 500
+	BatchBlocks int64 `ini:"batch_blocks"`
+}
+
+type Sizing struct {
+	// 默认仓位策略: each_sell_amount(钱包配置的固定数量), fixed_usd, proportional, equity_percent, leaderboard_score
+	Strategy string  `ini:"strategy"`
+	FixedUsd float64 `ini:"fixed_usd"`
+	// 按关注地址买入金额的比例跟单
+	ProportionalRatio float64 `ini:"proportional_ratio"`
+	// 按钱包原生币余额的比例跟单
+	EquityPercent float64 `ini:"equity_percent"`
+	// 评分(胜率置信下限)为 1 时的买入金额，实际金额为 ScoreBaseUsd * 评分，评分按最近 ScoreDays 天的交易计算
+	ScoreBaseUsd float64 `ini:"score_base_usd"`
+	ScoreDays    int     `ini:"score_days"`
+	// 买入金额不超过池子主流币流动性的比例，0 表示不限制
+	MaxLiquidityPercent float64 `ini:"max_liquidity_percent"`
+}
+
+type Allocator struct {
+	// 钱包选择策略: round_robin(默认), least_exposed, most_native_balance, sticky_per_token
+	Strategy string `ini:"strategy"`
+	// 估算一次跟单(授权+兑换)需要的 gas 上限，默认 500000
+	GasLimit uint64 `ini:"gas_limit"`
+}
+
+type Risk struct {
+	// 亏损达到 StopLossPercent 时全部卖出，如 0.3 表示 -30%，0 表示不启用
+	StopLossPercent float64 `ini:"stop_loss_percent"`
+	// 分批止盈，格式为 "涨幅:卖出原始持仓比例"，逗号分隔，如 "1:0.3,3:0.3" 表示涨 100% 卖 30%，涨 300% 再卖 30%
+	TakeProfitLadder string `ini:"take_profit_ladder"`
+	// 从最高价回撤 TrailingStopPercent 时全部卖出，盈利超过 TrailingActivatePercent 后才启用，0 表示不启用
+	TrailingStopPercent     float64 `ini:"trailing_stop_percent"`
+	TrailingActivatePercent float64 `ini:"trailing_activate_percent"`
+	// 持仓超过 MaxHoldHours 小时后全部卖出，0 表示不限制
+	MaxHoldHours int64 `ini:"max_hold_hours"`
+	// 单个代币、单个钱包未卖出跟单的最大美元价值，0 表示不限制
+	MaxTokenExposureUsd  float64 `ini:"max_token_exposure_usd"`
+	MaxWalletExposureUsd float64 `ini:"max_wallet_exposure_usd"`
+}
+
+type Exit struct {
+	// 默认退出策略: sell_principal(价值达到成本的 N 倍时出本, 默认), exit_all(收入加持仓价值达到成本的 N 倍时全部卖出),
+	// none(只靠跟随卖出和风控)
+	Strategy string `ini:"strategy"`
+	// 出本或全部卖出的倍数，默认 2
+	PrincipalMultiple float64 `ini:"principal_multiple"`
+}
+
+type Safety struct {
+	// 开启后买入前用 eth_call 模拟买入和卖出，检查貔貅、税率、合约权限和流动性
+	Enable bool `ini:"enable"`
+	// 模拟买入花费的主流币数量，默认 0.01
+	SimulateAmount float64 `ini:"simulate_amount"`
+	// 买入税、卖出税超过时不买，默认 0.1
+	MaxBuyTax  float64 `ini:"max_buy_tax"`
+	MaxSellTax float64 `ini:"max_sell_tax"`
+	// 池子主流币一侧的最小美元价值，0 表示不检查
+	MinLiquidityUsd float64 `ini:"min_liquidity_usd"`
+	// 是否允许未放弃所有权的合约带有增发、黑名单、暂停函数
+	AllowOwnerFunctions bool `ini:"allow_owner_functions"`
+	// 是否放行无法完成检查的代币，比如没有 v2 交易对无法模拟买卖，默认不放行
+	AllowUnknown bool `ini:"allow_unknown"`
+}
+
+type Paper struct {
+	// 开启后所有跟单都是模拟盘，也可以在关注地址上单独开启
+	Enable bool `ini:"enable"`
+	// 模拟成交在兑换渠道最好的报价基础上扣除的滑点，默认 0.01
+	Slippage float64 `ini:"slippage"`
+}
+
+type Control struct {
+	// 熔断器触发后是否卖出所有未卖出的跟单，手动停止时由请求参数决定
+	LiquidateOnTrip bool `ini:"liquidate_on_trip"`
+	// 当天实盘已实现亏损超过 MaxDailyLossUsd 美元时熔断，0 表示不启用
+	MaxDailyLossUsd float64 `ini:"max_daily_loss_usd"`
+	// 实盘兑换连续失败次数达到 MaxSwapFailures 时熔断，0 表示不启用
+	MaxSwapFailures int `ini:"max_swap_failures"`
+	// 最近 ErrorWindow 秒(默认 300)内请求数不少于 ErrorMinRequests(默认 20)且错误率超过上限时熔断，0 表示不启用
+	ErrorWindow        int64   `ini:"error_window"`
+	ErrorMinRequests   int     `ini:"error_min_requests"`
+	MaxRpcErrorRate    float64 `ini:"max_rpc_error_rate"`
+	MaxOklinkErrorRate float64 `ini:"max_oklink_error_rate"`
+}
+
+type Swap struct {
+	// 启用的兑换渠道，逗号分隔: 1inch(默认), 0x, paraswap, uniswap_v2, uniswap_v3，每次兑换选择扣除 gas 后收到最多的渠道
+	Providers string `ini:"providers"`
+	// 启用的渠道都报价失败时(聚合器 api 不可用或还没收录新币)直接通过 dex 路由兑换，逗号分隔，
+	// 默认 uniswap_v2,uniswap_v3，off 表示关闭
+	FallbackProviders string `ini:"fallback_providers"`
+	// 各渠道的 api 地址，为空时使用官方地址
+	OneInchUrl  string `ini:"oneinch_url"`
+	ZeroXUrl    string `ini:"zerox_url"`
+	ZeroXApiKey string `ini:"zerox_api_key"`
+	ParaSwapUrl string `ini:"paraswap_url"`
+	// uniswap v2 类路由合约，为空时使用内置的路由
+	UniswapV2Router string `ini:"uniswap_v2_router"`
+	// uniswap v3 SwapRouter 和 Quoter 合约，为空时使用内置的地址
+	UniswapV3Router string `ini:"uniswap_v3_router"`
+	UniswapV3Quoter string `ini:"uniswap_v3_quoter"`
+}
+
+// 通知渠道的 events 为逗号分隔的事件类型，为空时接收全部事件:
+// follow_signal, buy, sell, failure, risk, hunter_finished, low_balance
+type Notify struct {
+	// 钱包主流币余额低于 MinWalletBalanceUsd 美元时发送 low_balance 通知，0 表示不检查
+	MinWalletBalanceUsd float64 `ini:"min_wallet_balance_usd"`
+}
+
+type NotifyWebhook struct {
+	Enable bool   `ini:"enable"`
+	Url    string `ini:"url"`
+	Events string `ini:"events"`
+	// 请求超时秒数，默认 10
+	Timeout int `ini:"timeout"`
+}
+
+type NotifyTelegram struct {
+	Enable bool `ini:"enable"`
+	// bot api 地址，默认 https://api.telegram.org
+	ApiUrl   string `ini:"api_url"`
+	BotToken string `ini:"bot_token"`
+	ChatID   string `ini:"chat_id"`
+	Events   string `ini:"events"`
+	Timeout  int    `ini:"timeout"`
+}
+
+type NotifyEmail struct {
+	Enable bool   `ini:"enable"`
+	Host   string `ini:"host"`
+	Port   int    `ini:"port"`
+	// 为空时不认证
+	Username string `ini:"username"`
+	Password string `ini:"password"`
+	From     string `ini:"from"`
+	// 收件人，逗号分隔
+	To     string `ini:"to"`
+	Events string `ini:"events"`
+}
+
 func Init(path string) error {
-	if err := ini.MapTo(&CFG, path); err != nil {
+	f, err := ini.Load(path)
+	if err != nil {
+		return err
+	}
+	if err = f.MapTo(&CFG); err != nil {
 		return err
 	}
+	CFG.Jobs = f.Section("jobs").KeysHash()
 	return nil
 }
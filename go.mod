@@ -11,6 +11,7 @@ require (
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/panjf2000/ants/v2 v2.7.3
 	github.com/rivo/tview v0.0.0-20230406072732-e22ce9588bb4
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/shopspring/decimal v1.3.1
 	github.com/sirupsen/logrus v1.9.0
 	github.com/tidwall/gjson v1.14.4
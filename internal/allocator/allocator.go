@@ -0,0 +1,184 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const (
+	StrategyRoundRobin        = "round_robin"
+	StrategyLeastExposed      = "least_exposed"
+	StrategyMostNativeBalance = "most_native_balance"
+	StrategyStickyPerToken    = "sticky_per_token"
+
+	defaultGasLimit = 500000
+)
+
+var (
+	roundRobinLock sync.Mutex
+	// 每条链下一次轮询开始的位置
+	roundRobinNext = make(map[string]int)
+)
+
+// Check 检查策略名是否存在，空字符串表示使用默认策略
+func Check(name string) bool {
+	switch name {
+	case "", StrategyRoundRobin, StrategyLeastExposed, StrategyMostNativeBalance, StrategyStickyPerToken:
+		return true
+	}
+	return false
+}
+
+// Rank 返回链上可用的钱包，按配置的策略排序，调用方按顺序选择第一个满足条件的钱包，group 不为空时只使用该分组的钱包
+func Rank(chainName, tokenAddress, group string) ([]*model.MyWallet, error) {
+	query := model.GetDB().Where("chain_name = ? and status = ?", chainName, model.MyWalletStatusEnable)
+	if group != "" {
+		query = query.Where("group_name = ?", group)
+	}
+	var wallets []*model.MyWallet
+	if err := query.Order("id").Find(&wallets).Error; err != nil {
+		return nil, fmt.Errorf("get my wallet error: %v", err)
+	}
+	if len(wallets) == 0 {
+		if group != "" {
+			return nil, fmt.Errorf("no wallet available on %s in group %s", chainName, group)
+		}
+		return nil, fmt.Errorf("no wallet available on %s", chainName)
+	}
+
+	switch config.CFG.Allocator.Strategy {
+	case "", StrategyRoundRobin:
+		return roundRobin(chainName, wallets), nil
+	case StrategyLeastExposed:
+		return leastExposed(chainName, wallets)
+	case StrategyMostNativeBalance:
+		return mostNativeBalance(chainName, wallets)
+	case StrategyStickyPerToken:
+		return stickyPerToken(chainName, tokenAddress, wallets)
+	default:
+		return nil, fmt.Errorf("unknown allocator strategy %s", config.CFG.Allocator.Strategy)
+	}
+}
+
+// CheckGas 检查钱包的原生币能否支付 gas，主流币是原生币时还要够买入数量
+func CheckGas(chainName string, wallet *model.MyWallet, mainTokenAmount float64) error {
+	mainToken := util.MainTokenInfo[chainName]
+	if mainToken == nil {
+		return fmt.Errorf("chain %s not supported", chainName)
+	}
+
+	client, err := eth.ClientOf(chainName)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := client.GetEthClient().SuggestGasPrice(context.Background())
+	if err != nil {
+		return fmt.Errorf("get gas price error: %v", err)
+	}
+	gasLimit := config.CFG.Allocator.GasLimit
+	if gasLimit == 0 {
+		gasLimit = defaultGasLimit
+	}
+	need := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+	if strings.EqualFold(mainToken.ContractAddress, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee") {
+		amount := decimal.NewFromFloat(mainTokenAmount).Mul(decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal)))).Ceil()
+		need.Add(need, amount.BigInt())
+	}
+
+	balance, err := client.GetNativeBalance(wallet.Address)
+	if err != nil {
+		return fmt.Errorf("get native balance error: %v", err)
+	}
+	if balance.Cmp(need) < 0 {
+		return fmt.Errorf("wallet %s native balance %s less than %s", wallet.Address, balance, need)
+	}
+	return nil
+}
+
+// roundRobin 从上次选择的下一个钱包开始轮询
+func roundRobin(chainName string, wallets []*model.MyWallet) []*model.MyWallet {
+	roundRobinLock.Lock()
+	start := roundRobinNext[chainName] % len(wallets)
+	roundRobinNext[chainName] = start + 1
+	roundRobinLock.Unlock()
+
+	return append(wallets[start:], wallets[:start]...)
+}
+
+// leastExposed 按未卖出的实盘跟单的买入金额从小到大排序
+func leastExposed(chainName string, wallets []*model.MyWallet) ([]*model.MyWallet, error) {
+	type exposure struct {
+		WalletAddress string
+		SizeUsd       float64
+	}
+	var exposures []*exposure
+	err := model.GetDB().Model(&model.FollowTrade{}).Select("wallet_addreess as wallet_address, sum(size_usd) as size_usd").
+		Where("chain_name = ? and status in ? and is_paper = ?", chainName, model.FollowTradeOpenStatuses, 0).
+		Group("wallet_addreess").Scan(&exposures).Error
+	if err != nil {
+		return nil, fmt.Errorf("get wallet exposure error: %v", err)
+	}
+	exposureMap := make(map[string]float64)
+	for _, e := range exposures {
+		exposureMap[strings.ToLower(e.WalletAddress)] = e.SizeUsd
+	}
+
+	sort.SliceStable(wallets, func(i, j int) bool {
+		return exposureMap[strings.ToLower(wallets[i].Address)] < exposureMap[strings.ToLower(wallets[j].Address)]
+	})
+	return wallets, nil
+}
+
+// mostNativeBalance 按原生币余额从大到小排序
+func mostNativeBalance(chainName string, wallets []*model.MyWallet) ([]*model.MyWallet, error) {
+	client, err := eth.ClientOf(chainName)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]*big.Int)
+	for _, wallet := range wallets {
+		balance, err := client.GetNativeBalance(wallet.Address)
+		if err != nil {
+			return nil, fmt.Errorf("get wallet %s native balance error: %v", wallet.Address, err)
+		}
+		balances[wallet.Address] = balance
+	}
+
+	sort.SliceStable(wallets, func(i, j int) bool {
+		return balances[wallets[i].Address].Cmp(balances[wallets[j].Address]) > 0
+	})
+	return wallets, nil
+}
+
+// stickyPerToken 同一代币优先使用最近买过它的钱包，方便合并持仓，其他钱包按轮询排在后面
+func stickyPerToken(chainName, tokenAddress string, wallets []*model.MyWallet) ([]*model.MyWallet, error) {
+	followTrade := new(model.FollowTrade)
+	err := model.GetDB().Where("chain_name = ? and lower(buy_token_address) = ? and wallet_addreess != ''",
+		chainName, strings.ToLower(tokenAddress)).Order("id desc").Limit(1).Find(followTrade).Error
+	if err != nil {
+		return nil, fmt.Errorf("get follow trade error: %v", err)
+	}
+
+	wallets = roundRobin(chainName, wallets)
+	if followTrade.ID == 0 {
+		return wallets, nil
+	}
+	for i, wallet := range wallets {
+		if strings.EqualFold(wallet.Address, followTrade.WalletAddress) {
+			ranked := append([]*model.MyWallet{wallet}, wallets[:i]...)
+			return append(ranked, wallets[i+1:]...), nil
+		}
+	}
+	return wallets, nil
+}
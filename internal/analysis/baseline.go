@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/exp/rand"
+	"smart-money/pkg/model"
+)
+
+// Baseline 随机入场基准：用同一批代币在收集期内的成交价格序列，随机选择买入和卖出时点
+type Baseline struct {
+	series map[string][]float64
+}
+
+// LoadBaseline builds the usd price series of every token traded in trades
+// from the collected token transactions of all addresses.
+func LoadBaseline(trades []*model.AddressTrade) (*Baseline, error) {
+	b := &Baseline{series: make(map[string][]float64)}
+	for _, trade := range trades {
+		token := strings.ToLower(trade.BuyAddress)
+		if _, ok := b.series[token]; ok {
+			continue
+		}
+		prices, err := tokenPriceSeries(trade.ChainName, token)
+		if err != nil {
+			return nil, err
+		}
+		b.series[token] = prices
+	}
+	return b, nil
+}
+
+func tokenPriceSeries(chainName, tokenAddress string) ([]float64, error) {
+	var txs []*model.TokenTransactionCollect
+	err := model.GetDB().Where("chain_name = ? and (lower(buy_address) = ? or lower(sell_address) = ?)", chainName, tokenAddress, tokenAddress).
+		Order("tx_time asc").Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make([]float64, 0, len(txs))
+	for _, tx := range txs {
+		var (
+			tokenAmount float64
+			quoteAmount float64
+			quoteSymbol string
+		)
+		if strings.EqualFold(tx.BuyAddress, tokenAddress) {
+			tokenAmount, quoteAmount, quoteSymbol = tx.BuyAmount, tx.SellAmount, tx.SellSymbol
+		} else {
+			tokenAmount, quoteAmount, quoteSymbol = tx.SellAmount, tx.BuyAmount, tx.BuySymbol
+		}
+		if tokenAmount <= 0 || quoteAmount <= 0 {
+			continue
+		}
+		quoteUsd, ok := QuoteUsd(chainName, quoteSymbol, quoteAmount, tx.TxTime)
+		if !ok {
+			continue
+		}
+		prices = append(prices, quoteUsd/tokenAmount)
+	}
+	return prices, nil
+}
+
+// SampleReturn draws the return of a random entry and a later random exit of token.
+func (b *Baseline) SampleReturn(tokenAddress string, r *rand.Rand) (float64, bool) {
+	prices := b.series[strings.ToLower(tokenAddress)]
+	if len(prices) < 2 {
+		return 0, false
+	}
+	entry := r.Intn(len(prices) - 1)
+	exit := entry + 1 + r.Intn(len(prices)-entry-1)
+	return prices[exit]/prices[entry] - 1, true
+}
+
+// PValue returns the one-sided p-value of the mean return of trades against
+// random entries on the same tokens. Trades whose token has no usable price
+// series are left out; without any comparable trade the p-value is 1.
+func (b *Baseline) PValue(trades []*model.AddressTrade, simulations int, r *rand.Rand) float64 {
+	var (
+		comparable []*model.AddressTrade
+		returns    []float64
+	)
+	for _, trade := range trades {
+		ret, ok := TradeReturn(trade)
+		if !ok || len(b.series[strings.ToLower(trade.BuyAddress)]) < 2 {
+			continue
+		}
+		comparable = append(comparable, trade)
+		returns = append(returns, ret)
+	}
+	if len(comparable) == 0 || simulations <= 0 {
+		return 1
+	}
+
+	actual := Mean(returns)
+	exceed := 0
+	for i := 0; i < simulations; i++ {
+		var sum float64
+		for _, trade := range comparable {
+			ret, _ := b.SampleReturn(trade.BuyAddress, r)
+			sum += ret
+		}
+		if sum/float64(len(comparable)) >= actual {
+			exceed++
+		}
+	}
+	return float64(exceed+1) / float64(simulations+1)
+}
+
+// RandomEntryPValue loads the baseline of trades and returns their p-value.
+func RandomEntryPValue(trades []*model.AddressTrade) (float64, error) {
+	b, err := LoadBaseline(trades)
+	if err != nil {
+		return 0, err
+	}
+	r := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	return b.PValue(trades, DefaultResamples, r), nil
+}
@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"sort"
+
+	"smart-money/pkg/model"
+)
+
+type LeaderboardEntry struct {
+	ChainName string
+	Address   string
+	// Score 胜率置信下限，少量幸运交易的地址不会排到前面
+	Score   float64
+	Summary *Summary
+}
+
+// Leaderboard ranks the addresses whose trades started after since by score.
+// topN <= 0 returns all addresses.
+func Leaderboard(since int64, topN int) ([]*LeaderboardEntry, error) {
+	var trades []*model.AddressTrade
+	if err := model.GetDB().Where("first_tx_time >= ?", since).Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct{ chainName, address string }
+	grouped := make(map[key][]*model.AddressTrade)
+	for _, trade := range trades {
+		k := key{trade.ChainName, trade.Address}
+		grouped[k] = append(grouped[k], trade)
+	}
+
+	entries := make([]*LeaderboardEntry, 0, len(grouped))
+	for k, ts := range grouped {
+		summary := Summarize(DedupeTrades(ts))
+		entries = append(entries, &LeaderboardEntry{
+			ChainName: k.chainName,
+			Address:   k.address,
+			Score:     summary.WinRateLower,
+			Summary:   summary,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score == entries[j].Score {
+			return entries[i].Summary.ProfitTotalUsd > entries[j].Summary.ProfitTotalUsd
+		}
+		return entries[i].Score > entries[j].Score
+	})
+
+	if topN > 0 && len(entries) > topN {
+		entries = entries[:topN]
+	}
+	return entries, nil
+}
@@ -0,0 +1,20 @@
+package analysis
+
+import (
+	"smart-money/pkg/util"
+)
+
+// QuoteUsd converts an amount of a main token to usd at the date of txTime.
+func QuoteUsd(chainName, symbol string, amount float64, txTime uint64) (float64, bool) {
+	if !util.IsMainToken(symbol) {
+		return 0, false
+	}
+	if util.IsStableToken(symbol) {
+		return amount, true
+	}
+	price := util.GetMainTokenPriceInDate(chainName, int64(txTime))
+	if price == 0 {
+		return 0, false
+	}
+	return amount * price, true
+}
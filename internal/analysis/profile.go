@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+type Preference struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type TokenRoundTrip struct {
+	ChainName    string   `json:"chain_name"`
+	TokenAddress string   `json:"token_address"`
+	Symbol       string   `json:"symbol"`
+	BuyTotalUsd  float64  `json:"buy_total_usd"`
+	SellTotalUsd float64  `json:"sell_total_usd"`
+	Profit       float64  `json:"profit"`
+	FirstTxTime  uint64   `json:"first_tx_time"`
+	LastTxTime   uint64   `json:"last_tx_time"`
+	BuyTxHashes  []string `json:"buy_tx_hashes"`
+	SellTxHashes []string `json:"sell_tx_hashes"`
+
+	EntryBlocksAfterLaunch  int64   `json:"entry_blocks_after_launch"`
+	EntryMinutesAfterLaunch float64 `json:"entry_minutes_after_launch"`
+	EntryPriceUsd           float64 `json:"entry_price_usd"`
+	EntryMarketCapUsd       float64 `json:"entry_market_cap_usd"`
+}
+
+type Profile struct {
+	Address             string            `json:"address"`
+	Chains              []string          `json:"chains"`
+	TxCount             int               `json:"tx_count"`
+	PositionSizeUsd     float64           `json:"position_size_usd"`
+	PositionAvgUsd      float64           `json:"position_avg_usd"`
+	QuoteAssets         []*Preference     `json:"quote_assets"`
+	Dexes               []*Preference     `json:"dexes"`
+	ActiveHours         [24]int           `json:"active_hours"`
+	AvgFirstSellSeconds int64             `json:"avg_first_sell_seconds"`
+	Tokens              []string          `json:"tokens"`
+	RoundTrips          []*TokenRoundTrip `json:"round_trips"`
+}
+
+// BuildProfile describes how an address trades from its collected transactions and analyzed trades.
+func BuildProfile(address string) (*Profile, error) {
+	db := model.GetDB()
+
+	var txs []*model.TokenTransactionCollect
+	if err := db.Where("address = ?", address).Order("tx_time asc").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	var trades []*model.AddressTrade
+	if err := db.Where("address = ?", address).Order("first_tx_time asc").Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	p := &Profile{
+		Address:     address,
+		TxCount:     len(txs),
+		Chains:      []string{},
+		Tokens:      []string{},
+		QuoteAssets: []*Preference{},
+		Dexes:       []*Preference{},
+		RoundTrips:  []*TokenRoundTrip{},
+	}
+
+	var (
+		chains       = make(map[string]bool)
+		tokens       = make(map[string]bool)
+		quoteAssets  = make(map[string]int)
+		dexes        = make(map[string]int)
+		positionUsd  []float64
+		firstBuyAt   = make(map[string]uint64)
+		firstSellAt  = make(map[string]uint64)
+		buyTxHashes  = make(map[string][]string)
+		sellTxHashes = make(map[string][]string)
+	)
+
+	for _, tx := range txs {
+		chains[tx.ChainName] = true
+		p.ActiveHours[util.TxTime(tx.TxTime).UTC().Hour()]++
+		dexes[util.DexName(tx.ChainName, tx.RouterAddress)]++
+
+		// 买入：用主流币换非主流币
+		if !util.IsMainToken(tx.BuySymbol) && util.IsMainToken(tx.SellSymbol) {
+			token := strings.ToLower(tx.BuyAddress)
+			tokens[tx.BuySymbol] = true
+			quoteAssets[tx.SellSymbol]++
+			if usd, ok := QuoteUsd(tx.ChainName, tx.SellSymbol, tx.SellAmount, tx.TxTime); ok {
+				positionUsd = append(positionUsd, usd)
+			}
+			if _, ok := firstBuyAt[token]; !ok {
+				firstBuyAt[token] = tx.TxTime
+			}
+			buyTxHashes[token] = append(buyTxHashes[token], tx.TxHash)
+		}
+
+		// 卖出：用非主流币换主流币
+		if util.IsMainToken(tx.BuySymbol) && !util.IsMainToken(tx.SellSymbol) {
+			token := strings.ToLower(tx.SellAddress)
+			tokens[tx.SellSymbol] = true
+			if buyAt, ok := firstBuyAt[token]; ok && tx.TxTime >= buyAt {
+				if _, ok := firstSellAt[token]; !ok {
+					firstSellAt[token] = tx.TxTime
+				}
+			}
+			sellTxHashes[token] = append(sellTxHashes[token], tx.TxHash)
+		}
+	}
+
+	if len(positionUsd) > 0 {
+		p.PositionSizeUsd = median(positionUsd)
+		p.PositionAvgUsd = Mean(positionUsd)
+	}
+
+	var (
+		firstSellTotal int64
+		firstSellCount int64
+	)
+	for token, sellAt := range firstSellAt {
+		d := util.TxTime(sellAt).Sub(util.TxTime(firstBuyAt[token]))
+		firstSellTotal += int64(d.Seconds())
+		firstSellCount++
+	}
+	if firstSellCount > 0 {
+		p.AvgFirstSellSeconds = firstSellTotal / firstSellCount
+	}
+
+	// 同一地址可能在多个任务里被分析过，按链和代币去重
+	seen := make(map[string]bool)
+	for _, trade := range trades {
+		chains[trade.ChainName] = true
+		token := strings.ToLower(trade.BuyAddress)
+		key := token + "-" + trade.ChainName
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		tokens[trade.BuySymbol] = true
+
+		p.RoundTrips = append(p.RoundTrips, &TokenRoundTrip{
+			ChainName:    trade.ChainName,
+			TokenAddress: trade.BuyAddress,
+			Symbol:       trade.BuySymbol,
+			BuyTotalUsd:  trade.BuyTotalUsd,
+			SellTotalUsd: trade.SellTotalUsd,
+			Profit:       trade.Profit,
+			FirstTxTime:  trade.FirstTxTime,
+			LastTxTime:   trade.LastTxTime,
+			BuyTxHashes:  nonNil(buyTxHashes[token]),
+			SellTxHashes: nonNil(sellTxHashes[token]),
+
+			EntryBlocksAfterLaunch:  trade.EntryBlocksAfterLaunch,
+			EntryMinutesAfterLaunch: trade.EntryMinutesAfterLaunch,
+			EntryPriceUsd:           trade.EntryPriceUsd,
+			EntryMarketCapUsd:       trade.EntryMarketCapUsd,
+		})
+	}
+
+	for chain := range chains {
+		p.Chains = append(p.Chains, chain)
+	}
+	sort.Strings(p.Chains)
+	for token := range tokens {
+		p.Tokens = append(p.Tokens, token)
+	}
+	sort.Strings(p.Tokens)
+	p.QuoteAssets = sortPreferences(quoteAssets)
+	p.Dexes = sortPreferences(dexes)
+
+	return p, nil
+}
+
+func sortPreferences(m map[string]int) []*Preference {
+	prefs := make([]*Preference, 0, len(m))
+	for name, count := range m {
+		prefs = append(prefs, &Preference{Name: name, Count: count})
+	}
+	sort.Slice(prefs, func(i, j int) bool {
+		if prefs[i].Count == prefs[j].Count {
+			return prefs[i].Name < prefs[j].Name
+		}
+		return prefs[i].Count > prefs[j].Count
+	})
+	return prefs
+}
+
+func median(samples []float64) float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
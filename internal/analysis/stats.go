@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+)
+
+const (
+	// DefaultZ 95% 置信度对应的 z 值
+	DefaultZ = 1.96
+	// DefaultConfidence 默认置信度
+	DefaultConfidence = 0.95
+	// DefaultResamples bootstrap/随机基准的采样次数
+	DefaultResamples = 1000
+)
+
+// WilsonInterval returns the Wilson score interval of a binomial proportion.
+func WilsonInterval(wins, total int, z float64) (lower, upper float64) {
+	if total <= 0 {
+		return 0, 0
+	}
+	n := float64(total)
+	p := float64(wins) / n
+	z2 := z * z
+	denominator := 1 + z2/n
+	center := (p + z2/(2*n)) / denominator
+	margin := z * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / denominator
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// Mean returns the arithmetic mean of samples.
+func Mean(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s
+	}
+	return sum / float64(len(samples))
+}
+
+// BootstrapMeanCI returns the percentile bootstrap confidence interval of the mean.
+func BootstrapMeanCI(samples []float64, resamples int, confidence float64, r *rand.Rand) (lower, upper float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	if len(samples) == 1 || resamples <= 0 {
+		m := Mean(samples)
+		return m, m
+	}
+
+	means := make([]float64, resamples)
+	for i := 0; i < resamples; i++ {
+		var sum float64
+		for j := 0; j < len(samples); j++ {
+			sum += samples[r.Intn(len(samples))]
+		}
+		means[i] = sum / float64(len(samples))
+	}
+	sort.Float64s(means)
+
+	alpha := (1 - confidence) / 2
+	lowerIdx := int(math.Floor(alpha * float64(resamples)))
+	upperIdx := int(math.Ceil((1-alpha)*float64(resamples))) - 1
+	if upperIdx >= resamples {
+		upperIdx = resamples - 1
+	}
+	if upperIdx < lowerIdx {
+		upperIdx = lowerIdx
+	}
+	return means[lowerIdx], means[upperIdx]
+}
@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/exp/rand"
+	"smart-money/pkg/model"
+)
+
+type Summary struct {
+	TradeCount         int
+	BuyTotalUsd        float64
+	SellTotalUsd       float64
+	ProfitTotalUsd     float64
+	WinTotal           int
+	LoseTotal          int
+	WinRate            float64
+	WinRateLower       float64
+	WinRateUpper       float64
+	MeanReturn         float64
+	MeanReturnLower    float64
+	MeanReturnUpper    float64
+	MaxMultiple        float64
+	HoldingAvgDuration int64
+
+	AvgEntryBlocksAfterLaunch  float64
+	AvgEntryMinutesAfterLaunch float64
+	AvgEntryMarketCapUsd       float64
+}
+
+// TradeReturn returns the return of an address trade, eg: 0.5 means +50%.
+func TradeReturn(trade *model.AddressTrade) (float64, bool) {
+	if trade.BuyTotalUsd <= 0 {
+		return 0, false
+	}
+	return trade.SellTotalUsd/trade.BuyTotalUsd - 1, true
+}
+
+// DedupeTrades drops the same trade analyzed by several hunter tasks.
+func DedupeTrades(trades []*model.AddressTrade) []*model.AddressTrade {
+	type key struct {
+		chainName, address, buyAddress string
+		firstTxTime                    uint64
+	}
+	seen := make(map[key]bool)
+	result := make([]*model.AddressTrade, 0, len(trades))
+	for _, trade := range trades {
+		k := key{trade.ChainName, strings.ToLower(trade.Address), strings.ToLower(trade.BuyAddress), trade.FirstTxTime}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		result = append(result, trade)
+	}
+	return result
+}
+
+// Summarize aggregates the trades of one address.
+func Summarize(trades []*model.AddressTrade) *Summary {
+	s := &Summary{TradeCount: len(trades)}
+	if s.TradeCount == 0 {
+		return s
+	}
+
+	var (
+		holdingTotalDuration uint64
+		returns              = make([]float64, 0, len(trades))
+		entryBlocks          []float64
+		entryMinutes         []float64
+		entryMarketCaps      []float64
+	)
+	for _, trade := range trades {
+		if trade.Profit > 0 {
+			s.WinTotal++
+		} else {
+			s.LoseTotal++
+		}
+
+		s.BuyTotalUsd += trade.BuyTotalUsd
+		s.SellTotalUsd += trade.SellTotalUsd
+		s.ProfitTotalUsd += trade.Profit
+		holdingTotalDuration += trade.LastTxTime - trade.FirstTxTime
+
+		// 没有拿到上线时间的交易不参与入场时机统计
+		if trade.LaunchBlockHeight > 0 {
+			entryBlocks = append(entryBlocks, float64(trade.EntryBlocksAfterLaunch))
+			entryMinutes = append(entryMinutes, trade.EntryMinutesAfterLaunch)
+		}
+		if trade.EntryMarketCapUsd > 0 {
+			entryMarketCaps = append(entryMarketCaps, trade.EntryMarketCapUsd)
+		}
+
+		if ret, ok := TradeReturn(trade); ok {
+			returns = append(returns, ret)
+			if ret+1 > s.MaxMultiple {
+				s.MaxMultiple = ret + 1
+			}
+		}
+	}
+
+	s.WinRate = float64(s.WinTotal) / float64(s.TradeCount)
+	s.WinRateLower, s.WinRateUpper = WilsonInterval(s.WinTotal, s.TradeCount, DefaultZ)
+	s.HoldingAvgDuration = int64(holdingTotalDuration) / int64(s.TradeCount)
+
+	s.AvgEntryBlocksAfterLaunch = Mean(entryBlocks)
+	s.AvgEntryMinutesAfterLaunch = Mean(entryMinutes)
+	s.AvgEntryMarketCapUsd = Mean(entryMarketCaps)
+
+	r := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+	s.MeanReturn = Mean(returns)
+	s.MeanReturnLower, s.MeanReturnUpper = BootstrapMeanCI(returns, DefaultResamples, DefaultConfidence, r)
+
+	return s
+}
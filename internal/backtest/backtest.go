@@ -0,0 +1,582 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"smart-money/config"
+	"smart-money/internal/analysis"
+	"smart-money/internal/exitstrategy"
+	"smart-money/internal/sizing"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const (
+	EntryAll       = "all"
+	EntryFirstBuy  = "first_buy"
+	EntryMinBuyUsd = "min_buy_usd"
+
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	ReasonMirrorSell = "mirror_sell"
+
+	SkipHolding      = "holding"
+	SkipFiltered     = "filtered"
+	SkipNoPrice      = "no_price"
+	SkipNoCash       = "no_cash"
+	SkipSizeError    = "size_error"
+	SkipNotFollowing = "not_following"
+
+	defaultInitialUsd = 10000
+
+	// 卖出比例达到 fullExitFraction 时直接清仓，和实盘一致
+	fullExitFraction = 0.99
+)
+
+// Params 回测参数，金额都以美元计价
+type Params struct {
+	ChainName string   `json:"chain_name"`
+	Addresses []string `json:"addresses"`
+	// unix 秒，0 表示不限制
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+
+	// 入场过滤: all(默认), first_buy(只跟第一次买入), min_buy_usd(关注地址买入金额不低于 EntryValue)
+	EntryFilter string  `json:"entry_filter"`
+	EntryValue  float64 `json:"entry_value"`
+	// 仓位策略，同 sizing 包的策略名，回测没有钱包，默认 fixed_usd，each_sell_amount 的参数为主流币数量
+	SizingStrategy string  `json:"sizing_strategy"`
+	SizingValue    float64 `json:"sizing_value"`
+	// 关注地址交易后延迟多少秒跟单
+	DelaySeconds int64 `json:"delay_seconds"`
+	// 退出策略，同 exitstrategy 包，关注地址卖出时总会按比例跟随卖出
+	ExitStrategy string  `json:"exit_strategy"`
+	ExitValue    float64 `json:"exit_value"`
+
+	InitialUsd float64 `json:"initial_usd"`
+	// 每次成交相对历史价格的滑点
+	Slippage float64 `json:"slippage"`
+	// 每次成交的 gas
+	GasUsd float64 `json:"gas_usd"`
+}
+
+type Fill struct {
+	Time          int64   `json:"time"`
+	Side          string  `json:"side"`
+	Reason        string  `json:"reason"`
+	FollowAddress string  `json:"follow_address"`
+	FollowTxHash  string  `json:"follow_tx_hash"`
+	TokenAddress  string  `json:"token_address"`
+	Symbol        string  `json:"symbol"`
+	Amount        float64 `json:"amount"`
+	PriceUsd      float64 `json:"price_usd"`
+	ValueUsd      float64 `json:"value_usd"`
+	GasUsd        float64 `json:"gas_usd"`
+}
+
+// Trade 一次跟单从买入到卖完，未卖完的持仓按回测结束时的价格估值
+type Trade struct {
+	FollowAddress string  `json:"follow_address"`
+	TokenAddress  string  `json:"token_address"`
+	Symbol        string  `json:"symbol"`
+	EntryTime     int64   `json:"entry_time"`
+	ExitTime      int64   `json:"exit_time"`
+	EntryPriceUsd float64 `json:"entry_price_usd"`
+	CostUsd       float64 `json:"cost_usd"`
+	ProceedsUsd   float64 `json:"proceeds_usd"`
+	HoldingUsd    float64 `json:"holding_usd"`
+	PnlUsd        float64 `json:"pnl_usd"`
+	Open          bool    `json:"open"`
+}
+
+type EquityPoint struct {
+	Time      int64   `json:"time"`
+	EquityUsd float64 `json:"equity_usd"`
+	CashUsd   float64 `json:"cash_usd"`
+}
+
+type Summary struct {
+	InitialUsd     float64        `json:"initial_usd"`
+	FinalEquityUsd float64        `json:"final_equity_usd"`
+	PnlUsd         float64        `json:"pnl_usd"`
+	Return         float64        `json:"return"`
+	RealizedPnlUsd float64        `json:"realized_pnl_usd"`
+	GasUsd         float64        `json:"gas_usd"`
+	MaxDrawdown    float64        `json:"max_drawdown"`
+	SignalCount    int            `json:"signal_count"`
+	TradeCount     int            `json:"trade_count"`
+	ClosedCount    int            `json:"closed_count"`
+	WinCount       int            `json:"win_count"`
+	WinRate        float64        `json:"win_rate"`
+	Skipped        map[string]int `json:"skipped"`
+}
+
+type Result struct {
+	Summary     *Summary       `json:"summary"`
+	EquityCurve []*EquityPoint `json:"equity_curve"`
+	Fills       []*Fill        `json:"fills"`
+	Trades      []*Trade       `json:"trades"`
+}
+
+// CheckEntryFilter 检查入场过滤名是否存在
+func CheckEntryFilter(name string) bool {
+	switch name {
+	case "", EntryAll, EntryFirstBuy, EntryMinBuyUsd:
+		return true
+	}
+	return false
+}
+
+// CheckSizing 检查回测支持的仓位策略，leaderboard_score 用的是当前的评分，回测会用到未来数据
+func CheckSizing(name string) bool {
+	switch name {
+	case "", sizing.StrategyFixedUsd, sizing.StrategyProportional, sizing.StrategyEquityPercent, sizing.StrategyEachSellAmount:
+		return true
+	}
+	return false
+}
+
+// swap 一笔主流币和非主流币之间的兑换
+type swap struct {
+	time         int64
+	address      string
+	txHash       string
+	token        string
+	symbol       string
+	side         string
+	tokenAmount  float64
+	mainSymbol   string
+	mainAmount   float64
+	priceUsd     float64
+	valueUsd     float64
+	firstBuy     bool
+	sellFraction float64
+	signal       bool
+}
+
+type position struct {
+	trade     *Trade
+	amount    float64
+	buyGas    float64
+	principal bool
+}
+
+type engine struct {
+	params   *Params
+	strategy exitstrategy.ExitStrategy
+
+	cash      float64
+	prices    map[string]float64
+	positions map[string]*position
+	peak      float64
+	result    *Result
+}
+
+// Run 按时间顺序回放关注地址在 TokenTransactionCollect 里的历史交易，价格来自收集到的所有地址在同一代币上的成交
+func Run(params *Params) (*Result, error) {
+	if params.ChainName == "" || len(params.Addresses) == 0 {
+		return nil, fmt.Errorf("chain name and addresses are required")
+	}
+	if !CheckEntryFilter(params.EntryFilter) {
+		return nil, fmt.Errorf("unknown entry filter %s", params.EntryFilter)
+	}
+	if !CheckSizing(params.SizingStrategy) {
+		return nil, fmt.Errorf("sizing strategy %s not supported in backtest", params.SizingStrategy)
+	}
+	strategy, err := exitstrategy.New(params.ExitStrategy, params.ExitValue)
+	if err != nil {
+		return nil, err
+	}
+	if params.InitialUsd <= 0 {
+		params.InitialUsd = defaultInitialUsd
+	}
+
+	events, err := loadEvents(params)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &engine{
+		params:    params,
+		strategy:  strategy,
+		cash:      params.InitialUsd,
+		prices:    make(map[string]float64),
+		positions: make(map[string]*position),
+		peak:      params.InitialUsd,
+		result: &Result{
+			Summary:     &Summary{InitialUsd: params.InitialUsd, Skipped: make(map[string]int)},
+			EquityCurve: []*EquityPoint{},
+			Fills:       []*Fill{},
+			Trades:      []*Trade{},
+		},
+	}
+	if len(events) > 0 {
+		e.record(events[0].time)
+	}
+	for _, event := range events {
+		e.handle(event)
+	}
+	e.finish(events)
+	return e.result, nil
+}
+
+// loadEvents 返回按时间排序的价格事件和延迟后的跟单信号
+func loadEvents(params *Params) ([]*swap, error) {
+	addresses := make(map[string]bool)
+	lowerAddresses := make([]string, 0, len(params.Addresses))
+	for _, address := range params.Addresses {
+		addresses[strings.ToLower(address)] = true
+		lowerAddresses = append(lowerAddresses, strings.ToLower(address))
+	}
+
+	var followTxs []*model.TokenTransactionCollect
+	err := model.GetDB().Where("chain_name = ? and lower(address) in ?", params.ChainName, lowerAddresses).Find(&followTxs).Error
+	if err != nil {
+		return nil, fmt.Errorf("get follow address transactions error: %v", err)
+	}
+	tokenSet := make(map[string]bool)
+	for _, tx := range followTxs {
+		if s := parseSwap(params.ChainName, tx); s != nil {
+			tokenSet[s.token] = true
+		}
+	}
+	tokens := make([]string, 0, len(tokenSet))
+	for token := range tokenSet {
+		tokens = append(tokens, token)
+	}
+
+	var txs []*model.TokenTransactionCollect
+	err = model.GetDB().Where("chain_name = ? and (lower(buy_address) in ? or lower(sell_address) in ?)", params.ChainName, tokens, tokens).
+		Find(&txs).Error
+	if err != nil {
+		return nil, fmt.Errorf("get token transactions error: %v", err)
+	}
+
+	// 同一笔交易可能被多个收集任务记录
+	seen := make(map[string]bool)
+	var swaps []*swap
+	for _, tx := range txs {
+		key := strings.ToLower(tx.Address) + ":" + strings.ToLower(tx.TxHash)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if s := parseSwap(params.ChainName, tx); s != nil && (params.End == 0 || s.time <= params.End) {
+			swaps = append(swaps, s)
+		}
+	}
+	sort.SliceStable(swaps, func(i, j int) bool {
+		return swaps[i].time < swaps[j].time
+	})
+
+	// 按关注地址自己的持仓计算卖出比例和是否第一次买入
+	holdings := make(map[string]float64)
+	bought := make(map[string]bool)
+	events := make([]*swap, 0, len(swaps))
+	for _, s := range swaps {
+		events = append(events, s)
+		if !addresses[s.address] {
+			continue
+		}
+		key := s.address + ":" + s.token
+		signal := *s
+		signal.signal = true
+		signal.time = s.time + params.DelaySeconds
+		if s.side == SideBuy {
+			signal.firstBuy = !bought[key]
+			bought[key] = true
+			holdings[key] += s.tokenAmount
+		} else {
+			signal.sellFraction = 1
+			if holding := holdings[key]; holding > s.tokenAmount {
+				signal.sellFraction = s.tokenAmount / holding
+			}
+			holdings[key] = math.Max(holdings[key]-s.tokenAmount, 0)
+		}
+		if params.Start > 0 && s.time < params.Start {
+			continue
+		}
+		if params.End > 0 && signal.time > params.End {
+			continue
+		}
+		events = append(events, &signal)
+	}
+
+	// 同一时间先更新价格再处理信号，延迟为 0 时按关注地址的成交价跟单
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return !events[i].signal && events[j].signal
+	})
+	return events, nil
+}
+
+// parseSwap 只处理主流币和非主流币之间的兑换，无法换算美元价格时返回 nil
+func parseSwap(chainName string, tx *model.TokenTransactionCollect) *swap {
+	s := &swap{
+		time:    util.TxTime(tx.TxTime).Unix(),
+		address: strings.ToLower(tx.Address),
+		txHash:  tx.TxHash,
+	}
+	switch {
+	case !util.IsMainToken(tx.BuySymbol) && util.IsMainToken(tx.SellSymbol):
+		s.side, s.token, s.symbol, s.tokenAmount = SideBuy, strings.ToLower(tx.BuyAddress), tx.BuySymbol, tx.BuyAmount
+		s.mainSymbol, s.mainAmount = tx.SellSymbol, tx.SellAmount
+	case util.IsMainToken(tx.BuySymbol) && !util.IsMainToken(tx.SellSymbol):
+		s.side, s.token, s.symbol, s.tokenAmount = SideSell, strings.ToLower(tx.SellAddress), tx.SellSymbol, tx.SellAmount
+		s.mainSymbol, s.mainAmount = tx.BuySymbol, tx.BuyAmount
+	default:
+		return nil
+	}
+	if s.tokenAmount <= 0 {
+		return nil
+	}
+	usd, ok := analysis.QuoteUsd(chainName, s.mainSymbol, s.mainAmount, uint64(s.time))
+	if !ok || usd <= 0 {
+		return nil
+	}
+	s.valueUsd = usd
+	s.priceUsd = usd / s.tokenAmount
+	return s
+}
+
+func (e *engine) handle(event *swap) {
+	if !event.signal {
+		e.prices[event.token] = event.priceUsd
+		if e.params.Start == 0 || event.time >= e.params.Start {
+			e.evaluateExit(event)
+		}
+		e.mark()
+		return
+	}
+
+	e.result.Summary.SignalCount++
+	if event.side == SideBuy {
+		e.buy(event)
+	} else {
+		e.mirrorSell(event)
+	}
+	e.mark()
+}
+
+func (e *engine) skip(reason string) {
+	e.result.Summary.Skipped[reason]++
+}
+
+func (e *engine) buy(event *swap) {
+	if _, ok := e.positions[event.token]; ok {
+		e.skip(SkipHolding)
+		return
+	}
+	switch e.params.EntryFilter {
+	case EntryFirstBuy:
+		if !event.firstBuy {
+			e.skip(SkipFiltered)
+			return
+		}
+	case EntryMinBuyUsd:
+		if event.valueUsd < e.params.EntryValue {
+			e.skip(SkipFiltered)
+			return
+		}
+	}
+	price := e.prices[event.token]
+	if price <= 0 {
+		e.skip(SkipNoPrice)
+		return
+	}
+
+	sizeUsd, err := e.size(event)
+	if err != nil || sizeUsd <= 0 {
+		e.skip(SkipSizeError)
+		return
+	}
+	sizeUsd = math.Min(sizeUsd, e.cash-e.params.GasUsd)
+	if sizeUsd <= 0 {
+		e.skip(SkipNoCash)
+		return
+	}
+
+	fillPrice := price * (1 + e.params.Slippage)
+	amount := sizeUsd / fillPrice
+	e.cash -= sizeUsd + e.params.GasUsd
+	e.result.Summary.GasUsd += e.params.GasUsd
+	trade := &Trade{
+		FollowAddress: event.address,
+		TokenAddress:  event.token,
+		Symbol:        event.symbol,
+		EntryTime:     event.time,
+		EntryPriceUsd: fillPrice,
+		CostUsd:       sizeUsd + e.params.GasUsd,
+	}
+	e.positions[event.token] = &position{trade: trade, amount: amount, buyGas: e.params.GasUsd}
+	e.result.Trades = append(e.result.Trades, trade)
+	e.result.Summary.TradeCount++
+	e.fill(event, SideBuy, "follow_buy", amount, fillPrice)
+}
+
+// size 按历史数据计算买入金额，参数为 0 时使用全局配置
+func (e *engine) size(event *swap) (float64, error) {
+	cfg := config.CFG.Sizing
+	name := e.params.SizingStrategy
+	if name == "" && CheckSizing(cfg.Strategy) && cfg.Strategy != sizing.StrategyEachSellAmount {
+		name = cfg.Strategy
+	}
+	value := e.params.SizingValue
+	switch name {
+	case "", sizing.StrategyFixedUsd:
+		return valueOr(value, cfg.FixedUsd), nil
+	case sizing.StrategyProportional:
+		return event.valueUsd * valueOr(value, cfg.ProportionalRatio), nil
+	case sizing.StrategyEquityPercent:
+		return e.equity() * valueOr(value, cfg.EquityPercent), nil
+	case sizing.StrategyEachSellAmount:
+		price := util.GetMainTokenPriceInDate(e.params.ChainName, event.time)
+		if value <= 0 || price <= 0 {
+			return 0, fmt.Errorf("each sell amount or main token price unknown")
+		}
+		return value * price, nil
+	default:
+		return 0, fmt.Errorf("unknown sizing strategy %s", name)
+	}
+}
+
+func (e *engine) mirrorSell(event *swap) {
+	p, ok := e.positions[event.token]
+	if !ok || p.trade.FollowAddress != event.address {
+		e.skip(SkipNotFollowing)
+		return
+	}
+	e.sell(event, p, event.sellFraction, ReasonMirrorSell)
+}
+
+// evaluateExit 每次代币有新成交价时按退出策略检查持仓
+func (e *engine) evaluateExit(event *swap) {
+	p, ok := e.positions[event.token]
+	if !ok {
+		return
+	}
+	followTrade := &model.FollowTrade{
+		WalletAddressSellAmount: p.trade.CostUsd - p.buyGas,
+		WalletAddressBuyGas:     p.buyGas,
+		Proceeds:                p.trade.ProceedsUsd,
+	}
+	if p.principal {
+		followTrade.IsSellPrincipal = 1
+	}
+	value := p.amount * event.priceUsd * (1 - e.params.Slippage)
+	instructions := e.strategy.Evaluate(&exitstrategy.Position{FollowTrade: followTrade, Amount: p.amount},
+		&exitstrategy.Quote{Value: value}, e.params.GasUsd)
+
+	sold := 0.0
+	for _, instruction := range instructions {
+		if sold >= fullExitFraction || e.positions[event.token] == nil {
+			break
+		}
+		e.sell(event, p, math.Min(instruction.Fraction/(1-sold), 1), instruction.Reason)
+		sold += instruction.Fraction
+		if instruction.Principal {
+			p.principal = true
+		}
+	}
+}
+
+func (e *engine) sell(event *swap, p *position, fraction float64, reason string) {
+	price := e.prices[event.token]
+	if price <= 0 || fraction <= 0 {
+		return
+	}
+	amount := p.amount
+	if fraction < fullExitFraction {
+		amount = p.amount * fraction
+	}
+	fillPrice := price * (1 - e.params.Slippage)
+	proceeds := amount*fillPrice - e.params.GasUsd
+	e.cash += proceeds
+	e.result.Summary.GasUsd += e.params.GasUsd
+	p.amount -= amount
+	p.trade.ProceedsUsd += proceeds
+	e.fill(event, SideSell, reason, amount, fillPrice)
+
+	if fraction >= fullExitFraction {
+		delete(e.positions, event.token)
+		p.trade.ExitTime = event.time
+		p.trade.PnlUsd = p.trade.ProceedsUsd - p.trade.CostUsd
+		e.result.Summary.ClosedCount++
+		if p.trade.PnlUsd > 0 {
+			e.result.Summary.WinCount++
+		}
+		e.result.Summary.RealizedPnlUsd += p.trade.PnlUsd
+	}
+}
+
+func (e *engine) fill(event *swap, side, reason string, amount, price float64) {
+	e.result.Fills = append(e.result.Fills, &Fill{
+		Time:          event.time,
+		Side:          side,
+		Reason:        reason,
+		FollowAddress: event.address,
+		FollowTxHash:  event.txHash,
+		TokenAddress:  event.token,
+		Symbol:        event.symbol,
+		Amount:        amount,
+		PriceUsd:      price,
+		ValueUsd:      amount * price,
+		GasUsd:        e.params.GasUsd,
+	})
+	e.record(event.time)
+}
+
+// equity 现金加上持仓按最新成交价的估值
+func (e *engine) equity() float64 {
+	equity := e.cash
+	for token, p := range e.positions {
+		equity += p.amount * e.prices[token]
+	}
+	return equity
+}
+
+// mark 每个事件后更新最大回撤
+func (e *engine) mark() {
+	equity := e.equity()
+	if equity > e.peak {
+		e.peak = equity
+	}
+	if e.peak > 0 {
+		e.result.Summary.MaxDrawdown = math.Max(e.result.Summary.MaxDrawdown, (e.peak-equity)/e.peak)
+	}
+}
+
+func (e *engine) record(time int64) {
+	e.result.EquityCurve = append(e.result.EquityCurve, &EquityPoint{Time: time, EquityUsd: e.equity(), CashUsd: e.cash})
+}
+
+func (e *engine) finish(events []*swap) {
+	summary := e.result.Summary
+	for token, p := range e.positions {
+		p.trade.Open = true
+		p.trade.HoldingUsd = p.amount * e.prices[token]
+		p.trade.PnlUsd = p.trade.ProceedsUsd + p.trade.HoldingUsd - p.trade.CostUsd
+	}
+	if len(events) > 0 {
+		e.record(events[len(events)-1].time)
+	}
+	summary.FinalEquityUsd = e.equity()
+	summary.PnlUsd = summary.FinalEquityUsd - summary.InitialUsd
+	summary.Return = summary.PnlUsd / summary.InitialUsd
+	if summary.ClosedCount > 0 {
+		summary.WinRate = float64(summary.WinCount) / float64(summary.ClosedCount)
+	}
+}
+
+func valueOr(v, defaultValue float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return defaultValue
+}
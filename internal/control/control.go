@@ -0,0 +1,299 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-money/config"
+	"smart-money/internal/sizing"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/oklink"
+)
+
+const (
+	ActionBuy  = "buy"
+	ActionSell = "sell"
+	// ActionLiquidate 停止交易后的清仓卖出
+	ActionLiquidate = "liquidate"
+
+	SourceManual = "manual"
+
+	BreakerDailyLoss    = "daily_loss"
+	BreakerSwapFailures = "swap_failures"
+	BreakerRpcErrors    = "rpc_errors"
+	BreakerOklinkErrors = "oklink_errors"
+
+	defaultErrorWindow      = 300
+	defaultErrorMinRequests = 20
+)
+
+// ErrHalted 交易已停止
+var ErrHalted = errors.New("trading halted")
+
+var (
+	lock  sync.Mutex
+	state = new(model.TradingControl)
+	hooks []func(state model.TradingControl)
+
+	swapFailures int
+	rpcErrors    = new(errorWindow)
+	oklinkErrors = new(errorWindow)
+)
+
+// Init 从数据库加载交易开关，并统计 rpc 和 oklink 的错误率
+func Init() error {
+	s, err := model.GetTradingControl()
+	if err != nil {
+		return fmt.Errorf("get trading control error: %v", err)
+	}
+	lock.Lock()
+	state = s
+	lock.Unlock()
+	if s.Halted == 1 {
+		log.Warnf("trading halted by %s since %s: %s", s.Source, time.Unix(s.HaltedAt, 0).Format(time.DateTime), s.Reason)
+	}
+
+	eth.SetResultHook(func(err error) {
+		checkErrorRate(rpcErrors, err, config.CFG.Control.MaxRpcErrorRate, BreakerRpcErrors)
+	})
+	oklink.SetResultHook(func(err error) {
+		checkErrorRate(oklinkErrors, err, config.CFG.Control.MaxOklinkErrorRate, BreakerOklinkErrors)
+	})
+	return nil
+}
+
+// OnHalt 注册停止交易后的回调，比如清仓
+func OnHalt(fn func(state model.TradingControl)) {
+	lock.Lock()
+	defer lock.Unlock()
+	hooks = append(hooks, fn)
+}
+
+// Get 当前的交易开关
+func Get() model.TradingControl {
+	lock.Lock()
+	defer lock.Unlock()
+	return *state
+}
+
+// Allow 检查是否允许发送交易。停止后不再买入，卖出仍然允许以便止损和清仓，
+// 只有 rpc 或 oklink 熔断时普通卖出也暂停，这时发出的交易大概率失败，清仓不暂停，失败后重试
+func Allow(action string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if state.Halted == 0 || action == ActionLiquidate {
+		return nil
+	}
+	if action == ActionSell && state.Source != BreakerRpcErrors && state.Source != BreakerOklinkErrors {
+		return nil
+	}
+	return fmt.Errorf("%w by %s: %s", ErrHalted, state.Source, state.Reason)
+}
+
+// Halt 停止买入并持久化，liquidate 为 true 时卖出所有未卖出的跟单
+func Halt(source, reason string, liquidate bool) error {
+	lock.Lock()
+	s := *state
+	s.Halted = 1
+	s.Source = source
+	s.Reason = reason
+	s.HaltedAt = time.Now().Unix()
+	if liquidate {
+		s.Liquidate = 1
+	}
+	if err := model.SaveTradingControl(&s); err != nil {
+		lock.Unlock()
+		return fmt.Errorf("save trading control error: %v", err)
+	}
+	state = &s
+	fns := hooks
+	lock.Unlock()
+
+	log.Warnf("trading halted by %s: %s, liquidate: %v", source, reason, liquidate)
+	for _, fn := range fns {
+		fn(s)
+	}
+	return nil
+}
+
+// Resume 恢复交易，同时清空熔断器的计数
+func Resume() error {
+	lock.Lock()
+	defer lock.Unlock()
+	s := *state
+	s.Halted = 0
+	s.Source = ""
+	s.Reason = ""
+	s.Liquidate = 0
+	s.HaltedAt = 0
+	if err := model.SaveTradingControl(&s); err != nil {
+		return fmt.Errorf("save trading control error: %v", err)
+	}
+	state = &s
+	swapFailures = 0
+	rpcErrors.reset()
+	oklinkErrors.reset()
+	log.Infof("trading resumed")
+	return nil
+}
+
+// FinishLiquidation 清仓完成后清除等待清仓的标记
+func FinishLiquidation() error {
+	lock.Lock()
+	defer lock.Unlock()
+	s := *state
+	s.Liquidate = 0
+	if err := model.SaveTradingControl(&s); err != nil {
+		return fmt.Errorf("save trading control error: %v", err)
+	}
+	state = &s
+	return nil
+}
+
+// trip 熔断器触发，已经停止时不覆盖原来的原因
+func trip(breaker, reason string) {
+	if Get().Halted == 1 {
+		return
+	}
+	if err := Halt(breaker, reason, config.CFG.Control.LiquidateOnTrip); err != nil {
+		log.Errorf("trip %s breaker error: %v", breaker, err)
+	}
+}
+
+// RecordSwap 记录实盘兑换结果，连续失败达到上限时熔断
+func RecordSwap(err error) {
+	maxFailures := config.CFG.Control.MaxSwapFailures
+	lock.Lock()
+	if err == nil {
+		swapFailures = 0
+		lock.Unlock()
+		return
+	}
+	swapFailures++
+	failures := swapFailures
+	lock.Unlock()
+
+	if maxFailures > 0 && failures >= maxFailures {
+		trip(BreakerSwapFailures, fmt.Sprintf("%d consecutive swaps failed, last error: %v", failures, err))
+	}
+}
+
+// CheckDailyLoss 当天实盘已实现亏损超过上限时熔断
+func CheckDailyLoss() {
+	maxLoss := config.CFG.Control.MaxDailyLossUsd
+	if maxLoss <= 0 {
+		return
+	}
+	pnl, err := DailyRealizedPnlUsd()
+	if err != nil {
+		log.Errorf("CheckDailyLoss: %v", err)
+		return
+	}
+	if -pnl >= maxLoss {
+		trip(BreakerDailyLoss, fmt.Sprintf("daily realized loss %.2f usd reached %.2f usd", -pnl, maxLoss))
+	}
+}
+
+// DailyRealizedPnlUsd 当天实盘卖出的已实现盈亏，成本按卖出数量占买入数量的比例分摊
+func DailyRealizedPnlUsd() (float64, error) {
+	now := time.Now()
+	var exits []*model.FollowTradeExit
+	err := model.GetDB().Where("status = ? and created_at >= ?", model.FollowTradeExitStatusSuccess,
+		time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())).Find(&exits).Error
+	if err != nil {
+		return 0, fmt.Errorf("get follow trade exits error: %v", err)
+	}
+
+	followTrades := make(map[uint]*model.FollowTrade)
+	pnls := make(map[string]float64)
+	for _, exit := range exits {
+		followTrade, ok := followTrades[exit.FollowTradeID]
+		if !ok {
+			followTrade = new(model.FollowTrade)
+			if err = model.GetDB().First(followTrade, exit.FollowTradeID).Error; err != nil {
+				return 0, fmt.Errorf("get follow trade %d error: %v", exit.FollowTradeID, err)
+			}
+			followTrades[exit.FollowTradeID] = followTrade
+		}
+		if followTrade.IsPaper == 1 || followTrade.WalletAddressBuyAmount <= 0 {
+			continue
+		}
+		cost := (followTrade.WalletAddressSellAmount + followTrade.WalletAddressBuyGas) * exit.SellAmount / followTrade.WalletAddressBuyAmount
+		pnls[followTrade.ChainName] += exit.ReceiveAmount - exit.Gas - cost
+	}
+
+	var total float64
+	for chainName, pnl := range pnls {
+		price, err := sizing.MainTokenPriceUsd(chainName)
+		if err != nil {
+			return 0, err
+		}
+		total += pnl * price
+	}
+	return total, nil
+}
+
+func checkErrorRate(w *errorWindow, err error, maxRate float64, breaker string) {
+	cfg := config.CFG.Control
+	window := cfg.ErrorWindow
+	if window <= 0 {
+		window = defaultErrorWindow
+	}
+	minRequests := cfg.ErrorMinRequests
+	if minRequests <= 0 {
+		minRequests = defaultErrorMinRequests
+	}
+
+	total, failed := w.add(time.Duration(window)*time.Second, err != nil)
+	if maxRate <= 0 || total < minRequests {
+		return
+	}
+	if rate := float64(failed) / float64(total); rate > maxRate {
+		trip(breaker, fmt.Sprintf("%s error rate %.2f in last %ds over %.2f, last error: %v",
+			strings.TrimSuffix(breaker, "_errors"), rate, window, maxRate, err))
+	}
+}
+
+// errorWindow 最近一段时间的请求结果
+type errorWindow struct {
+	lock    sync.Mutex
+	results []windowResult
+}
+
+type windowResult struct {
+	at     time.Time
+	failed bool
+}
+
+// add 记录一次请求，返回窗口内的请求数和失败数
+func (w *errorWindow) add(window time.Duration, failed bool) (int, int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := time.Now()
+	w.results = append(w.results, windowResult{at: now, failed: failed})
+
+	start := 0
+	for start < len(w.results) && now.Sub(w.results[start].at) > window {
+		start++
+	}
+	w.results = w.results[start:]
+
+	failedCount := 0
+	for _, r := range w.results {
+		if r.failed {
+			failedCount++
+		}
+	}
+	return len(w.results), failedCount
+}
+
+func (w *errorWindow) reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.results = nil
+}
@@ -0,0 +1,281 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"smart-money/config"
+	"smart-money/internal/analysis"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/oklink"
+	"smart-money/pkg/util"
+)
+
+var (
+	consensusJobLock sync.Mutex
+)
+
+type watchAddress struct {
+	chainName string
+	address   string
+}
+
+// ConsensusJob 监控关注地址和排行榜地址的买入，K 个地址在窗口 T 内买入同一代币时生成共识信号
+func ConsensusJob() error {
+	if !consensusJobLock.TryLock() {
+		return nil
+	}
+	defer consensusJobLock.Unlock()
+
+	cfg := config.CFG.Consensus
+	if cfg.MinAddresses <= 1 || cfg.WindowSeconds <= 0 {
+		return fmt.Errorf("ConsensusJob: invalid config, min addresses: %d, window seconds: %d", cfg.MinAddresses, cfg.WindowSeconds)
+	}
+
+	addresses, err := getWatchAddresses()
+	if err != nil {
+		return fmt.Errorf("ConsensusJob: get watch addresses error: %v", err)
+	}
+
+	since := time.Now().Unix() - cfg.WindowSeconds
+	for _, wa := range addresses {
+		if err = recordAddressBuyEvents(wa, since); err != nil {
+			log.Errorf("ConsensusJob: record address %s buy events error: %v", wa.address, err)
+			continue
+		}
+	}
+
+	signals, err := detectConsensusSignals(since)
+	if err != nil {
+		return fmt.Errorf("ConsensusJob: detect consensus signals error: %v", err)
+	}
+
+	if !cfg.FollowTrigger {
+		return nil
+	}
+	for _, signal := range signals {
+		if err = followConsensusSignal(signal); err != nil {
+			log.Errorf("ConsensusJob: follow consensus signal %d error: %v", signal.ID, err)
+		}
+	}
+	return nil
+}
+
+func getWatchAddresses() ([]*watchAddress, error) {
+	var followAddresses []*model.FollowAddress
+	err := model.GetDB().Where("status = ?", model.FollowAddressStatusNormal).Find(&followAddresses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[watchAddress]bool)
+	var addresses []*watchAddress
+	add := func(chainName, address string) {
+		wa := watchAddress{chainName: chainName, address: strings.ToLower(address)}
+		if seen[wa] {
+			return
+		}
+		seen[wa] = true
+		addresses = append(addresses, &wa)
+	}
+
+	for _, followAddress := range followAddresses {
+		add(followAddress.ChainName, followAddress.Address)
+	}
+
+	cfg := config.CFG.Consensus
+	if cfg.LeaderboardTopN > 0 {
+		days := cfg.LeaderboardDays
+		if days <= 0 {
+			days = 30
+		}
+		entries, err := analysis.Leaderboard(time.Now().AddDate(0, 0, -days).Unix(), cfg.LeaderboardTopN)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			add(entry.ChainName, entry.Address)
+		}
+	}
+
+	return addresses, nil
+}
+
+// recordAddressBuyEvents 记录地址在窗口内收到非主流币的交易，当作买入
+func recordAddressBuyEvents(wa *watchAddress, since int64) error {
+	resp, err := oklink.Api.GetToken20TransactionListByAddress(wa.chainName, wa.address, 1, 20)
+	if err != nil {
+		return err
+	}
+	if len(resp.Data) == 0 {
+		return nil
+	}
+
+	for _, tx := range resp.Data[0].TransactionLists {
+		if tx.State != "success" || !strings.EqualFold(tx.To, wa.address) || util.IsMainToken(tx.TransactionSymbol) {
+			continue
+		}
+		ts, _ := strconv.Atoi(tx.TransactionTime)
+		txTime := util.TxTime(uint64(ts)).Unix()
+		if txTime < since {
+			// 列表按时间倒序
+			break
+		}
+
+		var count int64
+		err = model.GetDB().Model(&model.AddressBuyEvent{}).Where("tx_hash = ? and address = ?", tx.TxId, wa.address).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err = model.CreateAddressBuyEvent(&model.AddressBuyEvent{
+			ChainName:    wa.chainName,
+			Address:      wa.address,
+			TokenAddress: strings.ToLower(tx.TokenContractAddress),
+			Symbol:       tx.TransactionSymbol,
+			Amount:       tx.Amount,
+			TxHash:       tx.TxId,
+			TxTime:       txTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func detectConsensusSignals(since int64) ([]*model.ConsensusSignal, error) {
+	cfg := config.CFG.Consensus
+	db := model.GetDB()
+
+	var events []*model.AddressBuyEvent
+	if err := db.Where("tx_time >= ?", since).Order("tx_time asc").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	type key struct{ chainName, tokenAddress string }
+	grouped := make(map[key][]*model.AddressBuyEvent)
+	for _, event := range events {
+		if event.TokenAddress == "" {
+			continue
+		}
+		k := key{event.ChainName, event.TokenAddress}
+		grouped[k] = append(grouped[k], event)
+	}
+
+	var signals []*model.ConsensusSignal
+	for k, es := range grouped {
+		// 每个地址只取窗口内的第一笔买入
+		firstBuys := make(map[string]*model.AddressBuyEvent)
+		for _, e := range es {
+			if _, ok := firstBuys[e.Address]; !ok {
+				firstBuys[e.Address] = e
+			}
+		}
+		if len(firstBuys) < cfg.MinAddresses {
+			continue
+		}
+
+		// 窗口内已经发过信号的不重复发
+		existSignal := new(model.ConsensusSignal)
+		err := db.Where("chain_name = ? and token_address = ? and last_buy_time >= ?", k.chainName, k.tokenAddress, since).First(existSignal).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if existSignal.ID > 0 {
+			continue
+		}
+
+		buys := make([]*model.AddressBuyEvent, 0, len(firstBuys))
+		for _, e := range firstBuys {
+			buys = append(buys, e)
+		}
+		sort.Slice(buys, func(i, j int) bool { return buys[i].TxTime < buys[j].TxTime })
+
+		var addresses, txHashes []string
+		for _, e := range buys {
+			addresses = append(addresses, e.Address)
+			txHashes = append(txHashes, e.TxHash)
+		}
+
+		signal := &model.ConsensusSignal{
+			ChainName:     k.chainName,
+			TokenAddress:  k.tokenAddress,
+			Symbol:        buys[0].Symbol,
+			AddressCount:  len(buys),
+			Addresses:     strings.Join(addresses, ","),
+			TxHashes:      strings.Join(txHashes, ","),
+			FirstBuyTime:  buys[0].TxTime,
+			LastBuyTime:   buys[len(buys)-1].TxTime,
+			WindowSeconds: cfg.WindowSeconds,
+			Status:        model.ConsensusSignalStatusNew,
+		}
+		if err = model.CreateConsensusSignal(signal); err != nil {
+			return nil, err
+		}
+		log.Infof("ConsensusJob: %d addresses bought %s(%s) on %s within %ds", signal.AddressCount, signal.Symbol,
+			signal.TokenAddress, signal.ChainName, cfg.WindowSeconds)
+		signals = append(signals, signal)
+	}
+
+	return signals, nil
+}
+
+func followConsensusSignal(signal *model.ConsensusSignal) error {
+	// 以窗口内最后一个买入的地址作为跟单对象
+	lastBuy := new(model.AddressBuyEvent)
+	txHashes := strings.Split(signal.TxHashes, ",")
+	err := model.GetDB().Where("tx_hash = ?", txHashes[len(txHashes)-1]).First(lastBuy).Error
+	if err != nil {
+		finishConsensusSignal(signal.ID, err)
+		return err
+	}
+
+	fs := &followSignal{
+		ChainName:         signal.ChainName,
+		FollowAddress:     lastBuy.Address,
+		TxHash:            lastBuy.TxHash,
+		TxTime:            lastBuy.TxTime,
+		TokenAddress:      signal.TokenAddress,
+		Symbol:            signal.Symbol,
+		Amount:            lastBuy.Amount,
+		Side:              followSideBuy,
+		ConsensusSignalID: signal.ID,
+	}
+	if !config.CFG.Stream.Enable {
+		return executeFollowSignal(fs)
+	}
+	// 信号状态由执行者下单后更新
+	if err = publishFollowSignal(fs); err != nil {
+		finishConsensusSignal(signal.ID, err)
+	}
+	return err
+}
+
+// finishConsensusSignal 根据跟单结果更新共识信号状态
+func finishConsensusSignal(id uint, followErr error) {
+	signal := new(model.ConsensusSignal)
+	if err := model.GetDB().First(signal, id).Error; err != nil {
+		log.Errorf("ConsensusJob: get consensus signal %d error: %v", id, err)
+		return
+	}
+	if followErr != nil {
+		signal.Status = model.ConsensusSignalStatusFail
+		signal.FailReason = followErr.Error()
+	} else {
+		signal.Status = model.ConsensusSignalStatusFollowed
+	}
+	if err := model.SaveConsensusSignal(signal); err != nil {
+		log.Errorf("ConsensusJob: save consensus signal error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+)
+
+// delayFollowSignal 保存延迟跟单的信号再定时执行，服务重启后由 rearmDelayedFollowSignals 重新定时
+func delayFollowSignal(signal *followSignal, wait time.Duration) error {
+	// 信号可能被重复投递，同一笔交易只保存一次
+	var count int64
+	err := model.GetDB().Model(&model.DelayedFollowSignal{}).Where("follow_address = ? and tx_hash = ?", signal.FollowAddress, signal.TxHash).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("get delayed follow signal error: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+	delayed := &model.DelayedFollowSignal{
+		ChainName:     signal.ChainName,
+		FollowAddress: signal.FollowAddress,
+		TxHash:        signal.TxHash,
+		Data:          string(data),
+		ExecuteAt:     time.Now().Add(wait).UnixMilli(),
+		Status:        model.DelayedFollowSignalStatusPending,
+	}
+	if err = model.CreateDelayedFollowSignal(delayed); err != nil {
+		return fmt.Errorf("save delayed follow signal error: %v", err)
+	}
+	armDelayedFollowSignal(delayed.ID, wait)
+	return nil
+}
+
+// rearmDelayedFollowSignals 启动时重新定时还没执行的延迟跟单，已经到时间的马上执行
+func rearmDelayedFollowSignals() error {
+	var delayed []*model.DelayedFollowSignal
+	err := model.GetDB().Where("status = ?", model.DelayedFollowSignalStatusPending).Find(&delayed).Error
+	if err != nil {
+		return fmt.Errorf("get delayed follow signals error: %v", err)
+	}
+	for _, d := range delayed {
+		armDelayedFollowSignal(d.ID, time.Until(time.UnixMilli(d.ExecuteAt)))
+	}
+	if len(delayed) > 0 {
+		log.Infof("rearmed %d delayed follow signals", len(delayed))
+	}
+	return nil
+}
+
+func armDelayedFollowSignal(id uint, wait time.Duration) {
+	time.AfterFunc(wait, func() {
+		if err := runDelayedFollowSignal(id); err != nil {
+			log.Errorf("FollowAddressTradeBuyJob: delayed follow signal %d error: %v", id, err)
+		}
+	})
+}
+
+func runDelayedFollowSignal(id uint) error {
+	// 先抢占再执行，多个实例重新定时同一个信号时只执行一次
+	result := model.GetDB().Model(&model.DelayedFollowSignal{}).Where("id = ? and status = ?", id, model.DelayedFollowSignalStatusPending).
+		Update("status", model.DelayedFollowSignalStatusRunning)
+	if result.Error != nil {
+		return fmt.Errorf("claim error: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	delayed := new(model.DelayedFollowSignal)
+	if err := model.GetDB().First(delayed, id).Error; err != nil {
+		return fmt.Errorf("get delayed follow signal error: %v", err)
+	}
+	err := func() error {
+		signal := new(followSignal)
+		if err := json.Unmarshal([]byte(delayed.Data), signal); err != nil {
+			return fmt.Errorf("decode follow signal error: %v", err)
+		}
+		followAddress, err := getFollowAddress(signal.ChainName, signal.FollowAddress)
+		if err != nil {
+			return err
+		}
+		return runFollowSignal(signal, followAddress)
+	}()
+
+	delayed.Status = model.DelayedFollowSignalStatusDone
+	if err != nil {
+		delayed.FailReason = err.Error()
+	}
+	if saveErr := model.SaveDelayedFollowSignal(delayed); saveErr != nil {
+		log.Errorf("FollowAddressTradeBuyJob: save delayed follow signal %d error: %v", id, saveErr)
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package cron
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/util"
+)
+
+// followTxBlockTimeMs 关注地址交易的区块时间，查不到回执时用信号里的交易时间，内存池信号的交易时间是发现时间
+func followTxBlockTimeMs(signal *followSignal) int64 {
+	fallback := util.TxTime(uint64(signal.TxTime)).UnixMilli()
+	if signal.TxHash == "" {
+		return fallback
+	}
+	receipt, err := eth.Client.GetEthClient().TransactionReceipt(context.Background(), common.HexToHash(signal.TxHash))
+	if err != nil {
+		log.Debugf("followTxBlockTimeMs: get tx %s receipt error: %v", signal.TxHash, err)
+		return fallback
+	}
+	header, err := eth.Client.GetEthClient().HeaderByNumber(context.Background(), receipt.BlockNumber)
+	if err != nil {
+		log.Debugf("followTxBlockTimeMs: get block %v header error: %v", receipt.BlockNumber, err)
+		return fallback
+	}
+	return int64(header.Time) * 1000
+}
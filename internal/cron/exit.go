@@ -0,0 +1,428 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"smart-money/internal/control"
+	"smart-money/internal/exchange"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/oklink"
+	"smart-money/pkg/util"
+)
+
+const (
+	exitReasonMirrorSell = "mirror_sell"
+	exitReasonKillSwitch = "kill_switch"
+	exitReasonManual     = "manual"
+	exitReasonFollowExit = "follow_exit"
+
+	followExitGracePeriod = 10 * time.Minute
+
+	// 卖出比例达到 fullExitFraction 时直接清仓
+	fullExitFraction = 0.99
+)
+
+// followTradeLockStripes 跟单锁的数量，不同跟单可能共用一把锁，持有锁时不能再锁其他跟单
+const followTradeLockStripes = 64
+
+// followTradeLocks 同一跟单的卖出串行执行，跟随卖出和风控可能同时触发，按 id 取模固定数量的锁，不随跟单增长
+var followTradeLocks [followTradeLockStripes]sync.Mutex
+
+func lockFollowTrade(id uint) func() {
+	mu := &followTradeLocks[id%followTradeLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// mirrorSell 关注地址卖出时，按其卖出的持仓比例卖出对应的跟单
+func mirrorSell(signal *followSignal) error {
+	var followTrades []*model.FollowTrade
+	err := model.GetDB().Where("chain_name = ? and lower(follow_address) = ? and lower(buy_token_address) = ? and status = ?",
+		signal.ChainName, strings.ToLower(signal.FollowAddress), strings.ToLower(signal.TokenAddress), model.FollowTradeStatusSuccess).
+		Find(&followTrades).Error
+	if err != nil {
+		return fmt.Errorf("mirrorSell: get follow trades error: %v", err)
+	}
+	if len(followTrades) == 0 {
+		return nil
+	}
+
+	fraction, err := followedSellFraction(signal)
+	if err != nil {
+		return fmt.Errorf("mirrorSell: get sell fraction error: %v", err)
+	}
+	log.Infof("mirrorSell: address %s sold %.4f of %s in tx %s", signal.FollowAddress, fraction, signal.TokenAddress, signal.TxHash)
+
+	var errs []error
+	for _, followTrade := range followTrades {
+		if _, err = sellFollowTrade(followTrade, fraction, exitReasonMirrorSell, signal.TxHash); err != nil {
+			errs = append(errs, fmt.Errorf("mirrorSell: follow trade %d: %v", followTrade.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// followedSellFraction 关注地址卖出数量占卖出前持仓的比例
+func followedSellFraction(signal *followSignal) (float64, error) {
+	soldDf, err := decimal.NewFromString(signal.Amount)
+	if err != nil {
+		return 0, err
+	}
+
+	holdingDf, err := addressHolding(signal.ChainName, signal.FollowAddress, signal.TokenAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	total := soldDf.Add(holdingDf)
+	if total.LessThanOrEqual(decimal.Zero) {
+		return 1, nil
+	}
+	fraction, _ := soldDf.Div(total).Float64()
+	return math.Min(fraction, 1), nil
+}
+
+// addressHolding 地址持有的代币数量(已除以精度)
+func addressHolding(chainName, address, tokenAddress string) (decimal.Decimal, error) {
+	balanceResp, err := oklink.Api.GetAddressBalance(chainName, address, tokenAddress, 1, 1)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if len(balanceResp.Data) == 0 || len(balanceResp.Data[0].TokenList) == 0 {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(balanceResp.Data[0].TokenList[0].HoldingAmount)
+}
+
+// followAddressExited 关注地址已经清仓时卖出全部持仓。
+// 内存池信号跟单时关注地址的买入可能还没上链，买入后 followExitGracePeriod 内不检查
+func followAddressExited(followTrade *model.FollowTrade) (bool, error) {
+	if time.Since(time.Unix(followTrade.WalletAddressBuyTime, 0)) < followExitGracePeriod {
+		return false, nil
+	}
+	holdingDf, err := addressHolding(followTrade.ChainName, followTrade.FollowAddress, followTrade.BuyTokenAddress)
+	if err != nil {
+		return false, fmt.Errorf("get follow address balance error: %v", err)
+	}
+	if holdingDf.GreaterThan(decimal.Zero) {
+		return false, nil
+	}
+	log.Infof("followAddressExited: address %s exited %s, exit follow trade %d", followTrade.FollowAddress,
+		followTrade.BuySymbol, followTrade.ID)
+	exit, err := sellFollowTrade(followTrade, 1, exitReasonFollowExit, "")
+	if err != nil {
+		return false, err
+	}
+	return exit != nil, nil
+}
+
+// ExitFollowTrade 手动卖出跟单的全部持仓
+func ExitFollowTrade(id uint) (*model.FollowTradeExit, error) {
+	followTrade := new(model.FollowTrade)
+	if err := model.GetDB().First(followTrade, id).Error; err != nil {
+		return nil, err
+	}
+	if followTrade.Status != model.FollowTradeStatusSuccess {
+		return nil, fmt.Errorf("follow trade %d status %d is not open", id, followTrade.Status)
+	}
+	exit, err := sellFollowTrade(followTrade, 1, exitReasonManual, "")
+	if err != nil {
+		return exit, err
+	}
+	if exit == nil {
+		return nil, fmt.Errorf("follow trade %d has no balance to sell", id)
+	}
+	return exit, nil
+}
+
+// sellFollowTrade 卖出跟单钱包当前持仓的 fraction 部分，每次卖出都记录一条 FollowTradeExit，没有可卖的持仓时返回 nil
+func sellFollowTrade(followTrade *model.FollowTrade, fraction float64, reason, followTxHash string) (exit *model.FollowTradeExit, err error) {
+	unlock := lockFollowTrade(followTrade.ID)
+	defer unlock()
+
+	// 等锁期间其他卖出可能已经更新了跟单
+	if err = model.GetDB().First(followTrade, followTrade.ID).Error; err != nil {
+		return nil, fmt.Errorf("get follow trade error: %v", err)
+	}
+	if followTrade.Status != model.FollowTradeStatusSuccess {
+		return nil, nil
+	}
+	// rpc 或 oklink 熔断时不卖出，也不记录失败，清仓除外
+	action := control.ActionSell
+	if reason == exitReasonKillSwitch {
+		action = control.ActionLiquidate
+	}
+	if err = control.Allow(action); err != nil {
+		return nil, err
+	}
+
+	balance, err := followTradeBalance(followTrade)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() <= 0 {
+		return nil, nil
+	}
+
+	unit := decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))
+	balanceDf := decimal.NewFromBigInt(balance, 0)
+	sellRawDf := balanceDf
+	if fraction < fullExitFraction {
+		sellRawDf = balanceDf.Mul(decimal.NewFromFloat(fraction)).Floor()
+	}
+	if sellRawDf.LessThanOrEqual(decimal.Zero) {
+		return nil, nil
+	}
+
+	exit = &model.FollowTradeExit{
+		FollowTradeID:       followTrade.ID,
+		ChainName:           followTrade.ChainName,
+		WalletAddress:       followTrade.WalletAddress,
+		TokenAddress:        followTrade.BuyTokenAddress,
+		Reason:              reason,
+		FollowAddressTxHash: followTxHash,
+		Fraction:            fraction,
+		Status:              model.FollowTradeExitStatusSuccess,
+		ExitTime:            time.Now().Unix(),
+	}
+	exit.SellAmount, _ = sellRawDf.Div(unit).Float64()
+	defer func() {
+		if err != nil && followTrade.Status == model.FollowTradeStatusExiting {
+			// 结果未知，由 ReconcileFollowTradeJob 确认后记录卖出
+			log.Errorf("sellFollowTrade: follow trade %d sell tx %s pending reconcile: %v", followTrade.ID, followTrade.ExitTxHash, err)
+			return
+		}
+		if err != nil {
+			exit.Status = model.FollowTradeExitStatusFail
+			exit.FailReason = err.Error()
+		}
+		if createErr := model.CreateFollowTradeExit(exit); createErr != nil {
+			log.Errorf("sellFollowTrade: create follow trade exit error: %v", createErr)
+		} else if err == nil && followTrade.IsPaper == 0 {
+			// 卖出记录保存后再检查当天亏损
+			control.CheckDailyLoss()
+		}
+		notifyFollowTradeExit(followTrade, exit)
+	}()
+
+	wallet := new(model.MyWallet)
+	err = model.GetDB().Where("chain_name = ? and address = ?", followTrade.ChainName, followTrade.WalletAddress).First(wallet).Error
+	if err != nil {
+		return exit, fmt.Errorf("get wallet error: %v", err)
+	}
+	followAddress, err := getFollowAddress(followTrade.ChainName, followTrade.FollowAddress)
+	if err != nil {
+		return exit, err
+	}
+
+	mainToken := util.MainTokenInfo[followTrade.ChainName]
+	mainTokenUnit := decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal)))
+	swapRequest := &inch.SwapRequest{
+		FromTokenAddress: followTrade.BuyTokenAddress,
+		ToTokenAddress:   mainToken.ContractAddress,
+		Amount:           sellRawDf.BigInt().String(),
+		FromAddress:      followTrade.WalletAddress,
+		Slippage:         followSlippage(followAddress),
+	}
+	ex := exchange.NewExecutor(followTrade.ChainName, wallet.PrivateKey, swapRequest, followTrade.IsPaper == 1)
+
+	// 和兑换用同一个渠道报价
+	quote, err := ex.Quote()
+	if err != nil {
+		return exit, fmt.Errorf("get quote error: %v", err)
+	}
+	exit.ReceiveAmount, _ = decimal.NewFromBigInt(quote.ToAmount, 0).Div(mainTokenUnit).Float64()
+
+	// 卖出期间标记 exiting，卖出交易发出前失败或交易执行失败时回到 confirmed，其他情况由 ReconcileFollowTradeJob 恢复
+	followTrade.ExitingAmount = exit.SellAmount
+	followTrade.ExitingReceiveAmount = exit.ReceiveAmount
+	followTrade.ExitingReason = reason
+	followTrade.ExitTxHash = ""
+	if err = setFollowTradeStatus(followTrade, model.FollowTradeStatusExiting); err != nil {
+		return exit, err
+	}
+	defer func() {
+		if followTrade.Status != model.FollowTradeStatusExiting {
+			return
+		}
+		// 卖出交易已经发出但没等到结果时卖出可能成功，保持 exiting 由 ReconcileFollowTradeJob 按回执恢复
+		if followTrade.ExitTxHash != "" && !errors.Is(err, eth.ErrTxFailed) {
+			return
+		}
+		if restoreErr := setFollowTradeStatus(followTrade, model.FollowTradeStatusSuccess); restoreErr != nil {
+			log.Errorf("sellFollowTrade: follow trade %d: %v", followTrade.ID, restoreErr)
+		}
+	}()
+
+	// 检查是否授权
+	allowance, err := ex.CheckAllowance()
+	if err != nil {
+		return exit, fmt.Errorf("check allowance error: %v", err)
+	}
+	if allowance == "0" {
+		approveTx, err := ex.ApproveTransaction(true)
+		if err != nil {
+			return exit, fmt.Errorf("approve tx error: %v", err)
+		}
+		if _, err = ex.Wait(approveTx); err != nil {
+			return exit, fmt.Errorf("wait approve tx receipt error: %v", err)
+		}
+	}
+
+	// 授权期间可能已经熔断，发送兑换前再检查一次
+	if err = control.Allow(action); err != nil {
+		return exit, err
+	}
+	swapTx, err := ex.Swap()
+	exit.SwapProvider = ex.Provider()
+	if err != nil {
+		recordSwap(followTrade.IsPaper, err)
+		return exit, fmt.Errorf("swap error: %v", err)
+	}
+	exit.TxHash = swapTx.String()
+	followTrade.ExitTxHash = exit.TxHash
+	if saveErr := model.SaveFollowTrade(followTrade); saveErr != nil {
+		log.Errorf("sellFollowTrade: save follow trade %d exit tx error: %v", followTrade.ID, saveErr)
+	}
+	receipt, err := ex.Wait(swapTx)
+	recordSwap(followTrade.IsPaper, err)
+	if err != nil {
+		return exit, fmt.Errorf("wait swap tx receipt error: %v", err)
+	}
+	exit.Gas = receipt.Gas
+	if receipt.ToAmount != nil {
+		exit.ReceiveAmount, _ = decimal.NewFromBigInt(receipt.ToAmount, 0).Div(mainTokenUnit).Float64()
+	}
+
+	followTrade.ExitedAmount, _ = decimal.NewFromFloat(followTrade.ExitedAmount).Add(sellRawDf.Div(unit)).Float64()
+	followTrade.Proceeds, _ = decimal.NewFromFloat(followTrade.Proceeds).Add(decimal.NewFromFloat(exit.ReceiveAmount)).
+		Sub(decimal.NewFromFloat(exit.Gas)).Float64()
+	followTrade.Status = model.FollowTradeStatusSuccess
+	if sellRawDf.Equal(balanceDf) {
+		finishFollowTrade(followTrade)
+	}
+	if err = model.SaveFollowTrade(followTrade); err != nil {
+		return exit, fmt.Errorf("save follow trade error: %v", err)
+	}
+	log.Infof("sellFollowTrade: follow trade %d sold %v of %s, reason: %s, tx: %s", followTrade.ID, exit.SellAmount,
+		followTrade.BuySymbol, reason, exit.TxHash)
+	return exit, nil
+}
+
+// finishFollowTrade 结束跟单并记录已实现盈亏，没卖出的剩余持仓不计价
+func finishFollowTrade(followTrade *model.FollowTrade) {
+	followTrade.Status = model.FollowTradeStatusFinish
+	followTrade.RealizedPnl = followTrade.Proceeds - followTrade.Cost()
+	followTrade.ClosedAt = time.Now().Unix()
+}
+
+// closeFollowTrade 不卖出直接结束跟单，用于余额为 0 或价值太低不值得卖出的跟单，
+// 剩余持仓的数量和主流币估值记为未实现，不计入已实现盈亏
+func closeFollowTrade(followTrade *model.FollowTrade, dustAmount, dustValue float64) error {
+	unlock := lockFollowTrade(followTrade.ID)
+	defer unlock()
+
+	if err := model.GetDB().First(followTrade, followTrade.ID).Error; err != nil {
+		return fmt.Errorf("get follow trade error: %v", err)
+	}
+	if followTrade.Status != model.FollowTradeStatusSuccess {
+		return nil
+	}
+	finishFollowTrade(followTrade)
+	followTrade.DustAmount = dustAmount
+	followTrade.DustValue = dustValue
+	if err := model.SaveFollowTrade(followTrade); err != nil {
+		return fmt.Errorf("save follow trade error: %v", err)
+	}
+	return nil
+}
+
+// recordSwap 只统计实盘的兑换结果
+func recordSwap(isPaper int, err error) {
+	if isPaper == 0 {
+		control.RecordSwap(err)
+	}
+}
+
+// liquidating 清仓同时只执行一次
+var liquidating sync.Mutex
+
+// liquidateRetryInterval 清仓有失败时重试的间隔
+const liquidateRetryInterval = time.Minute
+
+// liquidateOpenTrades 卖出所有未卖出的跟单，全部成功后清除等待清仓的标记，
+// 有失败时隔 liquidateRetryInterval 重试，直到清仓完成或恢复交易
+func liquidateOpenTrades() {
+	if !liquidating.TryLock() {
+		return
+	}
+	defer liquidating.Unlock()
+	if control.Get().Liquidate == 0 {
+		return
+	}
+
+	var followTrades []*model.FollowTrade
+	err := model.GetDB().Where("status = ?", model.FollowTradeStatusSuccess).Find(&followTrades).Error
+	if err != nil {
+		log.Errorf("liquidateOpenTrades: get follow trades error: %v", err)
+		time.AfterFunc(liquidateRetryInterval, liquidateOpenTrades)
+		return
+	}
+	failed := 0
+	for _, followTrade := range followTrades {
+		if _, err = sellFollowTrade(followTrade, fullExitFraction, exitReasonKillSwitch, ""); err != nil {
+			failed++
+			log.Errorf("liquidateOpenTrades: sell follow trade %d error: %v", followTrade.ID, err)
+		}
+	}
+	log.Infof("liquidateOpenTrades: %d follow trades, %d failed", len(followTrades), failed)
+	if failed > 0 {
+		time.AfterFunc(liquidateRetryInterval, liquidateOpenTrades)
+		return
+	}
+	if err = control.FinishLiquidation(); err != nil {
+		log.Errorf("liquidateOpenTrades: %v", err)
+	}
+}
+
+// quoteMainToken 按兑换渠道的报价估算卖出 amount(未除以精度)个代币能收到的主流币，聚合器没有收录的代币用 dex 路由报价
+func quoteMainToken(chainName, tokenAddress string, amount *big.Int) (float64, *exchange.SwapQuote, error) {
+	mainToken := util.MainTokenInfo[chainName]
+	quote, err := exchange.Quote(chainName, &inch.SwapRequest{
+		FromTokenAddress: tokenAddress,
+		ToTokenAddress:   mainToken.ContractAddress,
+		Amount:           amount.String(),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	value, _ := decimal.NewFromBigInt(quote.ToAmount, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal)))).Float64()
+	return value, quote, nil
+}
+
+// followTradeBalance 跟单的持仓(未除以精度)，模拟盘没有链上余额，按买入数量减去已卖出数量计算
+func followTradeBalance(followTrade *model.FollowTrade) (*big.Int, error) {
+	if followTrade.IsPaper == 1 {
+		remaining := decimal.NewFromFloat(followTrade.WalletAddressBuyAmount).Sub(decimal.NewFromFloat(followTrade.ExitedAmount))
+		if remaining.LessThanOrEqual(decimal.Zero) {
+			return new(big.Int), nil
+		}
+		return remaining.Mul(decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))).Floor().BigInt(), nil
+	}
+
+	balance, err := eth.Client.GetTokenBalance(followTrade.BuyTokenAddress, followTrade.WalletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("get token balance error: %v", err)
+	}
+	return balance, nil
+}
@@ -0,0 +1,121 @@
+package cron
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"smart-money/internal/analysis"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+// defaultSlippage 关注地址没有配置滑点时使用的兑换滑点(百分比)
+const defaultSlippage = 20
+
+// followSlippage 关注地址配置的兑换滑点，未配置时使用默认值
+func followSlippage(followAddress *model.FollowAddress) float64 {
+	if followAddress.Slippage > 0 {
+		return followAddress.Slippage
+	}
+	return defaultSlippage
+}
+
+// splitTokens 解析逗号分隔的代币地址，统一转成小写
+func splitTokens(tokens string) map[string]bool {
+	result := make(map[string]bool)
+	for _, token := range strings.Split(tokens, ",") {
+		if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+			result[token] = true
+		}
+	}
+	return result
+}
+
+// signalFollowBuyUsd 关注地址这笔买入花费的美元，未知时返回 0
+func signalFollowBuyUsd(signal *followSignal) float64 {
+	payAmountDf, err := decimal.NewFromString(signal.PayAmount)
+	if err != nil {
+		return 0
+	}
+	payAmount, _ := payAmountDf.Float64()
+	usd, _ := analysis.QuoteUsd(signal.ChainName, signal.PaySymbol, payAmount, uint64(util.TxTime(uint64(signal.TxTime)).Unix()))
+	return usd
+}
+
+// filterFollowBuy 按关注地址的代币黑白名单和最小买入金额过滤，返回不跟单的原因，为空表示跟单
+func filterFollowBuy(followAddress *model.FollowAddress, signal *followSignal, followBuyUsd float64) string {
+	token := strings.ToLower(signal.TokenAddress)
+	if whitelist := splitTokens(followAddress.TokenWhitelist); len(whitelist) > 0 && !whitelist[token] {
+		return "token not in whitelist"
+	}
+	if splitTokens(followAddress.TokenBlacklist)[token] {
+		return "token in blacklist"
+	}
+	// 买入金额未知时无法判断，不过滤
+	if followAddress.MinFollowBuyUsd > 0 && followBuyUsd > 0 && followBuyUsd < followAddress.MinFollowBuyUsd {
+		return fmt.Sprintf("followed buy %.2f usd less than %.2f usd", followBuyUsd, followAddress.MinFollowBuyUsd)
+	}
+	return ""
+}
+
+// followBuyCapUsd 关注地址单笔和当天剩余的买入额度，返回 +Inf 表示不限制，模拟盘和实盘分开统计
+func followBuyCapUsd(followAddress *model.FollowAddress, paper bool) (float64, error) {
+	capUsd := math.Inf(1)
+	if followAddress.MaxTradeUsd > 0 {
+		capUsd = followAddress.MaxTradeUsd
+	}
+	if followAddress.MaxDailyUsd <= 0 {
+		return capUsd, nil
+	}
+
+	isPaper := 0
+	if paper {
+		isPaper = 1
+	}
+	now := time.Now()
+	var spent struct {
+		Total float64
+	}
+	err := model.GetDB().Model(&model.FollowTrade{}).Select("coalesce(sum(size_usd), 0) as total").
+		Where("chain_name = ? and lower(follow_address) = ? and status in ? and is_paper = ? and created_at >= ?",
+			followAddress.ChainName, strings.ToLower(followAddress.Address),
+			append([]int{model.FollowTradeStatusFinish}, model.FollowTradeOpenStatuses...), isPaper,
+			time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())).
+		Scan(&spent).Error
+	if err != nil {
+		return 0, fmt.Errorf("get daily spent error: %v", err)
+	}
+	return math.Min(capUsd, followAddress.MaxDailyUsd-spent.Total), nil
+}
+
+// checkPriceMove 跟单价格相对关注地址买入价的涨幅超过配置时返回错误，关注地址买入金额未知时不检查
+func checkPriceMove(followAddress *model.FollowAddress, signal *followSignal, followBuyUsd, sizeUsd, buyAmount float64) error {
+	if followAddress.MaxPriceMove <= 0 || followBuyUsd <= 0 || buyAmount <= 0 {
+		return nil
+	}
+	amountDf, err := decimal.NewFromString(signal.Amount)
+	if err != nil {
+		return nil
+	}
+	amount, _ := amountDf.Float64()
+	if amount <= 0 {
+		return nil
+	}
+
+	move := (sizeUsd/buyAmount)/(followBuyUsd/amount) - 1
+	if move > followAddress.MaxPriceMove {
+		return fmt.Errorf("price moved %.4f since followed buy, more than %.4f", move, followAddress.MaxPriceMove)
+	}
+	return nil
+}
+
+// followCopyWait 关注地址交易后还需要等待多久才跟单
+func followCopyWait(followAddress *model.FollowAddress, signal *followSignal) time.Duration {
+	if followAddress.CopyDelay <= 0 {
+		return 0
+	}
+	return time.Until(util.TxTime(uint64(signal.TxTime)).Add(time.Duration(followAddress.CopyDelay) * time.Second))
+}
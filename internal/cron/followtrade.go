@@ -6,13 +6,19 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/shopspring/decimal"
-	"golang.org/x/exp/rand"
 	"gorm.io/gorm"
+	"smart-money/config"
+	"smart-money/internal/allocator"
+	"smart-money/internal/control"
 	"smart-money/internal/exchange"
+	"smart-money/internal/exitstrategy"
+	"smart-money/internal/safety"
+	"smart-money/internal/sizing"
 	inch "smart-money/pkg/1inch"
 	"smart-money/pkg/eth"
 	"smart-money/pkg/log"
@@ -25,41 +31,45 @@ var (
 	followAddressTradeJobLock sync.Mutex
 )
 
-func FollowAddressTradeBuyJob() {
+// FollowAddressTradeBuyJob 检测关注地址的新买入。开启 stream 时只发布跟单信号，由执行者消费下单
+func FollowAddressTradeBuyJob() error {
 	if !followAddressTradeJobLock.TryLock() {
-		return
+		return nil
 	}
 	defer followAddressTradeJobLock.Unlock()
 
-	db := model.GetDB()
-
 	var followAddresses []*model.FollowAddress
-	err := db.Where("status = ?", model.FollowAddressStatusNormal).Find(&followAddresses).Error
+	err := model.GetDB().Where("status = ?", model.FollowAddressStatusNormal).Find(&followAddresses).Error
 	if err != nil {
-		log.Errorf("FollowAddressTradeBuyJob: get follow followAddress error: %v", err)
-		return
+		return fmt.Errorf("FollowAddressTradeBuyJob: get follow followAddress error: %v", err)
 	}
 
-	var wallets []*model.MyWallet
-	err = db.Where("status = ?", model.MyWalletStatusEnable).Find(&wallets).Error
-	if err != nil {
-		log.Errorf("FollowAddressTradeBuyJob: get my wallet error: %v", err)
-		return
-	}
-	if len(wallets) == 0 {
-		log.Errorf("FollowAddressTradeBuyJob: no wallet available")
-		return
+	// web3 配置的链扫块发现交易，其他链继续轮询 oklink
+	if config.CFG.Scanner.Enable {
+		var scanAddresses, pollAddresses []*model.FollowAddress
+		for _, followAddress := range followAddresses {
+			if followAddress.ChainName == config.CFG.Web3.ChainName {
+				scanAddresses = append(scanAddresses, followAddress)
+			} else {
+				pollAddresses = append(pollAddresses, followAddress)
+			}
+		}
+		if err = scanFollowAddresses(scanAddresses); err != nil {
+			log.Errorf("FollowAddressTradeBuyJob: scan follow address error: %v", err)
+		}
+		followAddresses = pollAddresses
 	}
 
 	for _, followAddress := range followAddresses {
-		if err = dealFollowAddress(wallets, followAddress); err != nil {
+		if err = dealFollowAddress(followAddress); err != nil {
 			log.Errorf("FollowAddressTradeBuyJob: deal follow address error: %v", err)
 			continue
 		}
 	}
+	return nil
 }
 
-func dealFollowAddress(wallets []*model.MyWallet, followAddress *model.FollowAddress) error {
+func dealFollowAddress(followAddress *model.FollowAddress) error {
 	// 查找最新一条
 	latestTx, err := oklink.Api.GetToken20TransactionListByAddress(followAddress.ChainName, followAddress.Address, 1, 1)
 	if err != nil {
@@ -101,7 +111,15 @@ func dealFollowAddress(wallets []*model.MyWallet, followAddress *model.FollowAdd
 		}
 		if util.IsMainToken(buyToken.Symbol) && !util.IsMainToken(sellToken.Symbol) {
 			log.Infof("FollowAddressTradeBuyJob: buy main token: %v", buyToken.Symbol)
-			return nil
+			return followAddressSell(followAddress, latestTx.Data[0].TransactionLists[0].TransactionTime, &followSignal{
+				ChainName:     followAddress.ChainName,
+				FollowAddress: followAddress.Address,
+				TxHash:        latestTxTxHash,
+				TokenAddress:  sellToken.TokenContractAddress,
+				Symbol:        sellToken.Symbol,
+				Amount:        sellToken.Amount,
+				Side:          followSideSell,
+			})
 		}
 
 		log.Infof("FollowAddressTradeBuyJob: address:%v buy token: %v, sell token: %v", followAddress.Address, buyToken, sellToken)
@@ -112,291 +130,617 @@ func dealFollowAddress(wallets []*model.MyWallet, followAddress *model.FollowAdd
 			return fmt.Errorf("FollowAddressTradeBuyJob: get lastest tx time error: %v", err)
 		}
 		followAddress.LastErc20TxTime = int64(latestTxTime)
-		if err = model.SaveFollowAddress(followAddress); err != nil {
-			return fmt.Errorf("FollowAddressTradeBuyJob: save follow address error: %v", err)
+
+		if config.CFG.Consensus.FollowTrigger {
+			log.Infof("FollowAddressTradeBuyJob: follow by consensus signal, skip single address buy: %v", latestTxTxHash)
+			if err = model.SaveFollowAddress(followAddress); err != nil {
+				return fmt.Errorf("FollowAddressTradeBuyJob: save follow address error: %v", err)
+			}
+			return nil
+		}
+
+		signal := &followSignal{
+			ChainName:     followAddress.ChainName,
+			FollowAddress: followAddress.Address,
+			TxHash:        latestTxTxHash,
+			TxTime:        int64(latestTxTime),
+			TokenAddress:  buyToken.TokenContractAddress,
+			Symbol:        buyToken.Symbol,
+			Amount:        buyToken.Amount,
+			Side:          followSideBuy,
+			PaySymbol:     sellToken.Symbol,
+			PayAmount:     sellToken.Amount,
 		}
 
-		tmpFollowTrade := new(model.FollowTrade)
-		err = model.GetDB().Where("buy_token_address = ? and status != ?", buyToken.TokenContractAddress, model.FollowTradeStatusFinish).First(tmpFollowTrade).Error
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("FollowAddressTradeBuyJob: get follow trade error: %v", err)
+		// 先发布再记录最新交易，发布失败下次还能重新检测到
+		if config.CFG.Stream.Enable {
+			if err = publishFollowSignal(signal); err != nil {
+				return fmt.Errorf("FollowAddressTradeBuyJob: publish follow signal error: %v", err)
+			}
+		}
+		if err = model.SaveFollowAddress(followAddress); err != nil {
+			return fmt.Errorf("FollowAddressTradeBuyJob: save follow address error: %v", err)
 		}
-		if tmpFollowTrade.ID > 0 {
-			log.Infof("FollowAddressTradeBuyJob: follow trade buy token:%v already exist", buyToken.TokenContractAddress)
+		if config.CFG.Stream.Enable {
 			return nil
 		}
 
-		followTrade := new(model.FollowTrade)
-		defer func() {
-			if err := model.CreateFollowTrade(followTrade); err != nil {
-				return
-			}
-		}()
+		return executeFollowSignal(signal)
+	}
 
-		followTrade.ChainName = followAddress.ChainName
-		followTrade.FollowAddress = followAddress.Address
-		followTrade.FollowAddressBuyTxHash = latestTxTxHash
-		followTrade.FollowAddressBuyTime = int64(latestTxTime)
-		followTrade.BuyTokenAddress = buyToken.TokenContractAddress
-		followTrade.BuySymbol = buyToken.Symbol
-		followTrade.Status = model.FollowTradeStatusSuccess
+	return nil
+}
 
-		buyAmountDf, err := decimal.NewFromString(buyToken.Amount)
-		if err != nil {
-			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Sprintf("buy amount parse error: %v", err)
-			return err
-		}
-		followTrade.FollowAddressBuyAmount, _ = buyAmountDf.Float64()
+// followAddressSell 记录关注地址的卖出交易，并跟随卖出持有的同一代币
+func followAddressSell(followAddress *model.FollowAddress, txTime string, signal *followSignal) error {
+	latestTxTime, err := strconv.Atoi(txTime)
+	if err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: get lastest tx time error: %v", err)
+	}
+	signal.TxTime = int64(latestTxTime)
 
-		var wallet *model.MyWallet
-		if len(wallets) > 1 {
-			wallet = wallets[rand.Int63n(int64(len(wallets)-1))]
-		} else {
-			wallet = wallets[0]
+	// 先发布再记录最新交易，发布失败下次还能重新检测到
+	if config.CFG.Stream.Enable {
+		if err = publishFollowSignal(signal); err != nil {
+			return fmt.Errorf("FollowAddressTradeBuyJob: publish follow signal error: %v", err)
 		}
+	}
+	followAddress.LastErc20TxHash = signal.TxHash
+	followAddress.LastErc20TxTime = signal.TxTime
+	if err = model.SaveFollowAddress(followAddress); err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: save follow address error: %v", err)
+	}
+	if config.CFG.Stream.Enable {
+		return nil
+	}
+	return executeFollowSignal(signal)
+}
 
-		followTrade.WalletAddress = wallet.Address
-		followTrade.WalletAddressSellAmount = wallet.EachSellAmount
+const (
+	followSideBuy  = "buy"
+	followSideSell = "sell"
+)
 
-		mainTokenDecimal := int64(util.MainTokenInfo[followAddress.ChainName].Decimal)
-		mainTokenAddress := util.MainTokenInfo[followAddress.ChainName].ContractAddress
-		walletSellMainTokenAmountDf := decimal.NewFromFloat(wallet.EachSellAmount).Mul(decimal.NewFromFloat(math.Pow(10, float64(mainTokenDecimal))))
-		quote, err := inch.Quote(followAddress.ChainName, util.MainTokenInfo[followAddress.ChainName].ContractAddress,
-			followTrade.BuyTokenAddress, walletSellMainTokenAmountDf.BigInt().Int64())
-		if err != nil {
-			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: get quote error: %v", err).Error()
-			return fmt.Errorf("FollowAddressTradeBuyJob: get quote error: %v", err)
+// followSignal 归一化后的"关注地址交易"事件，卖出时 TokenAddress 和 Amount 为卖出的代币和数量
+type followSignal struct {
+	ChainName         string `json:"chain_name"`
+	FollowAddress     string `json:"follow_address"`
+	TxHash            string `json:"tx_hash"`
+	TxTime            int64  `json:"tx_time"`
+	TokenAddress      string `json:"token_address"`
+	Symbol            string `json:"symbol"`
+	Amount            string `json:"amount"`
+	ConsensusSignalID uint   `json:"consensus_signal_id"`
+	// 为空时视为买入
+	Side string `json:"side"`
+	// 买入时关注地址付出的主流币和数量，未知时为空
+	PaySymbol string `json:"pay_symbol"`
+	PayAmount string `json:"pay_amount"`
+	// 收到信号的毫秒时间戳，用于统计跟单延迟
+	ReceivedAt int64 `json:"received_at"`
+}
+
+// dispatchFollowSignal 开启 stream 时发布跟单信号，否则直接下单
+func dispatchFollowSignal(signal *followSignal) error {
+	signal.ReceivedAt = time.Now().UnixMilli()
+	if config.CFG.Stream.Enable {
+		return publishFollowSignal(signal)
+	}
+	return executeFollowSignal(signal)
+}
+
+// executeFollowSignal 按跟单信号下单，关注地址配置了跟单延迟时到时间后再下单，由共识信号触发的同时更新信号状态
+func executeFollowSignal(signal *followSignal) error {
+	if signal.ReceivedAt == 0 {
+		signal.ReceivedAt = time.Now().UnixMilli()
+	}
+	notifyFollowSignal(signal)
+	followAddress, err := getFollowAddress(signal.ChainName, signal.FollowAddress)
+	if err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: %v", err)
+	}
+
+	// 延迟中的跟单保存到数据库后再确认消息，服务重启后重新定时
+	if wait := followCopyWait(followAddress, signal); wait > 0 {
+		log.Infof("FollowAddressTradeBuyJob: %s signal of %s tx %s delayed %v", signal.Side, signal.FollowAddress, signal.TxHash, wait)
+		return delayFollowSignal(signal, wait)
+	}
+	return runFollowSignal(signal, followAddress)
+}
+
+func runFollowSignal(signal *followSignal, followAddress *model.FollowAddress) error {
+	if signal.Side == followSideSell {
+		return mirrorSell(signal)
+	}
+
+	err := followBuy(signal, followAddress)
+	if signal.ConsensusSignalID > 0 {
+		finishConsensusSignal(signal.ConsensusSignalID, err)
+	}
+	return err
+}
+
+func followBuy(signal *followSignal, followAddress *model.FollowAddress) (err error) {
+	followBuyUsd := signalFollowBuyUsd(signal)
+	if reason := filterFollowBuy(followAddress, signal, followBuyUsd); reason != "" {
+		log.Infof("FollowAddressTradeBuyJob: skip token %s of %s: %s", signal.TokenAddress, signal.FollowAddress, reason)
+		return nil
+	}
+	// 停止交易后不再买入，也不记录跟单
+	if err = control.Allow(control.ActionBuy); err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: skip token %s of %s: %v", signal.TokenAddress, signal.FollowAddress, err)
+	}
+	// 余额、精度和 gas 都要从信号所在链读取，没有配置这条链的 rpc 时不跟单
+	client, err := eth.ClientOf(signal.ChainName)
+	if err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: skip token %s of %s: %v", signal.TokenAddress, signal.FollowAddress, err)
+	}
+	paper := config.CFG.Paper.Enable || followAddress.IsPaper == 1
+	isPaper := 0
+	if paper {
+		isPaper = 1
+	}
+
+	// 模拟盘和实盘分别去重，模拟盘的持仓不影响实盘跟单
+	tmpFollowTrade := new(model.FollowTrade)
+	err = model.GetDB().Where("buy_token_address = ? and status != ? and is_paper = ?", signal.TokenAddress, model.FollowTradeStatusFinish, isPaper).
+		First(tmpFollowTrade).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("FollowAddressTradeBuyJob: get follow trade error: %v", err)
+	}
+	if tmpFollowTrade.ID > 0 {
+		log.Infof("FollowAddressTradeBuyJob: follow trade buy token:%v already exist", signal.TokenAddress)
+		return nil
+	}
+
+	// 收到信号就保存跟单，之后每一步都先保存状态再执行，崩溃后启动时由 ReconcileFollowTradeJob 恢复
+	followTrade := new(model.FollowTrade)
+	followTrade.ChainName = signal.ChainName
+	followTrade.FollowAddress = signal.FollowAddress
+	followTrade.FollowAddressBuyTxHash = signal.TxHash
+	followTrade.FollowAddressBuyTime = signal.TxTime
+	followTrade.BuyTokenAddress = signal.TokenAddress
+	followTrade.BuySymbol = signal.Symbol
+	followTrade.ConsensusSignalID = signal.ConsensusSignalID
+	followTrade.IsPaper = isPaper
+	followTrade.SignalTimeMs = signal.ReceivedAt
+	followTrade.Status = model.FollowTradeStatusDetected
+	if err = model.CreateFollowTrade(followTrade); err != nil {
+		return fmt.Errorf("FollowAddressTradeBuyJob: create follow trade error: %v", err)
+	}
+	defer func() {
+		if saveErr := model.SaveFollowTrade(followTrade); saveErr != nil && err == nil {
+			err = fmt.Errorf("FollowAddressTradeBuyJob: save follow trade error: %v", saveErr)
 		}
-		toTokenAmountDf, err := decimal.NewFromString(quote.ToTokenAmount)
-		if err != nil {
+		notifyFollowTrade(followTrade)
+	}()
+
+	buyAmountDf, err := decimal.NewFromString(signal.Amount)
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Sprintf("buy amount parse error: %v", err)
+		return err
+	}
+	followTrade.FollowAddressBuyAmount, _ = buyAmountDf.Float64()
+
+	// 用关注地址模拟买入再卖出，买到貔貅会损失全部仓位
+	if config.CFG.Safety.Enable {
+		report := safety.Check(signal.ChainName, signal.TokenAddress, signal.FollowAddress)
+		if !report.Passed {
 			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: to token amount error: %v", err).Error()
-			return err
+			followTrade.FailReason = report.String()
+			return fmt.Errorf("FollowAddressTradeBuyJob: token %s safety check failed: %s", signal.TokenAddress, report)
 		}
-		toTokenRealAmountDf := toTokenAmountDf.Div(decimal.NewFromFloat(math.Pow(10, float64(quote.ToToken.Decimals))))
-		followTrade.WalletAddressBuyAmount, _ = toTokenRealAmountDf.Float64()
-		followTrade.BuyTokenDecimal = quote.ToToken.Decimals
+	}
 
-		swapRequest := &inch.SwapRequest{
-			FromTokenAddress: mainTokenAddress,
-			ToTokenAddress:   followTrade.BuyTokenAddress,
-			Amount:           walletSellMainTokenAmountDf.String(),
-			FromAddress:      wallet.Address,
-			Slippage:         20,
-		}
-		ex := exchange.NewExchange(followTrade.ChainName, wallet.PrivateKey, swapRequest)
+	capUsd, err := followBuyCapUsd(followAddress, paper)
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+		return fmt.Errorf("FollowAddressTradeBuyJob: %v", err)
+	}
+	if capUsd <= 0 {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = "FollowAddressTradeBuyJob: daily spend cap reached"
+		return fmt.Errorf("FollowAddressTradeBuyJob: follow address %s daily spend cap reached", signal.FollowAddress)
+	}
 
-		// 检查是否授权
-		allowance, err := ex.CheckAllowance()
-		if err != nil {
+	wallet, size, err := allocateWallet(signal, followAddress, followBuyUsd, capUsd, paper)
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: allocate wallet error: %v", err).Error()
+		return fmt.Errorf("FollowAddressTradeBuyJob: allocate wallet error: %v", err)
+	}
+	followTrade.WalletAddress = wallet.Address
+	followTrade.SizingStrategy = size.Strategy
+	followTrade.SizeUsd = size.SizeUsd
+	followTrade.WalletAddressSellAmount = size.MainTokenAmount
+
+	mainTokenDecimal := int64(util.MainTokenInfo[signal.ChainName].Decimal)
+	mainTokenAddress := util.MainTokenInfo[signal.ChainName].ContractAddress
+	walletSellMainTokenAmountDf := decimal.NewFromFloat(size.MainTokenAmount).Mul(decimal.NewFromFloat(math.Pow(10, float64(mainTokenDecimal)))).Floor()
+	swapRequest := &inch.SwapRequest{
+		FromTokenAddress: mainTokenAddress,
+		ToTokenAddress:   followTrade.BuyTokenAddress,
+		Amount:           walletSellMainTokenAmountDf.String(),
+		FromAddress:      wallet.Address,
+		Slippage:         followSlippage(followAddress),
+	}
+	ex := exchange.NewExecutor(followTrade.ChainName, wallet.PrivateKey, swapRequest, paper)
+
+	// 和兑换用同一个渠道报价，聚合器都报价失败时也能用 dex 路由的报价
+	quote, err := ex.Quote()
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: get quote error: %v", err).Error()
+		return fmt.Errorf("FollowAddressTradeBuyJob: get quote error: %v", err)
+	}
+	tokenDecimal, err := client.GetTokenDecimals(followTrade.BuyTokenAddress)
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: get token decimal error: %v", err).Error()
+		return err
+	}
+	tokenUnit := decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal)))
+	followTrade.WalletAddressBuyAmount, _ = decimal.NewFromBigInt(quote.ToAmount, 0).Div(tokenUnit).Float64()
+	followTrade.QuotedAmount = followTrade.WalletAddressBuyAmount
+	followTrade.BuyTokenDecimal = int(tokenDecimal)
+	if followBuyUsd > 0 && followTrade.FollowAddressBuyAmount > 0 {
+		followTrade.FollowEntryPriceUsd = followBuyUsd / followTrade.FollowAddressBuyAmount
+	}
+
+	if err = checkPriceMove(followAddress, signal, followBuyUsd, size.SizeUsd, followTrade.WalletAddressBuyAmount); err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+		return fmt.Errorf("FollowAddressTradeBuyJob: token %s: %v", signal.TokenAddress, err)
+	}
+	if err = setFollowTradeStatus(followTrade, model.FollowTradeStatusQuoted); err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+		return err
+	}
+
+	// 检查是否授权
+	allowance, err := ex.CheckAllowance()
+	if err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: check allowance error: %v", err).Error()
+		return err
+	}
+	if allowance == "0" {
+		if err = control.Allow(control.ActionBuy); err != nil {
 			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: check allowance error: %v", err).Error()
+			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
 			return err
 		}
-		if allowance == "0" {
-			approveTx, err := ex.ApproveTransaction(true)
-			if err != nil {
-				followTrade.Status = model.FollowTradeStatusFail
-				followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: approve tx error: %v", err).Error()
-				return err
-			}
-			_, err = eth.Client.WaitTxHashReceipt(approveTx)
-			if err != nil {
-				followTrade.Status = model.FollowTradeStatusFail
-				followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: wait approve tx receipt error: %v", err).Error()
-				return err
-			}
+		if err = setFollowTradeStatus(followTrade, model.FollowTradeStatusApproving); err != nil {
+			followTrade.Status = model.FollowTradeStatusFail
+			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+			return err
 		}
-
-		swapTx, err := ex.Swap()
+		approveTx, err := ex.ApproveTransaction(true)
 		if err != nil {
 			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: swap error: %v", err).Error()
+			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: approve tx error: %v", err).Error()
 			return err
 		}
-		followTrade.WalletAddressBuyTxHash = swapTx.String()
-		followTrade.WalletAddressBuyTime = time.Now().Unix()
-
-		receipt, err := eth.Client.WaitTxHashReceipt(swapTx)
+		_, err = ex.Wait(approveTx)
 		if err != nil {
 			followTrade.Status = model.FollowTradeStatusFail
-			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: wait swap tx receipt error: %v", err).Error()
+			followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: wait approve tx receipt error: %v", err).Error()
 			return err
 		}
-		followTrade.WalletAddressBuyGas = util.CalcGasFee(followAddress.ChainName, receipt.EffectiveGasPrice.Int64(), int64(receipt.GasUsed))
+	}
 
-		return nil
+	// 报价和授权期间可能已经停止交易，发送兑换前再检查一次
+	if err = control.Allow(control.ActionBuy); err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+		return err
+	}
+	// 发送前保存 submitted，发送后没来得及保存交易哈希时按钱包余额恢复
+	if err = setFollowTradeStatus(followTrade, model.FollowTradeStatusSubmitted); err != nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: %v", err).Error()
+		return err
+	}
+	swapTx, err := ex.Swap()
+	followTrade.SwapProvider = ex.Provider()
+	if err != nil {
+		recordSwap(isPaper, err)
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: swap error: %v", err).Error()
+		return err
 	}
+	followTrade.WalletAddressBuyTxHash = swapTx.String()
+	followTrade.WalletAddressBuyTime = time.Now().Unix()
+	followTrade.SubmitTimeMs = time.Now().UnixMilli()
+	if err = model.SaveFollowTrade(followTrade); err != nil {
+		// 交易已经发出，保存失败也继续等待确认，结束时再保存一次
+		log.Errorf("FollowAddressTradeBuyJob: save follow trade %d tx hash error: %v", followTrade.ID, err)
+	}
+
+	receipt, err := ex.Wait(swapTx)
+	recordSwap(isPaper, err)
+	if err != nil {
+		followTrade.FailReason = fmt.Errorf("FollowAddressTradeBuyJob: wait swap tx receipt error: %v", err).Error()
+		// 只有交易执行失败才算买入失败，超时或 rpc 出错时交易可能还会成功，保持 submitted 由 ReconcileFollowTradeJob 按回执恢复
+		if isPaper == 1 || errors.Is(err, eth.ErrTxFailed) {
+			followTrade.Status = model.FollowTradeStatusFail
+		}
+		return err
+	}
+	followTrade.WalletAddressBuyGas = receipt.Gas
+	if receipt.ToAmount != nil {
+		followTrade.WalletAddressBuyAmount, _ = decimal.NewFromBigInt(receipt.ToAmount, 0).Div(tokenUnit).Float64()
+	}
+	followTrade.ConfirmTimeMs = time.Now().UnixMilli()
+	if followTrade.WalletAddressBuyAmount > 0 {
+		followTrade.EntryPriceUsd = size.SizeUsd / followTrade.WalletAddressBuyAmount
+	}
+	followTrade.FollowTxBlockTimeMs = followTxBlockTimeMs(signal)
+	followTrade.Status = model.FollowTradeStatusSuccess
 
 	return nil
 }
 
-func SellPrincipalJob() {
-	var followTrades []*model.FollowTrade
-	db := model.GetDB()
+// setFollowTradeStatus 保存状态后再执行下一步
+func setFollowTradeStatus(followTrade *model.FollowTrade, status int) error {
+	followTrade.Status = status
+	if err := model.SaveFollowTrade(followTrade); err != nil {
+		return fmt.Errorf("save follow trade status %d error: %v", status, err)
+	}
+	return nil
+}
 
-	err := db.Where("status = ? and is_sell_principal=?", model.FollowTradeStatusSuccess, 0).Find(&followTrades).Error
+// allocateWallet 在关注地址的钱包分组里按分配策略的顺序选择第一个能算出仓位、未超过敞口上限且 gas 足够的钱包，
+// 仓位不超过关注地址剩余的买入额度 capUsd，模拟盘不检查 gas
+func allocateWallet(signal *followSignal, followAddress *model.FollowAddress, followBuyUsd, capUsd float64, paper bool) (*model.MyWallet, *sizing.Result, error) {
+	wallets, err := allocator.Rank(signal.ChainName, signal.TokenAddress, followAddress.WalletGroup)
 	if err != nil {
-		log.Errorf("SellPrincipalJob: get follow trades error: %v", err)
-		return
+		return nil, nil, err
 	}
 
-	for _, followTrade := range followTrades {
-		dl := decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))
-		amountDf := decimal.NewFromFloat(followTrade.WalletAddressBuyAmount).Mul(dl)
-		amount := amountDf.BigInt()
-		quote, err := inch.Quote(followTrade.ChainName, followTrade.BuyTokenAddress, util.MainTokenInfo[followTrade.ChainName].ContractAddress, amount.Int64())
+	var skipped []string
+	for _, wallet := range wallets {
+		size, err := followBuySize(wallet, signal, followAddress, followBuyUsd)
 		if err != nil {
-			log.Errorf("SellPrincipalJob: get quote error: %v", err)
+			skipped = append(skipped, fmt.Sprintf("%s sizing error: %v", wallet.Address, err))
 			continue
 		}
-		toTokenAmountDf, err := decimal.NewFromString(quote.ToTokenAmount)
-		toTokenRealAmountDf := toTokenAmountDf.Div(decimal.NewFromFloat(math.Pow(10, float64(quote.ToToken.Decimals))))
-		cost := decimal.NewFromFloat(followTrade.WalletAddressSellAmount).Add(decimal.NewFromFloat(followTrade.WalletAddressBuyGas))
-		gasPrice, err := eth.Client.GetEthClient().SuggestGasPrice(context.Background())
+		if capUsd < size.SizeUsd {
+			size.MainTokenAmount *= capUsd / size.SizeUsd
+			size.SizeUsd = capUsd
+			size.Strategy += "+follow_address_cap"
+		}
+		headroom, err := exposureHeadroomUsd(signal.ChainName, wallet.Address, signal.TokenAddress, paper)
 		if err != nil {
-			log.Errorf("SellPrincipalJob: get gas price error: %v", err)
+			return nil, nil, err
+		}
+		if headroom <= 0 {
+			skipped = append(skipped, fmt.Sprintf("%s exposure cap reached", wallet.Address))
+			continue
+		}
+		if headroom < size.SizeUsd {
+			size.MainTokenAmount *= headroom / size.SizeUsd
+			size.SizeUsd = headroom
+			size.Strategy += "+exposure_cap"
+		}
+		if paper {
+			return wallet, size, nil
+		}
+		if err = allocator.CheckGas(signal.ChainName, wallet, size.MainTokenAmount); err != nil {
+			skipped = append(skipped, err.Error())
 			continue
 		}
-		gasPriceDf := decimal.NewFromInt(gasPrice.Int64()).Div(decimal.NewFromFloat(math.Pow(10, float64(util.MainTokenInfo[followTrade.ChainName].Decimal))))
-		sellGasUsed := gasPriceDf.Mul(decimal.NewFromInt(int64(quote.EstimatedGas)))
+		return wallet, size, nil
+	}
+	return nil, nil, fmt.Errorf("no wallet can cover the trade: %s", strings.Join(skipped, "; "))
+}
 
-		buyCost := cost.Add(sellGasUsed)
-		shouldSellPrincipalMainTokenAmount := buyCost.Mul(decimal.NewFromInt(2))
-		log.Debugf("当前总价值：%v, 成本: %v, 当前卖出需要的gas fee:%v",
-			toTokenRealAmountDf.String(), cost.String(), sellGasUsed.String())
-		log.Debugf("翻本卖出达到的数量应该是:%v", shouldSellPrincipalMainTokenAmount.String())
+// getFollowAddress 查询关注地址的配置，共识信号的地址可能不在关注列表里，此时返回空配置
+func getFollowAddress(chainName, address string) (*model.FollowAddress, error) {
+	followAddress := new(model.FollowAddress)
+	err := model.GetDB().Where("chain_name = ? and lower(address) = ?", chainName, strings.ToLower(address)).First(followAddress).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("get follow address error: %v", err)
+	}
+	return followAddress, nil
+}
 
-		toTokenRealAmountDf = decimal.NewFromFloat(0.013)
+// followBuySize 按关注地址配置的仓位策略计算买入金额，未配置时使用全局策略
+func followBuySize(wallet *model.MyWallet, signal *followSignal, followAddress *model.FollowAddress, followBuyUsd float64) (*sizing.Result, error) {
+	req := &sizing.Request{
+		ChainName:     signal.ChainName,
+		FollowAddress: signal.FollowAddress,
+		TokenAddress:  signal.TokenAddress,
+		FollowBuyUsd:  followBuyUsd,
+		Wallet:        wallet,
+		Value:         followAddress.SizingValue,
+	}
+	return sizing.Size(followAddress.SizingStrategy, req)
+}
 
-		// 翻倍
-		if toTokenRealAmountDf.GreaterThanOrEqual(shouldSellPrincipalMainTokenAmount) {
-			log.Infof("已翻倍，启动出本策略")
-			bf := buyCost.Mul(decimal.NewFromFloat(math.Pow(10, float64(util.MainTokenInfo[followTrade.ChainName].Decimal))))
-			quote, err = inch.Quote(followTrade.ChainName, util.MainTokenInfo[followTrade.ChainName].ContractAddress, followTrade.BuyTokenAddress, bf.BigInt().Int64())
-			if err != nil {
-				log.Errorf("SellPrincipalJob: get quote error: %v", err)
-				continue
-			}
-			log.Infof("卖出代币数量：%v", quote.ToTokenAmount)
-			wallet := new(model.MyWallet)
-			err = db.Where("chain_name = ? and address = ? and status = ?", followTrade.ChainName, followTrade.WalletAddress, model.MyWalletStatusEnable).First(wallet).Error
-			if err != nil {
-				log.Errorf("SellPrincipalJob: get wallet error: %v", err)
-				continue
-			}
-			swapRequest := &inch.SwapRequest{
-				FromTokenAddress: followTrade.BuyTokenAddress,
-				ToTokenAddress:   util.MainTokenInfo[followTrade.ChainName].ContractAddress,
-				Amount:           quote.ToTokenAmount,
-				FromAddress:      followTrade.WalletAddress,
-				Slippage:         20,
-			}
-			ex := exchange.NewExchange(followTrade.ChainName, wallet.PrivateKey, swapRequest)
-			// 检查是否授权
-			allowance, err := ex.CheckAllowance()
-			if err != nil {
-				log.Errorf("SellPrincipalJob: check allowance error: %v", err)
-				continue
-			}
-			af, _ := decimal.NewFromString(allowance)
-			qf, _ := decimal.NewFromString(quote.ToTokenAmount)
-			if allowance == "0" || af.LessThanOrEqual(qf) {
-				log.Infof("授权代币数量：%v", quote.ToTokenAmount)
-				approveTx, err := ex.ApproveTransaction(true)
-				if err != nil {
-					log.Errorf("SellPrincipalJob: approve tx error: %v", err)
-					continue
-				}
-				_, err = eth.Client.WaitTxHashReceipt(approveTx)
-				if err != nil {
-					log.Errorf("SellPrincipalJob: wait approve tx receipt error: %v", err)
-					continue
-				}
-			}
+// ExitStrategyJob 按实时报价给未卖出的跟单估值，由关注地址配置的退出策略决定是否卖出
+func ExitStrategyJob() error {
+	// 熔断时报价和发送交易都不可靠，跳过本轮
+	if err := control.Allow(control.ActionSell); err != nil {
+		log.Warnf("ExitStrategyJob: skip: %v", err)
+		return nil
+	}
+	var followTrades []*model.FollowTrade
+	err := model.GetDB().Where("status = ?", model.FollowTradeStatusSuccess).Find(&followTrades).Error
+	if err != nil {
+		return fmt.Errorf("ExitStrategyJob: get follow trades error: %v", err)
+	}
 
-			swapTx, err := ex.Swap()
-			if err != nil {
-				log.Errorf("SellPrincipalJob: swap error: %v", err)
-				continue
-			}
-			if _, err := eth.Client.WaitTxHashReceipt(swapTx); err != nil {
-				log.Errorf("SellPrincipalJob: wait swap tx receipt error: %v", err)
-				continue
-			}
-			followTrade.SellPrincipalTxHash = swapTx.String()
+	strategies := make(map[string]exitstrategy.ExitStrategy)
+	for _, followTrade := range followTrades {
+		strategy, err := getExitStrategy(followTrade, strategies)
+		if err != nil {
+			log.Errorf("ExitStrategyJob: follow trade %d: %v", followTrade.ID, err)
+			continue
+		}
+		if err = runExitStrategy(followTrade, strategy); err != nil {
+			log.Errorf("ExitStrategyJob: follow trade %d: %v", followTrade.ID, err)
+		}
+	}
+	return nil
+}
+
+// getExitStrategy 跟单对应关注地址的退出策略，关注地址不存在时使用全局配置
+func getExitStrategy(followTrade *model.FollowTrade, strategies map[string]exitstrategy.ExitStrategy) (exitstrategy.ExitStrategy, error) {
+	key := followTrade.ChainName + ":" + strings.ToLower(followTrade.FollowAddress)
+	if strategy, ok := strategies[key]; ok {
+		return strategy, nil
+	}
+
+	followAddress, err := getFollowAddress(followTrade.ChainName, followTrade.FollowAddress)
+	if err != nil {
+		return nil, err
+	}
+	strategy, err := exitstrategy.New(followAddress.ExitStrategy, followAddress.ExitValue)
+	if err != nil {
+		return nil, err
+	}
+	strategies[key] = strategy
+	return strategy, nil
+}
+
+func runExitStrategy(followTrade *model.FollowTrade, strategy exitstrategy.ExitStrategy) error {
+	balance, err := followTradeBalance(followTrade)
+	if err != nil {
+		return err
+	}
+	if balance.Sign() <= 0 {
+		return nil
+	}
+
+	mainToken := util.MainTokenInfo[followTrade.ChainName]
+	value, quote, err := quoteMainToken(followTrade.ChainName, followTrade.BuyTokenAddress, balance)
+	if err != nil {
+		return fmt.Errorf("get quote error: %v", err)
+	}
+
+	gasPrice, err := eth.Client.GetEthClient().SuggestGasPrice(context.Background())
+	if err != nil {
+		return fmt.Errorf("get gas price error: %v", err)
+	}
+	gasPriceDf := decimal.NewFromBigInt(gasPrice, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal))))
+	sellGas, _ := gasPriceDf.Mul(decimal.NewFromInt(quote.EstimatedGas)).Float64()
+
+	position := &exitstrategy.Position{FollowTrade: followTrade}
+	position.Amount, _ = decimal.NewFromBigInt(balance, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))).Float64()
+	log.Debugf("ExitStrategyJob: follow trade %d value: %v, sell gas: %v, strategy: %s", followTrade.ID, value, sellGas, strategy.Name())
+
+	// 指令的比例按估值时的持仓计算，前面的指令成交后换算成剩余持仓的比例
+	sold := 0.0
+	for _, instruction := range strategy.Evaluate(position, &exitstrategy.Quote{Value: value}, sellGas) {
+		if sold >= fullExitFraction {
+			break
+		}
+		fraction := math.Min(instruction.Fraction/(1-sold), 1)
+		log.Infof("ExitStrategyJob: follow trade %d %s sell %.4f, reason: %s", followTrade.ID, strategy.Name(), fraction, instruction.Reason)
+		exit, err := sellFollowTrade(followTrade, fraction, instruction.Reason, "")
+		if err != nil {
+			return err
+		}
+		if exit == nil {
+			return nil
+		}
+		sold += instruction.Fraction
+
+		if instruction.Principal {
 			followTrade.IsSellPrincipal = 1
-			if err = model.SaveFollowTrade(followTrade); err != nil {
-				log.Errorf("SellPrincipalJob: save follow trade error: %v", err)
-				continue
+			followTrade.SellPrincipalTxHash = exit.TxHash
+			err = model.GetDB().Model(followTrade).UpdateColumns(map[string]interface{}{
+				"is_sell_principal":      1,
+				"sell_principal_tx_hash": exit.TxHash,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("save follow trade error: %v", err)
 			}
-			log.Infof("出本成功，交易hash：%v", swapTx.String())
+			log.Infof("出本成功，交易hash：%v", exit.TxHash)
 		}
 	}
+	return nil
 }
 
-func CheckBalanceJob() {
+// CheckBalanceJob 关注地址清仓时卖出全部持仓，钱包余额为 0 或价值过低时结束跟单并记录已实现盈亏
+func CheckBalanceJob() error {
 	var followTrades []*model.FollowTrade
 	db := model.GetDB()
 
-	err := db.Where("status=?", model.FollowTradeStatusSuccess).Find(&followTrades).Error
+	err := db.Where("status = ?", model.FollowTradeStatusSuccess).Find(&followTrades).Error
 	if err != nil {
-		log.Errorf("SellPrincipalJob: get follow trades error: %v", err)
-		return
+		return fmt.Errorf("SellPrincipalJob: get follow trades error: %v", err)
 	}
 
 	for _, followTrade := range followTrades {
+		exited, err := followAddressExited(followTrade)
+		if err != nil {
+			log.Errorf("SellPrincipalJob: follow trade %d: %v", followTrade.ID, err)
+			continue
+		}
+		// 模拟盘没有链上余额
+		if exited || followTrade.IsPaper == 1 {
+			continue
+		}
+
 		balanceResp, err := oklink.Api.GetAddressBalance(followTrade.ChainName, followTrade.WalletAddress, followTrade.BuyTokenAddress, 1, 1)
 		if err != nil {
 			log.Errorf("SellPrincipalJob: get balance error: %v", err)
 			continue
 		}
 
-		// 计算余额
-		if len(balanceResp.Data[0].TokenList) > 0 {
-			tokenDecimal, err := eth.Client.GetTokenDecimals(followTrade.BuyTokenAddress)
-			if err != nil {
-				log.Errorf("SellPrincipalJob: get token decimal error: %v", err)
-				continue
-			}
-			holdingAmountDf, err := decimal.NewFromString(balanceResp.Data[0].TokenList[0].HoldingAmount)
-			if err != nil {
-				log.Errorf("SellPrincipalJob: get holding amount error: %v", err)
-				continue
-			}
-			df := holdingAmountDf.Mul(decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal))))
-			amount := df.BigInt().Int64()
-			if amount < 0 {
-				amount = 0
-			}
-			quote, err := inch.Quote(followTrade.ChainName, followTrade.BuyTokenAddress, util.USDTContractMap[followTrade.ChainName].ContractAddress, amount)
-			if err != nil {
-				log.Errorf("SellPrincipalJob: get quote error: %v", err)
-				continue
+		// 余额为 0 说明已经在外部卖出或转走
+		if len(balanceResp.Data) == 0 || len(balanceResp.Data[0].TokenList) == 0 {
+			log.Infof("follow trade id:%d 购买的代币余额为 0，当做已结束", followTrade.ID)
+			if err = closeFollowTrade(followTrade, 0, 0); err != nil {
+				log.Errorf("SellPrincipalJob: %v", err)
 			}
+			continue
+		}
 
-			var amountDf decimal.Decimal
-			if err != nil {
-				amountDf = decimal.NewFromInt(0)
-			} else {
-				amountDf, err = decimal.NewFromString(quote.ToTokenAmount)
-				if err != nil {
-					log.Errorf("SellPrincipalJob: get amount decimal error: %v", err)
-					continue
-				}
+		// 计算余额
+		tokenDecimal, err := eth.Client.GetTokenDecimals(followTrade.BuyTokenAddress)
+		if err != nil {
+			log.Errorf("SellPrincipalJob: get token decimal error: %v", err)
+			continue
+		}
+		holdingAmountDf, err := decimal.NewFromString(balanceResp.Data[0].TokenList[0].HoldingAmount)
+		if err != nil {
+			log.Errorf("SellPrincipalJob: get holding amount error: %v", err)
+			continue
+		}
+		df := holdingAmountDf.Mul(decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal)))).Floor()
+		if df.Sign() <= 0 {
+			df = decimal.Zero
+		}
+		quote, err := exchange.Quote(followTrade.ChainName, &inch.SwapRequest{
+			FromTokenAddress: followTrade.BuyTokenAddress,
+			ToTokenAddress:   util.USDTContractMap[followTrade.ChainName].ContractAddress,
+			Amount:           df.String(),
+		})
+		if err != nil {
+			log.Errorf("SellPrincipalJob: get quote error: %v", err)
+			continue
+		}
+		usdtValueDf := decimal.NewFromBigInt(quote.ToAmount, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(util.USDTContractMap[followTrade.ChainName].Decimal))))
+		// 小于10u卖出的 gas 不划算，不卖出直接结束，剩余持仓记为未实现
+		if usdtValueDf.LessThanOrEqual(decimal.NewFromFloat(10)) {
+			log.Infof("follow trade id:%d 购买的代币余额(usdt价值)：%v, 小于10u，当做已结束", followTrade.ID, usdtValueDf)
+			dustAmount, _ := holdingAmountDf.Float64()
+			var dustValue float64
+			if price, err := sizing.MainTokenPriceUsd(followTrade.ChainName); err != nil {
+				log.Errorf("SellPrincipalJob: get main token price error: %v", err)
+			} else if price > 0 {
+				dustValue, _ = usdtValueDf.Div(decimal.NewFromFloat(price)).Float64()
 			}
-			usdtValueDf := amountDf.Div(decimal.NewFromFloat(math.Pow(10, float64(util.USDTContractMap[followTrade.ChainName].Decimal))))
-			// 小于10u就认为卖完了
-			if usdtValueDf.LessThanOrEqual(decimal.NewFromFloat(10)) {
-				log.Infof("follow trade id:%d 购买的代币余额(usdt价值)：%v, 小于10u，当做已结束", followTrade.ID, usdtValueDf)
-				followTrade.Status = model.FollowTradeStatusFinish
-				if err = model.SaveFollowTrade(followTrade); err != nil {
-					log.Errorf("SellPrincipalJob: save follow trade error: %v", err)
-				}
-				continue
+			if err = closeFollowTrade(followTrade, dustAmount, dustValue); err != nil {
+				log.Errorf("SellPrincipalJob: %v", err)
 			}
 		}
 	}
+	return nil
 }
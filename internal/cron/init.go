@@ -1,24 +1,80 @@
 package cron
 
-func Init() {
-	// go func() {
-	// 	for {
-	// 		FollowAddressTradeBuyJob()
-	// 		time.Sleep(10 * time.Second)
-	// 	}
-	// }()
-	//
-	// go func() {
-	// 	for {
-	// 		SellPrincipalJob()
-	// 		time.Sleep(10 * time.Second)
-	// 	}
-	// }()
-	//
-	// go func() {
-	// 	for {
-	// 		CheckBalanceJob()
-	// 		time.Sleep(10 * time.Second)
-	// 	}
-	// }()
+import (
+	"context"
+	"strings"
+	"time"
+
+	"smart-money/config"
+	"smart-money/internal/control"
+	"smart-money/internal/scheduler"
+	"smart-money/pkg/model"
+)
+
+var Scheduler = scheduler.New()
+
+// jobs 默认都不开启，需要在配置文件 [jobs] 中配置执行间隔或 cron 表达式
+var jobs = []struct {
+	name        string
+	defaultSpec string
+	fn          scheduler.JobFunc
+}{
+	{"follow_buy", "10s", FollowAddressTradeBuyJob},
+	// 沿用原来出本任务的名字，避免已有配置失效
+	{"sell_principal", "10s", ExitStrategyJob},
+	{"check_balance", "10s", CheckBalanceJob},
+	{"risk", "30s", RiskJob},
+	{"consensus", "10s", ConsensusJob},
+	{"rolling_performance", "6h", RollingPerformanceJob},
+	{"wallet_balance", "10m", WalletBalanceJob},
+	{"reconcile", "1m", ReconcileFollowTradeJob},
+}
+
+func Init() error {
+	if err := control.Init(); err != nil {
+		return err
+	}
+	// 先恢复上次没走完的跟单，避免和新的跟单、卖出同时处理
+	if err := reconcileFollowTrades(time.Now()); err != nil {
+		return err
+	}
+	if err := rearmDelayedFollowSignals(); err != nil {
+		return err
+	}
+	// 停止交易时需要清仓的在后台卖出，启动时还有没清仓完的继续清仓
+	control.OnHalt(func(state model.TradingControl) {
+		notifyHalt(state)
+		if state.Liquidate == 1 {
+			go liquidateOpenTrades()
+		}
+	})
+	if control.Get().Liquidate == 1 {
+		go liquidateOpenTrades()
+	}
+
+	for _, job := range jobs {
+		spec, enabled := job.defaultSpec, false
+		if s := strings.TrimSpace(config.CFG.Jobs[job.name]); s != "" && s != "off" {
+			spec, enabled = s, true
+		}
+		if err := Scheduler.Register(job.name, spec, enabled, job.fn); err != nil {
+			return err
+		}
+	}
+	Scheduler.Start()
+	if err := startFollowExecutors(); err != nil {
+		return err
+	}
+	return startMempoolWatcher()
+}
+
+// Stop waits for the running jobs, follow executors and mempool watcher to finish.
+func Stop(ctx context.Context) error {
+	if err := Scheduler.Stop(ctx); err != nil {
+		return err
+	}
+	if err := stopMempoolWatcher(ctx); err != nil {
+		return err
+	}
+	return stopFollowExecutors(ctx)
 }
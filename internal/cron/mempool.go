@@ -0,0 +1,165 @@
+package cron
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/internal/mempool"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const mempoolAddressRefreshInterval = 30 * time.Second
+
+var (
+	mempoolCancel context.CancelFunc
+	mempoolWg     sync.WaitGroup
+)
+
+// startMempoolWatcher 监控关注地址的 pending 兑换，只支持 web3 配置的链
+func startMempoolWatcher() error {
+	cfg := config.CFG.Mempool
+	if !cfg.Enable {
+		return nil
+	}
+	wsAddr := cfg.Ws
+	if wsAddr == "" {
+		wsAddr = config.CFG.Web3.Rpc
+	}
+
+	chainName := config.CFG.Web3.ChainName
+	watcher := mempool.NewWatcher(chainName, wsAddr, config.CFG.Web3.ChainID, onPendingSwap)
+	if err := refreshMempoolAddresses(watcher, chainName); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mempoolCancel = cancel
+	mempoolWg.Add(2)
+	go func() {
+		defer mempoolWg.Done()
+		watcher.Run(ctx)
+	}()
+	go func() {
+		defer mempoolWg.Done()
+		ticker := time.NewTicker(mempoolAddressRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := refreshMempoolAddresses(watcher, chainName); err != nil {
+					log.Errorf("mempool watcher: refresh follow address error: %v", err)
+				}
+			}
+		}
+	}()
+	log.Infof("started mempool watcher on %s", chainName)
+	return nil
+}
+
+func stopMempoolWatcher(ctx context.Context) error {
+	if mempoolCancel == nil {
+		return nil
+	}
+	mempoolCancel()
+
+	done := make(chan struct{})
+	go func() {
+		mempoolWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func refreshMempoolAddresses(watcher *mempool.Watcher, chainName string) error {
+	var followAddresses []*model.FollowAddress
+	err := model.GetDB().Where("chain_name = ? and status = ?", chainName, model.FollowAddressStatusNormal).Find(&followAddresses).Error
+	if err != nil {
+		return err
+	}
+	addresses := make([]string, 0, len(followAddresses))
+	for _, followAddress := range followAddresses {
+		addresses = append(addresses, followAddress.Address)
+	}
+	watcher.SetAddresses(addresses)
+	return nil
+}
+
+// onPendingSwap 关注地址用主流币买入非主流币时发出跟单信号
+func onPendingSwap(swap *mempool.PendingSwap) {
+	if config.CFG.Consensus.FollowTrigger {
+		return
+	}
+
+	paySymbol, payDecimal := util.NativeTokenSymbol[swap.ChainName], util.MainTokenInfo[swap.ChainName].Decimal
+	if !mempool.IsNative(swap.TokenIn) {
+		symbol, err := eth.Client.GetTokenSymbol(swap.TokenIn)
+		if err != nil {
+			log.Errorf("mempool watcher: get token %s symbol error: %v", swap.TokenIn, err)
+			return
+		}
+		if !util.IsMainToken(symbol) {
+			return
+		}
+		if payDecimal, err = eth.Client.GetTokenDecimals(swap.TokenIn); err != nil {
+			log.Errorf("mempool watcher: get token %s decimal error: %v", swap.TokenIn, err)
+			return
+		}
+		paySymbol = symbol
+	}
+	if mempool.IsNative(swap.TokenOut) {
+		return
+	}
+	symbol, err := eth.Client.GetTokenSymbol(swap.TokenOut)
+	if err != nil {
+		log.Errorf("mempool watcher: get token %s symbol error: %v", swap.TokenOut, err)
+		return
+	}
+	if util.IsMainToken(symbol) {
+		return
+	}
+	tokenDecimal, err := eth.Client.GetTokenDecimals(swap.TokenOut)
+	if err != nil {
+		log.Errorf("mempool watcher: get token %s decimal error: %v", swap.TokenOut, err)
+		return
+	}
+
+	// 确认前只知道最小输出或精确输出数量
+	amount := decimal.NewFromBigInt(swap.AmountOut, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal))))
+	payAmount := decimal.NewFromBigInt(swap.AmountIn, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(payDecimal))))
+	signal := &followSignal{
+		ChainName:     swap.ChainName,
+		FollowAddress: swap.From,
+		TxHash:        strings.ToLower(swap.TxHash),
+		TxTime:        swap.SeenAt.Unix(),
+		TokenAddress:  swap.TokenOut,
+		Symbol:        symbol,
+		Amount:        amount.String(),
+		Side:          followSideBuy,
+		PaySymbol:     paySymbol,
+		PayAmount:     payAmount.String(),
+	}
+
+	// 下单要等交易确认，不阻塞订阅
+	mempoolWg.Add(1)
+	go func() {
+		defer mempoolWg.Done()
+		if err := dispatchFollowSignal(signal); err != nil {
+			log.Errorf("mempool watcher: follow pending tx %s error: %v", signal.TxHash, err)
+		}
+	}()
+}
@@ -0,0 +1,179 @@
+package cron
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/internal/notify"
+	"smart-money/internal/sizing"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+// riskExitReasons 风控触发的卖出，通知类型为 risk
+var riskExitReasons = map[string]bool{
+	exitReasonStopLoss:     true,
+	exitReasonTakeProfit:   true,
+	exitReasonTrailingStop: true,
+	exitReasonMaxHold:      true,
+	exitReasonExposureCap:  true,
+	exitReasonKillSwitch:   true,
+}
+
+func notifyFollowSignal(signal *followSignal) {
+	notify.Send(&notify.Event{
+		Type:  notify.EventFollowSignal,
+		Title: fmt.Sprintf("%s %s %s", signal.FollowAddress, signal.Side, signal.Symbol),
+		Data: map[string]interface{}{
+			"chain_name":    signal.ChainName,
+			"token_address": signal.TokenAddress,
+			"amount":        signal.Amount,
+			"tx_hash":       signal.TxHash,
+		},
+	})
+}
+
+// notifyFollowTrade 买入结束后通知成功或失败
+func notifyFollowTrade(followTrade *model.FollowTrade) {
+	event := &notify.Event{
+		Data: map[string]interface{}{
+			"follow_trade_id": followTrade.ID,
+			"chain_name":      followTrade.ChainName,
+			"wallet_address":  followTrade.WalletAddress,
+			"follow_address":  followTrade.FollowAddress,
+			"token_address":   followTrade.BuyTokenAddress,
+			"is_paper":        followTrade.IsPaper,
+		},
+	}
+	switch followTrade.Status {
+	case model.FollowTradeStatusSuccess:
+		event.Type = notify.EventBuy
+		event.Title = fmt.Sprintf("bought %v %s", followTrade.WalletAddressBuyAmount, followTrade.BuySymbol)
+		event.Data["size_usd"] = followTrade.SizeUsd
+		event.Data["tx_hash"] = followTrade.WalletAddressBuyTxHash
+	case model.FollowTradeStatusFail:
+		event.Type = notify.EventFailure
+		event.Title = fmt.Sprintf("buy %s failed", followTrade.BuySymbol)
+		event.Message = followTrade.FailReason
+	default:
+		return
+	}
+	notify.Send(event)
+}
+
+// notifyFollowTradeExit 卖出结束后通知，风控触发的卖出通知类型为 risk
+func notifyFollowTradeExit(followTrade *model.FollowTrade, exit *model.FollowTradeExit) {
+	event := &notify.Event{
+		Type:  notify.EventSell,
+		Title: fmt.Sprintf("sold %v %s, reason: %s", exit.SellAmount, followTrade.BuySymbol, exit.Reason),
+		Data: map[string]interface{}{
+			"follow_trade_id": followTrade.ID,
+			"chain_name":      exit.ChainName,
+			"wallet_address":  exit.WalletAddress,
+			"token_address":   exit.TokenAddress,
+			"receive_amount":  exit.ReceiveAmount,
+			"tx_hash":         exit.TxHash,
+			"is_paper":        followTrade.IsPaper,
+		},
+	}
+	if followTrade.Status == model.FollowTradeStatusFinish {
+		event.Data["realized_pnl"] = followTrade.RealizedPnl
+	}
+	if riskExitReasons[exit.Reason] {
+		event.Type = notify.EventRisk
+	}
+	if exit.Status == model.FollowTradeExitStatusFail {
+		event.Type = notify.EventFailure
+		event.Title = fmt.Sprintf("sell %s failed, reason: %s", followTrade.BuySymbol, exit.Reason)
+		event.Message = exit.FailReason
+	}
+	notify.Send(event)
+}
+
+func notifyHalt(state model.TradingControl) {
+	notify.Send(&notify.Event{
+		Type:    notify.EventRisk,
+		Title:   fmt.Sprintf("trading halted by %s", state.Source),
+		Message: state.Reason,
+		Data:    map[string]interface{}{"liquidate": state.Liquidate == 1},
+	})
+}
+
+// lowBalanceWallets 已经通知过余额不足的钱包，余额恢复后再次不足时重新通知
+var (
+	lowBalanceLock    sync.Mutex
+	lowBalanceWallets = make(map[uint]bool)
+)
+
+// WalletBalanceJob 钱包主流币余额低于配置时发送通知
+func WalletBalanceJob() error {
+	minUsd := config.CFG.Notify.MinWalletBalanceUsd
+	if minUsd <= 0 {
+		return nil
+	}
+
+	var wallets []*model.MyWallet
+	if err := model.GetDB().Find(&wallets).Error; err != nil {
+		return fmt.Errorf("WalletBalanceJob: get wallets error: %v", err)
+	}
+
+	mainTokenPrices := make(map[string]float64)
+	for _, wallet := range wallets {
+		mainToken := util.MainTokenInfo[wallet.ChainName]
+		if mainToken == nil {
+			continue
+		}
+		price, ok := mainTokenPrices[wallet.ChainName]
+		if !ok {
+			var err error
+			if price, err = sizing.MainTokenPriceUsd(wallet.ChainName); err != nil {
+				log.Errorf("WalletBalanceJob: get %s main token price error: %v", wallet.ChainName, err)
+				continue
+			}
+			mainTokenPrices[wallet.ChainName] = price
+		}
+
+		var balance decimal.Decimal
+		if strings.EqualFold(mainToken.ContractAddress, "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee") {
+			b, err := eth.Client.GetNativeBalance(wallet.Address)
+			if err != nil {
+				log.Errorf("WalletBalanceJob: get wallet %s balance error: %v", wallet.Address, err)
+				continue
+			}
+			balance = decimal.NewFromBigInt(b, 0)
+		} else {
+			b, err := eth.Client.GetTokenBalance(mainToken.ContractAddress, wallet.Address)
+			if err != nil {
+				log.Errorf("WalletBalanceJob: get wallet %s balance error: %v", wallet.Address, err)
+				continue
+			}
+			balance = decimal.NewFromBigInt(b, 0)
+		}
+		amount, _ := balance.Div(decimal.NewFromFloat(math.Pow(10, float64(mainToken.Decimal)))).Float64()
+		balanceUsd := amount * price
+
+		lowBalanceLock.Lock()
+		notified := lowBalanceWallets[wallet.ID]
+		lowBalanceWallets[wallet.ID] = balanceUsd < minUsd
+		lowBalanceLock.Unlock()
+		if balanceUsd >= minUsd || notified {
+			continue
+		}
+		notify.Send(&notify.Event{
+			Type:  notify.EventLowBalance,
+			Title: fmt.Sprintf("wallet %s balance %.2f usd below %.2f usd", wallet.Name, balanceUsd, minUsd),
+			Data: map[string]interface{}{
+				"chain_name":     wallet.ChainName,
+				"wallet_address": wallet.Address,
+				"balance":        amount,
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const (
+	// reconcileStaleAfter 定时恢复只处理超过这个时间没有更新的跟单，避免和正在执行的跟单同时处理
+	reconcileStaleAfter = 10 * time.Minute
+	// reconcileRpcTimeout 恢复时每次查询链上数据的超时时间，交易还没打包时留到下次恢复，不等待
+	reconcileRpcTimeout = 10 * time.Second
+)
+
+// ReconcileFollowTradeJob 定时恢复长时间停在中间状态的跟单，比如启动时兑换交易还没打包
+func ReconcileFollowTradeJob() error {
+	return reconcileFollowTrades(time.Now().Add(-reconcileStaleAfter))
+}
+
+// reconcileFollowTrades 恢复 updatedBefore 之前更新、没有走完的跟单，启动时在跟单任务开始前恢复全部
+func reconcileFollowTrades(updatedBefore time.Time) error {
+	var followTrades []*model.FollowTrade
+	err := model.GetDB().Where("status in ? and updated_at < ?", []int{model.FollowTradeStatusDetected, model.FollowTradeStatusQuoted,
+		model.FollowTradeStatusApproving, model.FollowTradeStatusSubmitted, model.FollowTradeStatusExiting}, updatedBefore).
+		Find(&followTrades).Error
+	if err != nil {
+		return fmt.Errorf("ReconcileFollowTradeJob: get follow trades error: %v", err)
+	}
+
+	for _, followTrade := range followTrades {
+		status := followTrade.Status
+		if err = reconcileFollowTrade(followTrade); err != nil {
+			log.Errorf("ReconcileFollowTradeJob: follow trade %d: %v", followTrade.ID, err)
+			continue
+		}
+		if followTrade.Status == status {
+			log.Infof("ReconcileFollowTradeJob: follow trade %d tx still pending", followTrade.ID)
+			continue
+		}
+		if err = model.SaveFollowTrade(followTrade); err != nil {
+			log.Errorf("ReconcileFollowTradeJob: save follow trade %d error: %v", followTrade.ID, err)
+			continue
+		}
+		log.Infof("ReconcileFollowTradeJob: follow trade %d status %d -> %d", followTrade.ID, status, followTrade.Status)
+	}
+	return nil
+}
+
+func reconcileFollowTrade(followTrade *model.FollowTrade) error {
+	switch followTrade.Status {
+	case model.FollowTradeStatusExiting:
+		// 和卖出用同一把锁，拿到锁后重新读取，卖出可能已经结束
+		unlock := lockFollowTrade(followTrade.ID)
+		defer unlock()
+		if err := model.GetDB().First(followTrade, followTrade.ID).Error; err != nil {
+			return fmt.Errorf("get follow trade error: %v", err)
+		}
+		if followTrade.Status != model.FollowTradeStatusExiting {
+			return nil
+		}
+		return reconcileExit(followTrade)
+	case model.FollowTradeStatusSubmitted:
+		if followTrade.IsPaper == 1 {
+			// 模拟盘的成交只在内存中
+			followTrade.Status = model.FollowTradeStatusFail
+			followTrade.FailReason = "reconcile: paper fill lost on restart"
+			return nil
+		}
+		if followTrade.WalletAddressBuyTxHash == "" {
+			return reconcileByBalance(followTrade)
+		}
+		return reconcileByReceipt(followTrade)
+	default:
+		// 兑换交易还没有发出
+		followTrade.FailReason = fmt.Sprintf("reconcile: interrupted at status %d before swap", followTrade.Status)
+		followTrade.Status = model.FollowTradeStatusFail
+		return nil
+	}
+}
+
+// txReceipt 查询交易回执，交易还在等待打包时 pending 为 true，交易被丢弃时回执和错误都为 nil
+func txReceipt(txHash common.Hash) (receipt *types.Receipt, pending bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileRpcTimeout)
+	defer cancel()
+	receipt, err = eth.Client.GetEthClient().TransactionReceipt(ctx, txHash)
+	if err == nil {
+		return receipt, false, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return nil, false, fmt.Errorf("get tx %s receipt error: %v", txHash, err)
+	}
+	_, pending, err = eth.Client.GetEthClient().TransactionByHash(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get tx %s error: %v", txHash, err)
+	}
+	if !pending {
+		return nil, false, fmt.Errorf("tx %s mined but receipt not found", txHash)
+	}
+	return nil, true, nil
+}
+
+// reconcileByReceipt 按兑换交易的回执恢复，交易还在等待打包时保持 submitted，由定时恢复再检查
+func reconcileByReceipt(followTrade *model.FollowTrade) error {
+	receipt, pending, err := txReceipt(common.HexToHash(followTrade.WalletAddressBuyTxHash))
+	if err != nil || pending {
+		return err
+	}
+	if receipt == nil {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Sprintf("reconcile: swap tx %s dropped", followTrade.WalletAddressBuyTxHash)
+		return nil
+	}
+
+	followTrade.WalletAddressBuyGas = util.CalcGasFee(followTrade.ChainName, receipt.EffectiveGasPrice.Int64(), int64(receipt.GasUsed))
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Sprintf("reconcile: swap tx %s failed", followTrade.WalletAddressBuyTxHash)
+		return nil
+	}
+	// 等待回执超时时记录过失败原因
+	followTrade.FailReason = ""
+	followTrade.Status = model.FollowTradeStatusSuccess
+	return nil
+}
+
+// reconcileByBalance 发送兑换后没来得及保存交易哈希，同一代币同时只有一个未结束的跟单，按钱包余额判断是否买入。
+// 钱包里可能有以前跟单卖剩的零头，余额达到报价扣除滑点后的数量才算买入
+func reconcileByBalance(followTrade *model.FollowTrade) error {
+	balance, err := eth.Client.GetTokenBalance(followTrade.BuyTokenAddress, followTrade.WalletAddress)
+	if err != nil {
+		return fmt.Errorf("get token balance error: %v", err)
+	}
+	unit := decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))
+	balanceDf := decimal.NewFromBigInt(balance, 0).Div(unit)
+
+	minAmount := decimal.Zero
+	if followTrade.QuotedAmount > 0 {
+		slippage := float64(defaultSlippage)
+		if followAddress, err := getFollowAddress(followTrade.ChainName, followTrade.FollowAddress); err == nil {
+			slippage = followSlippage(followAddress)
+		}
+		minAmount = decimal.NewFromFloat(followTrade.QuotedAmount).Mul(decimal.NewFromFloat(1 - slippage/100))
+	}
+	if balance.Sign() <= 0 || balanceDf.LessThan(minAmount) {
+		followTrade.Status = model.FollowTradeStatusFail
+		followTrade.FailReason = fmt.Sprintf("reconcile: swap not sent, balance %s less than %s", balanceDf, minAmount)
+		return nil
+	}
+
+	followTrade.WalletAddressBuyAmount, _ = balanceDf.Float64()
+	if followTrade.WalletAddressBuyTime == 0 {
+		followTrade.WalletAddressBuyTime = time.Now().Unix()
+	}
+	followTrade.Status = model.FollowTradeStatusSuccess
+	return nil
+}
+
+// reconcileExit 按卖出交易恢复 exiting 的跟单，卖出成功时补记卖出记录、已卖出数量和收入，收入按报价计算。
+// 没有保存卖出交易哈希时卖出还没发出，回到 confirmed 后下次卖出按链上余额重新计算
+func reconcileExit(followTrade *model.FollowTrade) error {
+	if followTrade.ExitTxHash == "" {
+		followTrade.Status = model.FollowTradeStatusSuccess
+		return nil
+	}
+
+	// 模拟盘发出兑换时已经按报价成交，实盘按卖出交易的回执判断
+	var gas float64
+	if followTrade.IsPaper == 0 {
+		receipt, pending, err := txReceipt(common.HexToHash(followTrade.ExitTxHash))
+		if err != nil || pending {
+			return err
+		}
+		if receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+			log.Infof("ReconcileFollowTradeJob: follow trade %d sell tx %s dropped or failed", followTrade.ID, followTrade.ExitTxHash)
+			followTrade.Status = model.FollowTradeStatusSuccess
+			return nil
+		}
+		gas = util.CalcGasFee(followTrade.ChainName, receipt.EffectiveGasPrice.Int64(), int64(receipt.GasUsed))
+	}
+
+	exit := &model.FollowTradeExit{
+		FollowTradeID: followTrade.ID,
+		ChainName:     followTrade.ChainName,
+		WalletAddress: followTrade.WalletAddress,
+		TokenAddress:  followTrade.BuyTokenAddress,
+		Reason:        followTrade.ExitingReason,
+		SellAmount:    followTrade.ExitingAmount,
+		ReceiveAmount: followTrade.ExitingReceiveAmount,
+		TxHash:        followTrade.ExitTxHash,
+		Gas:           gas,
+		Status:        model.FollowTradeExitStatusSuccess,
+		ExitTime:      time.Now().Unix(),
+	}
+	if err := model.CreateFollowTradeExit(exit); err != nil {
+		return fmt.Errorf("create follow trade exit error: %v", err)
+	}
+
+	followTrade.ExitedAmount, _ = decimal.NewFromFloat(followTrade.ExitedAmount).Add(decimal.NewFromFloat(followTrade.ExitingAmount)).Float64()
+	followTrade.Proceeds, _ = decimal.NewFromFloat(followTrade.Proceeds).Add(decimal.NewFromFloat(exit.ReceiveAmount)).
+		Sub(decimal.NewFromFloat(gas)).Float64()
+	followTrade.ExitTxHash = ""
+	followTrade.Status = model.FollowTradeStatusSuccess
+
+	balance, err := followTradeBalance(followTrade)
+	if err != nil {
+		log.Errorf("ReconcileFollowTradeJob: follow trade %d: %v", followTrade.ID, err)
+	} else if balance.Sign() <= 0 {
+		finishFollowTrade(followTrade)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package cron
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/internal/control"
+	"smart-money/internal/sizing"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+)
+
+const (
+	exitReasonStopLoss     = "stop_loss"
+	exitReasonTakeProfit   = "take_profit"
+	exitReasonTrailingStop = "trailing_stop"
+	exitReasonMaxHold      = "max_hold"
+	exitReasonExposureCap  = "exposure_cap"
+)
+
+// takeProfitTranche 涨幅达到 gain 时卖出原始持仓的 fraction
+type takeProfitTranche struct {
+	gain     float64
+	fraction float64
+}
+
+func parseTakeProfitLadder(ladder string) ([]*takeProfitTranche, error) {
+	var tranches []*takeProfitTranche
+	for _, item := range strings.Split(ladder, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("take profit tranche %s error", item)
+		}
+		gain, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("take profit tranche %s error: %v", item, err)
+		}
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || fraction <= 0 || fraction > 1 {
+			return nil, fmt.Errorf("take profit tranche %s fraction error", item)
+		}
+		tranches = append(tranches, &takeProfitTranche{gain: gain, fraction: fraction})
+	}
+	sort.Slice(tranches, func(i, j int) bool {
+		return tranches[i].gain < tranches[j].gain
+	})
+	return tranches, nil
+}
+
+// riskPosition 跟单当前的链上持仓和按报价计算的价值
+type riskPosition struct {
+	followTrade *model.FollowTrade
+	amount      float64
+	// 主流币计价
+	price    float64
+	valueUsd float64
+}
+
+// RiskJob 检查所有未卖出的跟单，触发止损、分批止盈、移动止损、最长持仓和敞口上限时卖出
+func RiskJob() error {
+	// 熔断时报价和发送交易都不可靠，跳过本轮
+	if err := control.Allow(control.ActionSell); err != nil {
+		log.Warnf("RiskJob: skip: %v", err)
+		return nil
+	}
+	cfg := config.CFG.Risk
+	ladder, err := parseTakeProfitLadder(cfg.TakeProfitLadder)
+	if err != nil {
+		return fmt.Errorf("RiskJob: %v", err)
+	}
+
+	var followTrades []*model.FollowTrade
+	err = model.GetDB().Where("status = ?", model.FollowTradeStatusSuccess).Find(&followTrades).Error
+	if err != nil {
+		return fmt.Errorf("RiskJob: get follow trades error: %v", err)
+	}
+
+	mainTokenPrices := make(map[string]float64)
+	var positions []*riskPosition
+	for _, followTrade := range followTrades {
+		price, ok := mainTokenPrices[followTrade.ChainName]
+		if !ok {
+			if price, err = sizing.MainTokenPriceUsd(followTrade.ChainName); err != nil {
+				log.Errorf("RiskJob: get %s main token price error: %v", followTrade.ChainName, err)
+				continue
+			}
+			mainTokenPrices[followTrade.ChainName] = price
+		}
+
+		position, err := getRiskPosition(followTrade, price)
+		if err != nil {
+			log.Errorf("RiskJob: follow trade %d: %v", followTrade.ID, err)
+			continue
+		}
+		if position == nil {
+			continue
+		}
+		closed, err := evaluateRisk(position, ladder)
+		if err != nil {
+			log.Errorf("RiskJob: follow trade %d: %v", followTrade.ID, err)
+			continue
+		}
+		if !closed {
+			positions = append(positions, position)
+		}
+	}
+
+	// 模拟盘和实盘的敞口分开计算
+	if cfg.MaxTokenExposureUsd > 0 {
+		trimExposure(positions, cfg.MaxTokenExposureUsd, func(f *model.FollowTrade) string {
+			return fmt.Sprintf("%s:%s:%d", f.ChainName, strings.ToLower(f.BuyTokenAddress), f.IsPaper)
+		})
+	}
+	if cfg.MaxWalletExposureUsd > 0 {
+		trimExposure(positions, cfg.MaxWalletExposureUsd, func(f *model.FollowTrade) string {
+			return fmt.Sprintf("%s:%s:%d", f.ChainName, strings.ToLower(f.WalletAddress), f.IsPaper)
+		})
+	}
+	return nil
+}
+
+// getRiskPosition 余额为 0 时返回 nil
+func getRiskPosition(followTrade *model.FollowTrade, mainTokenPrice float64) (*riskPosition, error) {
+	balance, err := followTradeBalance(followTrade)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() <= 0 {
+		return nil, nil
+	}
+
+	value, _, err := quoteMainToken(followTrade.ChainName, followTrade.BuyTokenAddress, balance)
+	if err != nil {
+		return nil, fmt.Errorf("get quote error: %v", err)
+	}
+	amount, _ := decimal.NewFromBigInt(balance, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(followTrade.BuyTokenDecimal)))).Float64()
+
+	return &riskPosition{
+		followTrade: followTrade,
+		amount:      amount,
+		price:       value / amount,
+		valueUsd:    value * mainTokenPrice,
+	}, nil
+}
+
+// evaluateRisk 按止损、最长持仓、移动止损、分批止盈的顺序检查，返回是否已全部卖出，没有实际卖出时返回 false
+func evaluateRisk(position *riskPosition, ladder []*takeProfitTranche) (bool, error) {
+	cfg := config.CFG.Risk
+	followTrade := position.followTrade
+
+	if cfg.MaxHoldHours > 0 && followTrade.WalletAddressBuyTime > 0 &&
+		time.Since(time.Unix(followTrade.WalletAddressBuyTime, 0)) >= time.Duration(cfg.MaxHoldHours)*time.Hour {
+		exit, err := sellFollowTrade(followTrade, 1, exitReasonMaxHold, "")
+		return exit != nil, err
+	}
+
+	if followTrade.WalletAddressBuyAmount <= 0 || followTrade.WalletAddressSellAmount <= 0 {
+		return false, nil
+	}
+	entryPrice := followTrade.WalletAddressSellAmount / followTrade.WalletAddressBuyAmount
+	gain := position.price/entryPrice - 1
+
+	if cfg.StopLossPercent > 0 && gain <= -cfg.StopLossPercent {
+		log.Infof("RiskJob: follow trade %d gain %.4f hit stop loss", followTrade.ID, gain)
+		exit, err := sellFollowTrade(followTrade, 1, exitReasonStopLoss, "")
+		return exit != nil, err
+	}
+
+	if position.price > followTrade.HighPrice {
+		followTrade.HighPrice = position.price
+		if err := model.GetDB().Model(followTrade).UpdateColumn("high_price", position.price).Error; err != nil {
+			return false, fmt.Errorf("save high price error: %v", err)
+		}
+	}
+	if cfg.TrailingStopPercent > 0 && followTrade.HighPrice >= entryPrice*(1+cfg.TrailingActivatePercent) &&
+		position.price <= followTrade.HighPrice*(1-cfg.TrailingStopPercent) {
+		log.Infof("RiskJob: follow trade %d price %v fell from high %v, hit trailing stop", followTrade.ID, position.price, followTrade.HighPrice)
+		exit, err := sellFollowTrade(followTrade, 1, exitReasonTrailingStop, "")
+		return exit != nil, err
+	}
+
+	// 一次跳过多档时合并成一笔卖出
+	level, tranche := followTrade.TakeProfitLevel, 0.0
+	for level < len(ladder) && gain >= ladder[level].gain {
+		tranche += ladder[level].fraction
+		level++
+	}
+	if level == followTrade.TakeProfitLevel {
+		return false, nil
+	}
+	fraction := math.Min(followTrade.WalletAddressBuyAmount*tranche/position.amount, 1)
+	log.Infof("RiskJob: follow trade %d gain %.4f hit take profit level %d", followTrade.ID, gain, level)
+	exit, err := sellFollowTrade(followTrade, fraction, exitReasonTakeProfit, "")
+	if err != nil {
+		return false, err
+	}
+	// 没有卖出时不推进档位，下次再检查
+	if exit == nil {
+		return false, nil
+	}
+	followTrade.TakeProfitLevel = level
+	if err = model.GetDB().Model(followTrade).UpdateColumn("take_profit_level", level).Error; err != nil {
+		return false, fmt.Errorf("save take profit level error: %v", err)
+	}
+	return fraction >= fullExitFraction, nil
+}
+
+// trimExposure 同一分组的持仓价值超过上限时，每笔跟单按相同比例卖出超出的部分
+func trimExposure(positions []*riskPosition, maxUsd float64, key func(*model.FollowTrade) string) {
+	groups := make(map[string][]*riskPosition)
+	totals := make(map[string]float64)
+	for _, position := range positions {
+		k := key(position.followTrade)
+		groups[k] = append(groups[k], position)
+		totals[k] += position.valueUsd
+	}
+
+	for k, group := range groups {
+		if totals[k] <= maxUsd {
+			continue
+		}
+		fraction := (totals[k] - maxUsd) / totals[k]
+		log.Infof("RiskJob: exposure of %s is %.2f usd, over cap %.2f usd, sell %.4f", k, totals[k], maxUsd, fraction)
+		for _, position := range group {
+			if _, err := sellFollowTrade(position.followTrade, fraction, exitReasonExposureCap, ""); err != nil {
+				log.Errorf("RiskJob: follow trade %d: %v", position.followTrade.ID, err)
+				continue
+			}
+			position.valueUsd *= 1 - fraction
+		}
+	}
+}
+
+// exposureHeadroomUsd 买入前按未卖出跟单的买入金额计算代币和钱包还能增加的敞口，返回 +Inf 表示不限制，模拟盘只统计模拟盘的跟单
+func exposureHeadroomUsd(chainName, walletAddress, tokenAddress string, paper bool) (float64, error) {
+	cfg := config.CFG.Risk
+	isPaper := 0
+	if paper {
+		isPaper = 1
+	}
+	headroom := math.Inf(1)
+	caps := []struct {
+		maxUsd float64
+		query  string
+		arg    string
+	}{
+		{cfg.MaxTokenExposureUsd, "lower(buy_token_address) = ?", strings.ToLower(tokenAddress)},
+		{cfg.MaxWalletExposureUsd, "lower(wallet_addreess) = ?", strings.ToLower(walletAddress)},
+	}
+	for _, c := range caps {
+		if c.maxUsd <= 0 {
+			continue
+		}
+		var exposure struct {
+			Total float64
+		}
+		err := model.GetDB().Model(&model.FollowTrade{}).Select("coalesce(sum(size_usd), 0) as total").
+			Where("chain_name = ? and status in ? and is_paper = ?", chainName, model.FollowTradeOpenStatuses, isPaper).
+			Where(c.query, c.arg).Scan(&exposure).Error
+		if err != nil {
+			return 0, fmt.Errorf("get exposure error: %v", err)
+		}
+		headroom = math.Min(headroom, c.maxUsd-exposure.Total)
+	}
+	return headroom, nil
+}
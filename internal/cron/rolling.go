@@ -0,0 +1,134 @@
+package cron
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"smart-money/config"
+	"smart-money/internal/analysis"
+	"smart-money/internal/collector"
+	"smart-money/internal/hunter"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+)
+
+var (
+	rollingJobLock sync.Mutex
+
+	rollingWindowDays = []int{7, 30, 90}
+)
+
+// RollingPerformanceJob 按 7/30/90 天窗口重新分析关注地址，记录快照，并按降级规则停止跟单
+func RollingPerformanceJob() error {
+	if !rollingJobLock.TryLock() {
+		return nil
+	}
+	defer rollingJobLock.Unlock()
+
+	var followAddresses []*model.FollowAddress
+	err := model.GetDB().Where("status = ?", model.FollowAddressStatusNormal).Find(&followAddresses).Error
+	if err != nil {
+		return fmt.Errorf("RollingPerformanceJob: get follow address error: %v", err)
+	}
+
+	now := time.Now()
+	for _, followAddress := range followAddresses {
+		if config.CFG.Rolling.Recollect {
+			taskName := fmt.Sprintf("rolling_%s_%s", followAddress.Address, now.Format("20060102150405"))
+			ht := hunter.NewHunter(followAddress.ChainName, taskName, collector.Factory("manual_input"),
+				map[string]any{"addresses": []string{followAddress.Address}}, int64(rollingWindowDays[len(rollingWindowDays)-1]*24*3600))
+			if err = ht.Work(); err != nil {
+				log.Errorf("RollingPerformanceJob: recollect address %s error: %v", followAddress.Address, err)
+			}
+		}
+
+		snapshots, err := snapshotFollowAddress(followAddress, now)
+		if err != nil {
+			log.Errorf("RollingPerformanceJob: snapshot address %s error: %v", followAddress.Address, err)
+			continue
+		}
+
+		reason, err := checkDemotion(followAddress, snapshots)
+		if err != nil {
+			log.Errorf("RollingPerformanceJob: check demotion of address %s error: %v", followAddress.Address, err)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+
+		log.Infof("RollingPerformanceJob: stop follow address %s, reason: %s", followAddress.Address, reason)
+		followAddress.Status = model.FollowAddressStatusStop
+		followAddress.StopReason = reason
+		if err = model.SaveFollowAddress(followAddress); err != nil {
+			log.Errorf("RollingPerformanceJob: save follow address error: %v", err)
+		}
+	}
+	return nil
+}
+
+func snapshotFollowAddress(followAddress *model.FollowAddress, now time.Time) (map[int]*model.FollowAddressSnapshot, error) {
+	snapshots := make(map[int]*model.FollowAddressSnapshot)
+	for _, days := range rollingWindowDays {
+		var trades []*model.AddressTrade
+		err := model.GetDB().Where("chain_name = ? and address = ? and first_tx_time >= ?", followAddress.ChainName,
+			followAddress.Address, now.AddDate(0, 0, -days).Unix()).Find(&trades).Error
+		if err != nil {
+			return nil, err
+		}
+
+		summary := analysis.Summarize(analysis.DedupeTrades(trades))
+		snapshot := &model.FollowAddressSnapshot{
+			FollowAddressID: followAddress.ID,
+			ChainName:       followAddress.ChainName,
+			Address:         followAddress.Address,
+			WindowDays:      days,
+			TradeCount:      summary.TradeCount,
+			WinTotal:        summary.WinTotal,
+			WinRate:         summary.WinRate,
+			WinRateLower:    summary.WinRateLower,
+			ProfitTotalUsd:  summary.ProfitTotalUsd,
+			MeanReturn:      summary.MeanReturn,
+			SnapshotTime:    now.Unix(),
+		}
+		if err = model.CreateFollowAddressSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		snapshots[days] = snapshot
+	}
+	return snapshots, nil
+}
+
+// checkDemotion 返回命中的降级规则，未命中返回空字符串
+func checkDemotion(followAddress *model.FollowAddress, snapshots map[int]*model.FollowAddressSnapshot) (string, error) {
+	cfg := config.CFG.Rolling
+
+	if cfg.MinWinRate30d > 0 {
+		snapshot := snapshots[30]
+		if snapshot != nil && snapshot.TradeCount > 0 && snapshot.TradeCount >= cfg.MinTrades30d && snapshot.WinRate < cfg.MinWinRate30d {
+			return fmt.Sprintf("30d win rate %.4f below %.4f, trade count %d", snapshot.WinRate, cfg.MinWinRate30d, snapshot.TradeCount), nil
+		}
+	}
+
+	if cfg.MaxConsecutiveLosses > 0 {
+		var followTrades []*model.FollowTrade
+		err := model.GetDB().Where("chain_name = ? and follow_address = ? and status = ?", followAddress.ChainName,
+			followAddress.Address, model.FollowTradeStatusFinish).Order("id desc").Limit(cfg.MaxConsecutiveLosses).Find(&followTrades).Error
+		if err != nil {
+			return "", err
+		}
+		losses := 0
+		for _, followTrade := range followTrades {
+			if !followTrade.IsLoss() {
+				break
+			}
+			losses++
+		}
+		if losses >= cfg.MaxConsecutiveLosses {
+			return fmt.Sprintf("%d consecutive losing follow trades", losses), nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,311 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"smart-money/config"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const (
+	defaultScanConfirmations = 12
+	defaultScanBatchBlocks   = 500
+)
+
+type scanToken struct {
+	symbol  string
+	decimal uint8
+}
+
+func isDexRouter(chainName string, to *common.Address) bool {
+	if to == nil {
+		return false
+	}
+	_, ok := util.DexRouters[chainName][strings.ToLower(to.Hex())]
+	return ok
+}
+
+type scanTransfer struct {
+	token string
+	from  string
+	to    string
+	value *big.Int
+}
+
+// blockScanner 扫描一段区块内关注地址的 Transfer 日志，识别买入和卖出
+type blockScanner struct {
+	chainName string
+	addresses map[string]*model.FollowAddress
+	tokens    map[string]*scanToken
+	blockTime map[uint64]int64
+}
+
+// scanFollowAddresses 从游标处扫描已确认的区块，每笔交易只会被扫描一次
+func scanFollowAddresses(followAddresses []*model.FollowAddress) error {
+	if len(followAddresses) == 0 {
+		return nil
+	}
+	cfg := config.CFG.Scanner
+	confirmations := cfg.Confirmations
+	if confirmations <= 0 {
+		confirmations = defaultScanConfirmations
+	}
+	batchBlocks := cfg.BatchBlocks
+	if batchBlocks <= 0 {
+		batchBlocks = defaultScanBatchBlocks
+	}
+
+	s := &blockScanner{
+		chainName: config.CFG.Web3.ChainName,
+		addresses: make(map[string]*model.FollowAddress),
+		tokens:    make(map[string]*scanToken),
+		blockTime: make(map[uint64]int64),
+	}
+	addresses := make([]string, 0, len(followAddresses))
+	for _, followAddress := range followAddresses {
+		address := strings.ToLower(followAddress.Address)
+		s.addresses[address] = followAddress
+		addresses = append(addresses, address)
+	}
+
+	ctx := context.Background()
+	client := eth.Client.GetEthClient()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("get block number error: %v", err)
+	}
+	safe := int64(head) - confirmations
+
+	cursor := new(model.ScanCursor)
+	err = model.GetDB().Where("chain_name = ?", s.chainName).First(cursor).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get scan cursor error: %v", err)
+	}
+	if cursor.ID == 0 {
+		// 第一次扫描从当前已确认的区块开始
+		cursor.ChainName = s.chainName
+		return s.saveCursor(cursor, safe)
+	}
+
+	// 确认数不够深时游标所在区块可能被重组，回退后重新扫描
+	header, err := client.HeaderByNumber(ctx, big.NewInt(cursor.BlockHeight))
+	if err != nil {
+		return fmt.Errorf("get block %d header error: %v", cursor.BlockHeight, err)
+	}
+	if cursor.BlockHash != "" && header.Hash().Hex() != cursor.BlockHash {
+		log.Warnf("block scanner: block %d of %s reorged, rewind %d blocks", cursor.BlockHeight, s.chainName, confirmations)
+		cursor.BlockHeight -= confirmations
+	}
+
+	from := cursor.BlockHeight + 1
+	to := from + batchBlocks - 1
+	if to > safe {
+		to = safe
+	}
+	if from > to {
+		return nil
+	}
+
+	logs, err := eth.Client.GetTransferLogs(from, to, addresses)
+	if err != nil {
+		return fmt.Errorf("get transfer logs of block %d-%d error: %v", from, to, err)
+	}
+	signals, err := s.signals(logs)
+	if err != nil {
+		return err
+	}
+
+	for _, signal := range signals {
+		followAddress := s.addresses[signal.FollowAddress]
+		followAddress.LastErc20TxHash = signal.TxHash
+		followAddress.LastErc20TxTime = signal.TxTime
+		if err = model.SaveFollowAddress(followAddress); err != nil {
+			return fmt.Errorf("save follow address error: %v", err)
+		}
+
+		if signal.Side == followSideBuy && config.CFG.Consensus.FollowTrigger {
+			log.Infof("block scanner: follow by consensus signal, skip single address buy: %v", signal.TxHash)
+			continue
+		}
+		if err = dispatchFollowSignal(signal); err != nil {
+			// 发布失败时不前移游标，下次重新扫描，执行者会按交易哈希去重
+			if config.CFG.Stream.Enable {
+				return fmt.Errorf("publish follow signal error: %v", err)
+			}
+			log.Errorf("block scanner: follow tx %s error: %v", signal.TxHash, err)
+		}
+	}
+
+	return s.saveCursor(cursor, to)
+}
+
+func (s *blockScanner) saveCursor(cursor *model.ScanCursor, height int64) error {
+	header, err := eth.Client.GetEthClient().HeaderByNumber(context.Background(), big.NewInt(height))
+	if err != nil {
+		return fmt.Errorf("get block %d header error: %v", height, err)
+	}
+	cursor.BlockHeight = height
+	cursor.BlockHash = header.Hash().Hex()
+	if err = model.SaveScanCursor(cursor); err != nil {
+		return fmt.Errorf("save scan cursor error: %v", err)
+	}
+	return nil
+}
+
+// signals 关注地址自己发出的交易中，付出主流币(或原生币)并收到非主流币的当作买入，反之当作卖出
+func (s *blockScanner) signals(logs []types.Log) ([]*followSignal, error) {
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	var txHashes []common.Hash
+	txLogs := make(map[common.Hash][]*scanTransfer)
+	txBlock := make(map[common.Hash]uint64)
+	for _, l := range logs {
+		// ERC-721 的 Transfer 有 4 个 topic
+		if len(l.Topics) != 3 || l.Removed {
+			continue
+		}
+		if _, ok := txLogs[l.TxHash]; !ok {
+			txHashes = append(txHashes, l.TxHash)
+			txBlock[l.TxHash] = l.BlockNumber
+		}
+		txLogs[l.TxHash] = append(txLogs[l.TxHash], &scanTransfer{
+			token: strings.ToLower(l.Address.Hex()),
+			from:  strings.ToLower(common.BytesToAddress(l.Topics[1].Bytes()).Hex()),
+			to:    strings.ToLower(common.BytesToAddress(l.Topics[2].Bytes()).Hex()),
+			value: new(big.Int).SetBytes(l.Data),
+		})
+	}
+
+	client := eth.Client.GetEthClient()
+	var signals []*followSignal
+	for _, txHash := range txHashes {
+		tx, _, err := client.TransactionByHash(context.Background(), txHash)
+		if err != nil {
+			return nil, fmt.Errorf("get tx %s error: %v", txHash, err)
+		}
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			return nil, fmt.Errorf("get tx %s sender error: %v", txHash, err)
+		}
+		address := strings.ToLower(sender.Hex())
+		if _, ok := s.addresses[address]; !ok {
+			// 别人转入的代币，比如空投
+			continue
+		}
+
+		var (
+			buy, sold, pay *scanTransfer
+			paid           = tx.Value().Sign() > 0
+			receivedMain   bool
+		)
+		for _, t := range txLogs[txHash] {
+			isMain := util.IsMainToken(s.getToken(t.token).symbol)
+			switch {
+			case t.from == address && isMain:
+				paid, pay = true, t
+			case t.from == address:
+				sold = t
+			case t.to == address && isMain:
+				receivedMain = true
+			case t.to == address:
+				buy = t
+			}
+		}
+
+		var (
+			t    *scanTransfer
+			side string
+		)
+		switch {
+		case buy != nil && sold == nil && paid:
+			t, side = buy, followSideBuy
+		case sold != nil && buy == nil && (receivedMain || isDexRouter(s.chainName, tx.To())):
+			// 卖出换成原生币时没有转入的 Transfer 日志，按发往 dex 路由判断
+			t, side = sold, followSideSell
+		default:
+			continue
+		}
+
+		token := s.getToken(t.token)
+		txTime, err := s.getBlockTime(txBlock[txHash])
+		if err != nil {
+			return nil, err
+		}
+		amount := decimal.NewFromBigInt(t.value, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(token.decimal))))
+		signal := &followSignal{
+			ChainName:     s.chainName,
+			FollowAddress: address,
+			TxHash:        strings.ToLower(txHash.Hex()),
+			TxTime:        txTime,
+			TokenAddress:  t.token,
+			Symbol:        token.symbol,
+			Amount:        amount.String(),
+			Side:          side,
+		}
+		if side == followSideBuy {
+			signal.PaySymbol, signal.PayAmount = s.payment(pay, tx)
+		}
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+// payment 买入付出的主流币和数量，没有主流币转出时为原生币
+func (s *blockScanner) payment(pay *scanTransfer, tx *types.Transaction) (string, string) {
+	if pay != nil {
+		token := s.getToken(pay.token)
+		return token.symbol, decimal.NewFromBigInt(pay.value, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(token.decimal)))).String()
+	}
+	mainDecimal := util.MainTokenInfo[s.chainName].Decimal
+	return util.NativeTokenSymbol[s.chainName], decimal.NewFromBigInt(tx.Value(), 0).Div(decimal.NewFromFloat(math.Pow(10, float64(mainDecimal)))).String()
+}
+
+// getToken 非标准代币(比如 bytes32 的 symbol)读取失败时按非主流币、18 位精度处理
+func (s *blockScanner) getToken(tokenAddress string) *scanToken {
+	if token, ok := s.tokens[tokenAddress]; ok {
+		return token
+	}
+	token := &scanToken{decimal: 18}
+	symbol, err := eth.Client.GetTokenSymbol(tokenAddress)
+	if err != nil {
+		log.Warnf("block scanner: get token %s symbol error: %v", tokenAddress, err)
+	}
+	token.symbol = symbol
+	if tokenDecimal, err := eth.Client.GetTokenDecimals(tokenAddress); err == nil {
+		token.decimal = tokenDecimal
+	} else {
+		log.Warnf("block scanner: get token %s decimal error: %v", tokenAddress, err)
+	}
+	s.tokens[tokenAddress] = token
+	return token
+}
+
+func (s *blockScanner) getBlockTime(height uint64) (int64, error) {
+	if t, ok := s.blockTime[height]; ok {
+		return t, nil
+	}
+	header, err := eth.Client.GetEthClient().HeaderByNumber(context.Background(), new(big.Int).SetUint64(height))
+	if err != nil {
+		return 0, fmt.Errorf("get block %d header error: %v", height, err)
+	}
+	s.blockTime[height] = int64(header.Time)
+	return int64(header.Time), nil
+}
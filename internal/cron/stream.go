@@ -0,0 +1,227 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	vredis "github.com/go-redis/redis/v8"
+	"smart-money/config"
+	"smart-money/pkg/log"
+	"smart-money/pkg/model"
+	"smart-money/pkg/redis"
+)
+
+const (
+	defaultStreamKey           = "follow:signal"
+	defaultStreamGroup         = "follow-executor"
+	defaultStreamDeadLetterKey = "follow:signal:dead"
+	defaultClaimIdleSeconds    = 300
+
+	streamReadBlock = 5 * time.Second
+)
+
+var (
+	executorCancel context.CancelFunc
+	executorWg     sync.WaitGroup
+)
+
+func streamKey() string {
+	if k := config.CFG.Stream.Key; k != "" {
+		return k
+	}
+	return defaultStreamKey
+}
+
+func streamGroup() string {
+	if g := config.CFG.Stream.Group; g != "" {
+		return g
+	}
+	return defaultStreamGroup
+}
+
+func streamDeadLetterKey() string {
+	if k := config.CFG.Stream.DeadLetterKey; k != "" {
+		return k
+	}
+	return defaultStreamDeadLetterKey
+}
+
+// publishFollowSignal 把跟单信号发布到 stream
+func publishFollowSignal(signal *followSignal) error {
+	data, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+	args := &vredis.XAddArgs{
+		Stream: streamKey(),
+		Values: map[string]interface{}{"data": string(data)},
+	}
+	if config.CFG.Stream.MaxLen > 0 {
+		args.MaxLen = config.CFG.Stream.MaxLen
+		args.Approx = true
+	}
+	id, err := redis.Client.XAdd(context.Background(), args).Result()
+	if err != nil {
+		return err
+	}
+	log.Infof("publish follow signal %s: address: %s, token: %s, tx: %s", id, signal.FollowAddress, signal.TokenAddress, signal.TxHash)
+	return nil
+}
+
+// startFollowExecutors 创建消费组并启动执行者
+func startFollowExecutors() error {
+	cfg := config.CFG.Stream
+	if !cfg.Enable || cfg.Workers <= 0 {
+		return nil
+	}
+
+	err := redis.Client.XGroupCreateMkStream(context.Background(), streamKey(), streamGroup(), "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create stream group error: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	executorCancel = cancel
+	for i := 0; i < cfg.Workers; i++ {
+		consumer := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		executorWg.Add(1)
+		go func() {
+			defer executorWg.Done()
+			runFollowExecutor(ctx, consumer)
+		}()
+	}
+	log.Infof("started %d follow executors, stream: %s, group: %s", cfg.Workers, streamKey(), streamGroup())
+	return nil
+}
+
+// stopFollowExecutors 停止读取新消息并等待正在处理的消息完成
+func stopFollowExecutors(ctx context.Context) error {
+	if executorCancel == nil {
+		return nil
+	}
+	executorCancel()
+
+	done := make(chan struct{})
+	go func() {
+		executorWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func runFollowExecutor(ctx context.Context, consumer string) {
+	claimIdle := time.Duration(config.CFG.Stream.ClaimIdleSeconds) * time.Second
+	if claimIdle <= 0 {
+		claimIdle = defaultClaimIdleSeconds * time.Second
+	}
+
+	for ctx.Err() == nil {
+		// 先接管其他执行者长时间未确认的消息，比如进程在下单途中退出
+		claimed, _, err := redis.Client.XAutoClaim(ctx, &vredis.XAutoClaimArgs{
+			Stream:   streamKey(),
+			Group:    streamGroup(),
+			MinIdle:  claimIdle,
+			Start:    "0-0",
+			Count:    10,
+			Consumer: consumer,
+		}).Result()
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("follow executor %s: claim pending error: %v", consumer, err)
+		}
+		for _, msg := range claimed {
+			handleFollowMessage(consumer, msg)
+		}
+
+		streams, err := redis.Client.XReadGroup(ctx, &vredis.XReadGroupArgs{
+			Group:    streamGroup(),
+			Consumer: consumer,
+			Streams:  []string{streamKey(), ">"},
+			Count:    1,
+			Block:    streamReadBlock,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, vredis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Errorf("follow executor %s: read stream error: %v", consumer, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				handleFollowMessage(consumer, msg)
+			}
+		}
+	}
+}
+
+// handleFollowMessage 下单并持久化 FollowTrade 后确认消息，失败的消息转入死信 stream
+func handleFollowMessage(consumer string, msg vredis.XMessage) {
+	// 已经开始的下单不受退出信号影响
+	ctx := context.Background()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		data, _ := msg.Values["data"].(string)
+		signal := new(followSignal)
+		if err = json.Unmarshal([]byte(data), signal); err != nil {
+			return fmt.Errorf("decode follow signal error: %v", err)
+		}
+
+		// 消息可能被重复投递，已经跟过的交易直接确认
+		var count int64
+		if signal.Side == followSideSell {
+			err = model.GetDB().Model(&model.FollowTradeExit{}).Where("follow_address_tx_hash = ?", signal.TxHash).Count(&count).Error
+		} else {
+			err = model.GetDB().Model(&model.FollowTrade{}).Where("follow_address = ? and follow_address_buy_tx_hash = ?",
+				signal.FollowAddress, signal.TxHash).Count(&count).Error
+		}
+		if err != nil {
+			return fmt.Errorf("get followed tx error: %v", err)
+		}
+		if count > 0 {
+			log.Infof("follow executor %s: tx %s already followed", consumer, signal.TxHash)
+			return nil
+		}
+
+		return executeFollowSignal(signal)
+	}()
+
+	if err != nil {
+		log.Errorf("follow executor %s: message %s error: %v", consumer, msg.ID, err)
+		values := map[string]interface{}{
+			"source_id": msg.ID,
+			"consumer":  consumer,
+			"error":     err.Error(),
+		}
+		for k, v := range msg.Values {
+			values[k] = v
+		}
+		if dlErr := redis.Client.XAdd(ctx, &vredis.XAddArgs{Stream: streamDeadLetterKey(), Values: values}).Err(); dlErr != nil {
+			// 死信写入失败时不确认，等待其他执行者接管重试
+			log.Errorf("follow executor %s: add message %s to dead letter error: %v", consumer, msg.ID, dlErr)
+			return
+		}
+	}
+
+	if err = redis.Client.XAck(ctx, streamKey(), streamGroup(), msg.ID).Err(); err != nil {
+		log.Errorf("follow executor %s: ack message %s error: %v", consumer, msg.ID, err)
+	}
+}
@@ -1,6 +1,8 @@
 package exchange
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	inch "smart-money/pkg/1inch"
 	"smart-money/pkg/eth"
@@ -10,39 +12,91 @@ type Exchange struct {
 	chainName  string
 	req        *inch.SwapRequest
 	privateKey string
+	// 第一次使用时选择，授权和兑换使用同一个渠道
+	provider SwapProvider
 }
 
 func NewExchange(chainName, privateKey string, req *inch.SwapRequest) *Exchange {
 	return &Exchange{chainName: chainName, req: req, privateKey: privateKey}
 }
 
+// selectProvider 选择扣除 gas 后报价最好的渠道，只启用一个渠道时也报价，报价失败才能切换到备用的 dex 路由
+func (e *Exchange) selectProvider() (SwapProvider, error) {
+	if e.provider != nil {
+		return e.provider, nil
+	}
+	provider, _, err := bestQuote(e.chainName, e.req)
+	if err != nil {
+		return nil, err
+	}
+	e.provider = provider
+	return provider, nil
+}
+
+func (e *Exchange) Quote() (*SwapQuote, error) {
+	provider, quote, err := bestQuote(e.chainName, e.req)
+	if err != nil {
+		return nil, err
+	}
+	e.provider = provider
+	return quote, nil
+}
+
+func (e *Exchange) Provider() string {
+	if e.provider == nil {
+		return ""
+	}
+	return e.provider.Name()
+}
+
 func (e *Exchange) Swap() (common.Hash, error) {
-	swapResp, err := inch.Swap(e.chainName, e.req)
+	provider, err := e.selectProvider()
 	if err != nil {
 		return [32]byte{}, err
 	}
-	return eth.Client.SendTransaction(e.privateKey, &swapResp.Tx)
+	tx, err := provider.BuildSwap(e.chainName, e.req)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if err = fillTxData(e.req.FromAddress, tx); err != nil {
+		return [32]byte{}, err
+	}
+	return eth.Client.SendTransaction(e.privateKey, tx)
 }
 
 func (e *Exchange) CheckAllowance() (string, error) {
-	return inch.CheckAllowance(e.chainName, e.req.FromTokenAddress, e.req.FromAddress)
+	// 原生币不需要授权
+	if isNativeToken(e.req.FromTokenAddress) {
+		return maxAllowance, nil
+	}
+	provider, err := e.selectProvider()
+	if err != nil {
+		return "0", err
+	}
+	allowance, err := provider.Allowance(e.chainName, e.req.FromTokenAddress, e.req.FromAddress)
+	if err != nil {
+		return "0", err
+	}
+	return allowance.String(), nil
 }
 
 func (e *Exchange) ApproveTransaction(isInf bool) (common.Hash, error) {
-	amount := e.req.Amount
-	if isInf {
-		amount = ""
+	provider, err := e.selectProvider()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	var amount *big.Int
+	if !isInf {
+		if amount, err = parseAmount(e.req.Amount); err != nil {
+			return [32]byte{}, err
+		}
 	}
-	approveResp, err := inch.ApproveTransaction(e.chainName, e.req.FromTokenAddress, amount)
+	tx, err := provider.BuildApprove(e.chainName, e.req.FromTokenAddress, amount)
 	if err != nil {
 		return [32]byte{}, err
 	}
-
-	return eth.Client.SendTransaction(e.privateKey, &inch.TxData{
-		From:     e.req.FromAddress,
-		To:       approveResp.To,
-		Data:     approveResp.Data,
-		Value:    approveResp.Value,
-		GasPrice: approveResp.GasPrice,
-	})
+	if err = fillTxData(e.req.FromAddress, tx); err != nil {
+		return [32]byte{}, err
+	}
+	return eth.Client.SendTransaction(e.privateKey, tx)
 }
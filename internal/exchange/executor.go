@@ -0,0 +1,160 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/util"
+)
+
+const defaultPaperSlippage = 0.01
+
+// maxAllowance 模拟盘不需要授权，返回最大授权
+var maxAllowance = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)).String()
+
+// Receipt 交易确认后的结果
+type Receipt struct {
+	// 主流币计价的 gas
+	Gas float64
+	// 实际收到的目标代币数量(未除以精度)，实盘按交易日志中转入钱包的 Transfer 计算，目标代币是主流币或没有日志时为 nil
+	ToAmount *big.Int
+}
+
+// Executor 执行授权和兑换，实盘发送交易，模拟盘按报价成交
+type Executor interface {
+	// Quote 选择报价最好的渠道，之后的授权和兑换使用这个渠道
+	Quote() (*SwapQuote, error)
+	CheckAllowance() (string, error)
+	ApproveTransaction(isInf bool) (common.Hash, error)
+	Swap() (common.Hash, error)
+	Wait(txHash common.Hash) (*Receipt, error)
+	// Provider 授权或兑换使用的兑换渠道，还没选择时为空
+	Provider() string
+}
+
+// NewExecutor paper 为 true 时返回模拟执行器
+func NewExecutor(chainName, privateKey string, req *inch.SwapRequest, paper bool) Executor {
+	if paper {
+		return NewPaperExchange(chainName, req)
+	}
+	return NewExchange(chainName, privateKey, req)
+}
+
+func (e *Exchange) Wait(txHash common.Hash) (*Receipt, error) {
+	receipt, err := eth.Client.WaitTxHashReceipt(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return &Receipt{
+		Gas:      util.CalcGasFee(e.chainName, receipt.EffectiveGasPrice.Int64(), int64(receipt.GasUsed)),
+		ToAmount: receivedAmount(receipt, e.req.ToTokenAddress, e.req.FromAddress),
+	}, nil
+}
+
+// receivedAmount 交易中 token 转入 to 的数量之和
+func receivedAmount(receipt *types.Receipt, token, to string) *big.Int {
+	if !common.IsHexAddress(token) || !common.IsHexAddress(to) {
+		return nil
+	}
+	tokenAddress := common.HexToAddress(token)
+	toTopic := common.BytesToHash(common.HexToAddress(to).Bytes())
+
+	var total *big.Int
+	for _, l := range receipt.Logs {
+		if l.Address != tokenAddress || len(l.Topics) != 3 || l.Topics[0] != eth.TransferEventTopic || l.Topics[2] != toTopic {
+			continue
+		}
+		if total == nil {
+			total = new(big.Int)
+		}
+		total.Add(total, new(big.Int).SetBytes(l.Data))
+	}
+	return total
+}
+
+// PaperExchange 模拟执行器，按启用渠道中最好的报价扣除滑点成交，gas 按报价的预估 gas 和当前 gas price 计算
+type PaperExchange struct {
+	chainName string
+	req       *inch.SwapRequest
+	provider  string
+
+	lock  sync.Mutex
+	fills map[common.Hash]*Receipt
+}
+
+func NewPaperExchange(chainName string, req *inch.SwapRequest) *PaperExchange {
+	return &PaperExchange{chainName: chainName, req: req, fills: make(map[common.Hash]*Receipt)}
+}
+
+func (e *PaperExchange) Quote() (*SwapQuote, error) {
+	provider, quote, err := bestQuote(e.chainName, e.req)
+	if err != nil {
+		return nil, err
+	}
+	e.provider = provider.Name()
+	return quote, nil
+}
+
+func (e *PaperExchange) CheckAllowance() (string, error) {
+	return maxAllowance, nil
+}
+
+func (e *PaperExchange) ApproveTransaction(bool) (common.Hash, error) {
+	return common.Hash{}, nil
+}
+
+func (e *PaperExchange) Provider() string {
+	return e.provider
+}
+
+func (e *PaperExchange) Swap() (common.Hash, error) {
+	provider, quote, err := bestQuote(e.chainName, e.req)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	e.provider = provider.Name()
+	slippage := config.CFG.Paper.Slippage
+	if slippage <= 0 {
+		slippage = defaultPaperSlippage
+	}
+	gasPrice, err := eth.Client.GetEthClient().SuggestGasPrice(context.Background())
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	fill := &Receipt{
+		Gas:      util.CalcGasFee(e.chainName, gasPrice.Int64(), quote.EstimatedGas),
+		ToAmount: decimal.NewFromBigInt(quote.ToAmount, 0).Mul(decimal.NewFromFloat(1 - slippage)).Floor().BigInt(),
+	}
+	// 模拟盘的交易哈希只用于关联成交记录
+	txHash := crypto.Keccak256Hash([]byte(fmt.Sprintf("paper-%s-%s-%s-%s-%d", e.chainName, e.req.FromAddress,
+		e.req.FromTokenAddress, e.req.ToTokenAddress, time.Now().UnixNano())))
+
+	e.lock.Lock()
+	e.fills[txHash] = fill
+	e.lock.Unlock()
+	return txHash, nil
+}
+
+func (e *PaperExchange) Wait(txHash common.Hash) (*Receipt, error) {
+	if txHash == (common.Hash{}) {
+		return &Receipt{}, nil
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	fill, ok := e.fills[txHash]
+	if !ok {
+		return nil, fmt.Errorf("paper tx %s not found", txHash)
+	}
+	return fill, nil
+}
@@ -0,0 +1,63 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+
+	inch "smart-money/pkg/1inch"
+)
+
+type oneInchProvider struct{}
+
+func (p *oneInchProvider) Name() string {
+	return ProviderOneInch
+}
+
+func (p *oneInchProvider) Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	amount, err := parseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := inch.Quote(chainName, req.FromTokenAddress, req.ToTokenAddress, amount)
+	if err != nil {
+		return nil, err
+	}
+	toAmount, ok := new(big.Int).SetString(quote.ToTokenAmount, 10)
+	if !ok {
+		return nil, fmt.Errorf("to token amount %s error", quote.ToTokenAmount)
+	}
+	return &SwapQuote{ToAmount: toAmount, EstimatedGas: int64(quote.EstimatedGas)}, nil
+}
+
+func (p *oneInchProvider) BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error) {
+	swapResp, err := inch.Swap(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	return &swapResp.Tx, nil
+}
+
+func (p *oneInchProvider) Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error) {
+	allowance, err := inch.CheckAllowance(chainName, tokenAddress, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	return parseAmount(allowance)
+}
+
+func (p *oneInchProvider) BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error) {
+	amountStr := ""
+	if amount != nil {
+		amountStr = amount.String()
+	}
+	approveResp, err := inch.ApproveTransaction(chainName, tokenAddress, amountStr)
+	if err != nil {
+		return nil, err
+	}
+	return &inch.TxData{
+		To:       approveResp.To,
+		Data:     approveResp.Data,
+		Value:    approveResp.Value,
+		GasPrice: approveResp.GasPrice,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package exchange
+
+import (
+	"math/big"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/paraswap"
+	"smart-money/pkg/util"
+)
+
+// paraSwapTokenTransferProxy ParaSwap v5 在 eth 和 bsc 上的授权合约，报价还没返回授权合约时使用
+const paraSwapTokenTransferProxy = "0x216b4b4ba9f3e719726886d34a177484278bfcae"
+
+type paraSwapProvider struct {
+	api *paraswap.API
+	// transferProxies 每条链最近一次报价返回的授权合约
+	transferProxies sync.Map
+}
+
+func newParaSwapProvider(host string) *paraSwapProvider {
+	if host == "" {
+		host = paraswap.DefaultHost
+	}
+	return &paraSwapProvider{api: paraswap.NewAPI(host)}
+}
+
+func (p *paraSwapProvider) Name() string {
+	return ProviderParaSwap
+}
+
+func (p *paraSwapProvider) prices(chainName string, req *inch.SwapRequest) (*paraswap.PriceRequest, *paraswap.PriceRoute, error) {
+	srcDecimals, err := tokenDecimals(chainName, req.FromTokenAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	destDecimals, err := tokenDecimals(chainName, req.ToTokenAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	priceReq := &paraswap.PriceRequest{
+		Network:      util.ChainIDMap[chainName],
+		SrcToken:     req.FromTokenAddress,
+		SrcDecimals:  srcDecimals,
+		DestToken:    req.ToTokenAddress,
+		DestDecimals: destDecimals,
+		Amount:       req.Amount,
+		UserAddress:  req.FromAddress,
+	}
+	route, err := p.api.Prices(priceReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	if common.IsHexAddress(route.TokenTransferProxy) {
+		p.transferProxies.Store(chainName, route.TokenTransferProxy)
+	}
+	return priceReq, route, nil
+}
+
+// spender 授权给报价返回的授权合约，Exchange 授权前都会先报价
+func (p *paraSwapProvider) spender(chainName string) string {
+	if proxy, ok := p.transferProxies.Load(chainName); ok {
+		return proxy.(string)
+	}
+	return paraSwapTokenTransferProxy
+}
+
+func (p *paraSwapProvider) Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	_, route, err := p.prices(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	toAmount, err := parseAmount(route.DestAmount)
+	if err != nil {
+		return nil, err
+	}
+	gas, _ := strconv.ParseInt(route.GasCost, 10, 64)
+	return &SwapQuote{ToAmount: toAmount, EstimatedGas: gas}, nil
+}
+
+func (p *paraSwapProvider) BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error) {
+	priceReq, route, err := p.prices(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := p.api.Transactions(&paraswap.TransactionRequest{
+		Network:      priceReq.Network,
+		SrcToken:     priceReq.SrcToken,
+		SrcDecimals:  priceReq.SrcDecimals,
+		DestToken:    priceReq.DestToken,
+		DestDecimals: priceReq.DestDecimals,
+		SrcAmount:    req.Amount,
+		// 滑点单位为 bps
+		Slippage:    int(req.Slippage * 100),
+		UserAddress: req.FromAddress,
+		PriceRoute:  route,
+	})
+	if err != nil {
+		return nil, err
+	}
+	gas, _ := strconv.Atoi(tx.Gas)
+	return &inch.TxData{
+		To:       tx.To,
+		Data:     tx.Data,
+		Value:    tx.Value,
+		Gas:      gas,
+		GasPrice: tx.GasPrice,
+	}, nil
+}
+
+func (p *paraSwapProvider) Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error) {
+	return eth.Client.GetTokenAllowance(tokenAddress, walletAddress, p.spender(chainName))
+}
+
+func (p *paraSwapProvider) BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error) {
+	return approveTx(tokenAddress, p.spender(chainName), amount)
+}
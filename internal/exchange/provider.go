@@ -0,0 +1,283 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"smart-money/config"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/eth/erc20"
+	"smart-money/pkg/log"
+	"smart-money/pkg/util"
+)
+
+const (
+	ProviderOneInch   = "1inch"
+	ProviderZeroX     = "0x"
+	ProviderParaSwap  = "paraswap"
+	ProviderUniswapV2 = "uniswap_v2"
+	ProviderUniswapV3 = "uniswap_v3"
+)
+
+const defaultFallbackProviders = ProviderUniswapV2 + "," + ProviderUniswapV3
+
+// dexSwapDeadline 直接通过 dex 路由兑换时交易的截止时间
+const dexSwapDeadline = 20 * time.Minute
+
+// nativeTokenAddress 聚合器约定的原生币地址
+const nativeTokenAddress = "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+
+// SwapQuote 兑换渠道的报价，ToAmount 未除以精度
+type SwapQuote struct {
+	Provider     string
+	ToAmount     *big.Int
+	EstimatedGas int64
+}
+
+// SwapProvider 兑换渠道，授权和兑换交易由 Exchange 签名发送
+type SwapProvider interface {
+	Name() string
+	Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error)
+	// BuildSwap 构造兑换交易，Value、GasPrice 为空时由 Exchange 补全
+	BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error)
+	// Allowance 钱包授权给渠道的数量
+	Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error)
+	// BuildApprove 构造授权交易，amount 为 nil 时无限授权
+	BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error)
+}
+
+var (
+	providersOnce     sync.Once
+	providers         []SwapProvider
+	fallbackProviders []SwapProvider
+)
+
+func initProviders() {
+	providersOnce.Do(func() {
+		cfg := config.CFG.Swap
+		names := cfg.Providers
+		if strings.TrimSpace(names) == "" {
+			names = ProviderOneInch
+		}
+		providers = newProviders(names)
+		if len(providers) == 0 {
+			providers = append(providers, &oneInchProvider{})
+		}
+
+		fallback := cfg.FallbackProviders
+		if strings.TrimSpace(fallback) == "" {
+			fallback = defaultFallbackProviders
+		}
+		if fallback == "off" {
+			return
+		}
+		// 已经启用的渠道不再作为备用
+		enabled := make(map[string]bool)
+		for _, p := range providers {
+			enabled[p.Name()] = true
+		}
+		for _, p := range newProviders(fallback) {
+			if !enabled[p.Name()] {
+				fallbackProviders = append(fallbackProviders, p)
+			}
+		}
+	})
+}
+
+// newProviders 按逗号分隔的名称顺序创建兑换渠道，忽略未知的名称
+func newProviders(names string) []SwapProvider {
+	cfg := config.CFG.Swap
+	var ps []SwapProvider
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "":
+		case ProviderOneInch:
+			ps = append(ps, &oneInchProvider{})
+		case ProviderZeroX:
+			ps = append(ps, newZeroXProvider(cfg.ZeroXApiKey, cfg.ZeroXUrl))
+		case ProviderParaSwap:
+			ps = append(ps, newParaSwapProvider(cfg.ParaSwapUrl))
+		case ProviderUniswapV2:
+			ps = append(ps, &uniswapV2Provider{router: cfg.UniswapV2Router})
+		case ProviderUniswapV3:
+			ps = append(ps, &uniswapV3Provider{router: cfg.UniswapV3Router, quoter: cfg.UniswapV3Quoter})
+		default:
+			log.Errorf("exchange: unknown swap provider %s", name)
+		}
+	}
+	return ps
+}
+
+// bestQuote 选择启用的渠道中扣除 gas 后收到最多的渠道，都报价失败时从备用的 dex 路由中选择
+func bestQuote(chainName string, req *inch.SwapRequest) (SwapProvider, *SwapQuote, error) {
+	initProviders()
+	provider, quote, err := bestQuoteOf(providers, chainName, req)
+	if err == nil || len(fallbackProviders) == 0 {
+		return provider, quote, err
+	}
+	log.Errorf("exchange: %v, fallback to dex routers", err)
+	provider, quote, fallbackErr := bestQuoteOf(fallbackProviders, chainName, req)
+	if fallbackErr != nil {
+		return nil, nil, errors.Join(err, fallbackErr)
+	}
+	return provider, quote, nil
+}
+
+// Quote 不需要兑换时的报价，比如估值，和兑换一样选择扣除 gas 后最好的渠道
+func Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	_, quote, err := bestQuote(chainName, req)
+	return quote, err
+}
+
+// bestQuoteOf 同时向 ps 报价，选择扣除 gas 后收到最多的渠道
+func bestQuoteOf(ps []SwapProvider, chainName string, req *inch.SwapRequest) (SwapProvider, *SwapQuote, error) {
+	quotes := make([]*SwapQuote, len(ps))
+	errs := make([]error, len(ps))
+
+	var wg sync.WaitGroup
+	for i, p := range ps {
+		wg.Add(1)
+		go func(i int, p SwapProvider) {
+			defer wg.Done()
+			quote, err := p.Quote(chainName, req)
+			if err == nil && (quote.ToAmount == nil || quote.ToAmount.Sign() <= 0) {
+				err = fmt.Errorf("empty quote")
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s quote error: %v", p.Name(), err)
+				return
+			}
+			quote.Provider = p.Name()
+			quotes[i] = quote
+		}(i, p)
+	}
+	wg.Wait()
+
+	gasPrice, err := eth.Client.GetEthClient().SuggestGasPrice(context.Background())
+	if err != nil {
+		log.Errorf("exchange: suggest gas price error: %v", err)
+	}
+	gasValue := gasValueFunc(chainName, req, quotes, gasPrice)
+
+	best, bestNet := -1, new(big.Int)
+	for i, quote := range quotes {
+		if quote == nil {
+			continue
+		}
+		net := new(big.Int).Sub(quote.ToAmount, gasValue(quote.EstimatedGas))
+		log.Infof("exchange: %s quote %s -> %s amount %s: receive %s, gas %d, net %s", quote.Provider,
+			req.FromTokenAddress, req.ToTokenAddress, req.Amount, quote.ToAmount, quote.EstimatedGas, net)
+		if best < 0 || net.Cmp(bestNet) > 0 {
+			best, bestNet = i, net
+		}
+	}
+	if best < 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return ps[best], quotes[best], nil
+}
+
+// gasValueFunc 把 gas 换算成目标代币数量，卖出成主流币时直接扣除，用主流币买入时按报价的最好兑换比例换算，其它情况不扣除
+func gasValueFunc(chainName string, req *inch.SwapRequest, quotes []*SwapQuote, gasPrice *big.Int) func(gas int64) *big.Int {
+	zero := func(int64) *big.Int { return new(big.Int) }
+	if gasPrice == nil {
+		return zero
+	}
+	mainToken := util.MainTokenInfo[chainName].ContractAddress
+	gasWei := func(gas int64) *big.Int { return new(big.Int).Mul(big.NewInt(gas), gasPrice) }
+
+	switch {
+	case strings.EqualFold(req.ToTokenAddress, mainToken):
+		return gasWei
+	case strings.EqualFold(req.FromTokenAddress, mainToken):
+		amountIn, ok := new(big.Int).SetString(req.Amount, 10)
+		if !ok || amountIn.Sign() <= 0 {
+			return zero
+		}
+		maxOut := new(big.Int)
+		for _, quote := range quotes {
+			if quote != nil && quote.ToAmount.Cmp(maxOut) > 0 {
+				maxOut = quote.ToAmount
+			}
+		}
+		return func(gas int64) *big.Int {
+			value := new(big.Int).Mul(gasWei(gas), maxOut)
+			return value.Div(value, amountIn)
+		}
+	}
+	return zero
+}
+
+func isNativeToken(tokenAddress string) bool {
+	return strings.EqualFold(tokenAddress, nativeTokenAddress)
+}
+
+// tokenDecimals 原生币按主流币精度
+func tokenDecimals(chainName, tokenAddress string) (int, error) {
+	if isNativeToken(tokenAddress) {
+		return int(util.MainTokenInfo[chainName].Decimal), nil
+	}
+	decimals, err := eth.Client.GetTokenDecimals(tokenAddress)
+	if err != nil {
+		return 0, err
+	}
+	return int(decimals), nil
+}
+
+var erc20ABI, _ = abi.JSON(strings.NewReader(erc20.Erc20ABI))
+
+// approveTx 授权给 spender 的 erc20 approve 交易
+func approveTx(tokenAddress, spender string, amount *big.Int) (*inch.TxData, error) {
+	if amount == nil {
+		amount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	}
+	data, err := erc20ABI.Pack("approve", common.HexToAddress(spender), amount)
+	if err != nil {
+		return nil, err
+	}
+	return &inch.TxData{To: tokenAddress, Data: hexutil.Encode(data), Value: "0"}, nil
+}
+
+// fillTxData 补全发送交易需要的字段
+func fillTxData(from string, tx *inch.TxData) error {
+	tx.From = from
+	if tx.Value == "" {
+		tx.Value = "0"
+	}
+	if tx.GasPrice == "" {
+		gasPrice, err := eth.Client.GetEthClient().SuggestGasPrice(context.Background())
+		if err != nil {
+			return err
+		}
+		tx.GasPrice = gasPrice.String()
+	}
+	return nil
+}
+
+// minAmountOut 扣除滑点后最少收到的数量，滑点单位为百分比，和 1inch 一致
+func minAmountOut(amount *big.Int, slippage float64) *big.Int {
+	slippageBps := big.NewInt(int64(slippage * 100))
+	out := new(big.Int).Mul(amount, new(big.Int).Sub(big.NewInt(10000), slippageBps))
+	out.Div(out, big.NewInt(10000))
+	if out.Sign() < 0 {
+		return new(big.Int)
+	}
+	return out
+}
+
+func parseAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("amount %s error", amount)
+	}
+	return value, nil
+}
@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/eth/uniswapv2"
+	"smart-money/pkg/util"
+)
+
+// v2 兑换不返回预估 gas，按经验值计算
+const uniswapV2SwapGas = 200000
+
+var uniswapV2RouterABI, _ = uniswapv2.UniswapV2RouterMetaData.GetAbi()
+
+// uniswapV2Provider 直接通过 uniswap v2 类路由合约报价和兑换，不依赖聚合器 api，可以兑换聚合器还没收录的新币
+type uniswapV2Provider struct {
+	router string
+}
+
+func (p *uniswapV2Provider) Name() string {
+	return ProviderUniswapV2
+}
+
+func (p *uniswapV2Provider) routerAddress(chainName string) (string, error) {
+	router := p.router
+	if router == "" {
+		router = util.V2Routers[chainName]
+	}
+	if router == "" {
+		return "", fmt.Errorf("chain %s not supported", chainName)
+	}
+	if !common.IsHexAddress(router) {
+		return "", fmt.Errorf("uniswap v2 router %s error", router)
+	}
+	return router, nil
+}
+
+// path 原生币换成包装代币，两边都不是包装代币时经过包装代币兑换
+func (p *uniswapV2Provider) path(chainName string, req *inch.SwapRequest) ([]common.Address, error) {
+	wrapped := util.WrappedNativeToken[chainName]
+	if wrapped == "" {
+		return nil, fmt.Errorf("chain %s not supported", chainName)
+	}
+	from, to := req.FromTokenAddress, req.ToTokenAddress
+	if isNativeToken(from) {
+		from = wrapped
+	}
+	if isNativeToken(to) {
+		to = wrapped
+	}
+	if strings.EqualFold(from, wrapped) || strings.EqualFold(to, wrapped) {
+		return []common.Address{common.HexToAddress(from), common.HexToAddress(to)}, nil
+	}
+	return []common.Address{common.HexToAddress(from), common.HexToAddress(wrapped), common.HexToAddress(to)}, nil
+}
+
+func (p *uniswapV2Provider) amountOut(chainName string, req *inch.SwapRequest) (*big.Int, []common.Address, error) {
+	router, err := p.routerAddress(chainName)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := p.path(chainName, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	amountIn, err := parseAmount(req.Amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	amounts, err := eth.Client.GetV2AmountsOut(router, amountIn, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return amounts[len(amounts)-1], path, nil
+}
+
+func (p *uniswapV2Provider) Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	toAmount, _, err := p.amountOut(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	return &SwapQuote{ToAmount: toAmount, EstimatedGas: uniswapV2SwapGas}, nil
+}
+
+func (p *uniswapV2Provider) BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error) {
+	router, err := p.routerAddress(chainName)
+	if err != nil {
+		return nil, err
+	}
+	toAmount, path, err := p.amountOut(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	amountIn, err := parseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	amountOutMin := minAmountOut(toAmount, req.Slippage)
+	receiver := common.HexToAddress(req.FromAddress)
+	deadline := big.NewInt(time.Now().Add(dexSwapDeadline).Unix())
+
+	var data []byte
+	value := "0"
+	switch {
+	case isNativeToken(req.FromTokenAddress):
+		data, err = uniswapV2RouterABI.Pack("swapExactETHForTokensSupportingFeeOnTransferTokens", amountOutMin, path, receiver, deadline)
+		value = amountIn.String()
+	case isNativeToken(req.ToTokenAddress):
+		data, err = uniswapV2RouterABI.Pack("swapExactTokensForETHSupportingFeeOnTransferTokens", amountIn, amountOutMin, path, receiver, deadline)
+	default:
+		data, err = uniswapV2RouterABI.Pack("swapExactTokensForTokensSupportingFeeOnTransferTokens", amountIn, amountOutMin, path, receiver, deadline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inch.TxData{To: router, Data: hexutil.Encode(data), Value: value}, nil
+}
+
+func (p *uniswapV2Provider) Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error) {
+	router, err := p.routerAddress(chainName)
+	if err != nil {
+		return nil, err
+	}
+	return eth.Client.GetTokenAllowance(tokenAddress, walletAddress, router)
+}
+
+func (p *uniswapV2Provider) BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error) {
+	router, err := p.routerAddress(chainName)
+	if err != nil {
+		return nil, err
+	}
+	return approveTx(tokenAddress, router, amount)
+}
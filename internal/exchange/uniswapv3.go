@@ -0,0 +1,216 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/eth/uniswapv3"
+	"smart-money/pkg/util"
+)
+
+const (
+	// v3 兑换不返回预估 gas，按经验值计算，每多一跳增加 uniswapV3HopGas
+	uniswapV3SwapGas = 150000
+	uniswapV3HopGas  = 80000
+)
+
+// uniswapV3Fees 依次尝试的池子费率
+var uniswapV3Fees = []uint32{100, 500, 3000, 10000}
+
+var uniswapV3RouterABI, _ = uniswapv3.SwapRouterMetaData.GetAbi()
+
+// uniswapV3Provider 直接通过 uniswap v3 SwapRouter 兑换，用 Quoter 选择每一跳报价最好的费率
+type uniswapV3Provider struct {
+	router string
+	quoter string
+}
+
+// uniswapV3Route 编码后的兑换路径和报价
+type uniswapV3Route struct {
+	path      []byte
+	hops      int
+	amountOut *big.Int
+}
+
+func (p *uniswapV3Provider) Name() string {
+	return ProviderUniswapV3
+}
+
+func (p *uniswapV3Provider) addresses(chainName string) (string, string, error) {
+	router, quoter := p.router, p.quoter
+	if router == "" {
+		router = util.V3Routers[chainName]
+	}
+	if quoter == "" {
+		quoter = util.V3Quoters[chainName]
+	}
+	if router == "" || quoter == "" {
+		return "", "", fmt.Errorf("chain %s not supported", chainName)
+	}
+	if !common.IsHexAddress(router) || !common.IsHexAddress(quoter) {
+		return "", "", fmt.Errorf("uniswap v3 router %s or quoter %s error", router, quoter)
+	}
+	return router, quoter, nil
+}
+
+// encodeV3Path v3 路径编码为 token(20 字节) fee(3 字节) token ...
+func encodeV3Path(tokens []common.Address, fees []uint32) []byte {
+	path := make([]byte, 0, len(tokens)*20+len(fees)*3)
+	for i, token := range tokens {
+		path = append(path, token.Bytes()...)
+		if i < len(fees) {
+			path = append(path, byte(fees[i]>>16), byte(fees[i]>>8), byte(fees[i]))
+		}
+	}
+	return path
+}
+
+// bestHop 单跳报价最好的费率，池子不存在时报价会 revert，跳过
+func bestHop(quoter string, tokenIn, tokenOut common.Address, amountIn *big.Int) (uint32, *big.Int, error) {
+	var bestFee uint32
+	var bestOut *big.Int
+	var lastErr error
+	for _, fee := range uniswapV3Fees {
+		out, err := eth.Client.QuoteV3ExactInput(quoter, encodeV3Path([]common.Address{tokenIn, tokenOut}, []uint32{fee}), amountIn)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if bestOut == nil || out.Cmp(bestOut) > 0 {
+			bestFee, bestOut = fee, out
+		}
+	}
+	if bestOut == nil {
+		return 0, nil, fmt.Errorf("no v3 pool for %s -> %s: %v", tokenIn.Hex(), tokenOut.Hex(), lastErr)
+	}
+	return bestFee, bestOut, nil
+}
+
+// route 两边都不是包装代币时比较直接兑换和经过包装代币兑换，原生币换成包装代币
+func (p *uniswapV3Provider) route(chainName string, req *inch.SwapRequest) (*uniswapV3Route, error) {
+	_, quoter, err := p.addresses(chainName)
+	if err != nil {
+		return nil, err
+	}
+	wrapped := util.WrappedNativeToken[chainName]
+	if wrapped == "" {
+		return nil, fmt.Errorf("chain %s not supported", chainName)
+	}
+	amountIn, err := parseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	from, to := req.FromTokenAddress, req.ToTokenAddress
+	if isNativeToken(from) {
+		from = wrapped
+	}
+	if isNativeToken(to) {
+		to = wrapped
+	}
+	tokenIn, tokenOut, weth := common.HexToAddress(from), common.HexToAddress(to), common.HexToAddress(wrapped)
+
+	var best *uniswapV3Route
+	fee, out, directErr := bestHop(quoter, tokenIn, tokenOut, amountIn)
+	if directErr == nil {
+		best = &uniswapV3Route{path: encodeV3Path([]common.Address{tokenIn, tokenOut}, []uint32{fee}), hops: 1, amountOut: out}
+	}
+	if strings.EqualFold(from, wrapped) || strings.EqualFold(to, wrapped) {
+		return best, directErr
+	}
+
+	fee0, mid, err := bestHop(quoter, tokenIn, weth, amountIn)
+	if err != nil {
+		return best, directErr
+	}
+	fee1, out, err := bestHop(quoter, weth, tokenOut, mid)
+	if err != nil {
+		return best, directErr
+	}
+	if best == nil || out.Cmp(best.amountOut) > 0 {
+		best = &uniswapV3Route{path: encodeV3Path([]common.Address{tokenIn, weth, tokenOut}, []uint32{fee0, fee1}), hops: 2, amountOut: out}
+	}
+	return best, nil
+}
+
+func (p *uniswapV3Provider) Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	route, err := p.route(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	return &SwapQuote{ToAmount: route.amountOut, EstimatedGas: int64(uniswapV3SwapGas + uniswapV3HopGas*(route.hops-1))}, nil
+}
+
+// BuildSwap 卖出成原生币时先把包装代币转给路由，再由 unwrapWETH9 换成原生币转给钱包
+func (p *uniswapV3Provider) BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error) {
+	router, _, err := p.addresses(chainName)
+	if err != nil {
+		return nil, err
+	}
+	route, err := p.route(chainName, req)
+	if err != nil {
+		return nil, err
+	}
+	amountIn, err := parseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	amountOutMin := minAmountOut(route.amountOut, req.Slippage)
+	receiver := common.HexToAddress(req.FromAddress)
+
+	params := uniswapv3.ISwapRouterExactInputParams{
+		Path:             route.path,
+		Recipient:        receiver,
+		Deadline:         big.NewInt(time.Now().Add(dexSwapDeadline).Unix()),
+		AmountIn:         amountIn,
+		AmountOutMinimum: amountOutMin,
+	}
+	value := "0"
+	if isNativeToken(req.FromTokenAddress) {
+		value = amountIn.String()
+	}
+
+	var data []byte
+	if isNativeToken(req.ToTokenAddress) {
+		params.Recipient = common.HexToAddress(router)
+		swapData, err := uniswapV3RouterABI.Pack("exactInput", params)
+		if err != nil {
+			return nil, err
+		}
+		unwrapData, err := uniswapV3RouterABI.Pack("unwrapWETH9", amountOutMin, receiver)
+		if err != nil {
+			return nil, err
+		}
+		data, err = uniswapV3RouterABI.Pack("multicall", [][]byte{swapData, unwrapData})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err = uniswapV3RouterABI.Pack("exactInput", params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &inch.TxData{To: router, Data: hexutil.Encode(data), Value: value}, nil
+}
+
+func (p *uniswapV3Provider) Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error) {
+	router, _, err := p.addresses(chainName)
+	if err != nil {
+		return nil, err
+	}
+	return eth.Client.GetTokenAllowance(tokenAddress, walletAddress, router)
+}
+
+func (p *uniswapV3Provider) BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error) {
+	router, _, err := p.addresses(chainName)
+	if err != nil {
+		return nil, err
+	}
+	return approveTx(tokenAddress, router, amount)
+}
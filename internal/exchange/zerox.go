@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/zerox"
+)
+
+// zeroXExchangeProxy 0x 在 eth 和 bsc 上的授权合约
+const zeroXExchangeProxy = "0xdef1c0ded9bec7f1a1670819833240f027b25eff"
+
+type zeroXProvider struct {
+	apiKey string
+	host   string
+
+	lock sync.Mutex
+	apis map[string]*zerox.API
+}
+
+func newZeroXProvider(apiKey, host string) *zeroXProvider {
+	return &zeroXProvider{apiKey: apiKey, host: host, apis: make(map[string]*zerox.API)}
+}
+
+func (p *zeroXProvider) Name() string {
+	return ProviderZeroX
+}
+
+// api 未配置地址时按链选择官方地址
+func (p *zeroXProvider) api(chainName string) (*zerox.API, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if api, ok := p.apis[chainName]; ok {
+		return api, nil
+	}
+	host := p.host
+	if host == "" {
+		host = zerox.DefaultHosts[chainName]
+	}
+	if host == "" {
+		return nil, fmt.Errorf("chain %s not supported", chainName)
+	}
+	api := zerox.NewAPI(p.apiKey, host)
+	p.apis[chainName] = api
+	return api, nil
+}
+
+func (p *zeroXProvider) Quote(chainName string, req *inch.SwapRequest) (*SwapQuote, error) {
+	api, err := p.api(chainName)
+	if err != nil {
+		return nil, err
+	}
+	price, err := api.Price(&zerox.SwapRequest{
+		SellToken:  req.FromTokenAddress,
+		BuyToken:   req.ToTokenAddress,
+		SellAmount: req.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	toAmount, err := parseAmount(price.BuyAmount)
+	if err != nil {
+		return nil, err
+	}
+	gas, _ := strconv.ParseInt(price.EstimatedGas, 10, 64)
+	return &SwapQuote{ToAmount: toAmount, EstimatedGas: gas}, nil
+}
+
+func (p *zeroXProvider) BuildSwap(chainName string, req *inch.SwapRequest) (*inch.TxData, error) {
+	api, err := p.api(chainName)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := api.Quote(&zerox.SwapRequest{
+		SellToken:          req.FromTokenAddress,
+		BuyToken:           req.ToTokenAddress,
+		SellAmount:         req.Amount,
+		TakerAddress:       req.FromAddress,
+		SlippagePercentage: req.Slippage / 100,
+	})
+	if err != nil {
+		return nil, err
+	}
+	gas, _ := strconv.Atoi(quote.Gas)
+	return &inch.TxData{
+		To:       quote.To,
+		Data:     quote.Data,
+		Value:    quote.Value,
+		Gas:      gas,
+		GasPrice: quote.GasPrice,
+	}, nil
+}
+
+func (p *zeroXProvider) Allowance(chainName, tokenAddress, walletAddress string) (*big.Int, error) {
+	return eth.Client.GetTokenAllowance(tokenAddress, walletAddress, zeroXExchangeProxy)
+}
+
+func (p *zeroXProvider) BuildApprove(chainName, tokenAddress string, amount *big.Int) (*inch.TxData, error) {
+	return approveTx(tokenAddress, zeroXExchangeProxy, amount)
+}
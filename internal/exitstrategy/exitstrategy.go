@@ -0,0 +1,138 @@
+package exitstrategy
+
+import (
+	"fmt"
+
+	"smart-money/config"
+	"smart-money/pkg/model"
+)
+
+const (
+	StrategySellPrincipal = "sell_principal"
+	StrategyExitAll       = "exit_all"
+	StrategyNone          = "none"
+
+	ReasonSellPrincipal = "sell_principal"
+	ReasonExitAll       = "exit_all"
+
+	defaultPrincipalMultiple = 2
+)
+
+// Position 跟单当前的链上持仓
+type Position struct {
+	FollowTrade *model.FollowTrade
+	// 持仓数量(已除以精度)
+	Amount float64
+}
+
+// Quote 按实时报价卖出全部持仓能收到的主流币数量
+type Quote struct {
+	Value float64
+}
+
+// SellInstruction 卖出持仓的 Fraction 部分
+type SellInstruction struct {
+	Fraction float64
+	Reason   string
+	// 卖出的是本金，成交后标记跟单已出本
+	Principal bool
+}
+
+// ExitStrategy 根据持仓、实时报价和卖出需要的 gas(主流币)决定卖出，不卖出时返回空
+type ExitStrategy interface {
+	Name() string
+	Evaluate(position *Position, quote *Quote, sellGas float64) []*SellInstruction
+}
+
+// Check 检查策略名是否存在，空字符串表示使用全局配置
+func Check(name string) bool {
+	switch name {
+	case "", StrategySellPrincipal, StrategyExitAll, StrategyNone:
+		return true
+	}
+	return false
+}
+
+// New 创建退出策略，name 为空时使用全局配置，value 为策略参数，0 时使用全局配置
+func New(name string, value float64) (ExitStrategy, error) {
+	if name == "" {
+		name = config.CFG.Exit.Strategy
+	}
+	switch name {
+	case "", StrategySellPrincipal:
+		multiple := value
+		if multiple <= 0 {
+			multiple = config.CFG.Exit.PrincipalMultiple
+		}
+		if multiple <= 0 {
+			multiple = defaultPrincipalMultiple
+		}
+		return &sellPrincipal{multiple: multiple}, nil
+	case StrategyExitAll:
+		multiple := value
+		if multiple <= 0 {
+			multiple = config.CFG.Exit.PrincipalMultiple
+		}
+		if multiple <= 0 {
+			multiple = defaultPrincipalMultiple
+		}
+		return &exitAll{multiple: multiple}, nil
+	case StrategyNone:
+		return none{}, nil
+	default:
+		return nil, fmt.Errorf("unknown exit strategy %s", name)
+	}
+}
+
+// sellPrincipal 持仓价值达到成本的 multiple 倍时卖出成本对应的部分，剩下的利润继续持有
+type sellPrincipal struct {
+	multiple float64
+}
+
+func (s *sellPrincipal) Name() string { return StrategySellPrincipal }
+
+func (s *sellPrincipal) Evaluate(position *Position, quote *Quote, sellGas float64) []*SellInstruction {
+	followTrade := position.FollowTrade
+	if followTrade.IsSellPrincipal == 1 || quote.Value <= 0 {
+		return nil
+	}
+
+	// 成本包括买入金额、买入和卖出的 gas
+	cost := followTrade.WalletAddressSellAmount + followTrade.WalletAddressBuyGas + sellGas
+	if quote.Value < cost*s.multiple {
+		return nil
+	}
+	return []*SellInstruction{{
+		Fraction:  cost / quote.Value,
+		Reason:    ReasonSellPrincipal,
+		Principal: true,
+	}}
+}
+
+// exitAll 已卖出的收入加上持仓价值达到成本的 multiple 倍时卖出全部持仓，已经出本的跟单也会卖出剩下的部分
+type exitAll struct {
+	multiple float64
+}
+
+func (s *exitAll) Name() string { return StrategyExitAll }
+
+func (s *exitAll) Evaluate(position *Position, quote *Quote, sellGas float64) []*SellInstruction {
+	followTrade := position.FollowTrade
+	if quote.Value <= sellGas {
+		return nil
+	}
+	if followTrade.Proceeds+quote.Value-sellGas < followTrade.Cost()*s.multiple {
+		return nil
+	}
+	return []*SellInstruction{{
+		Fraction: 1,
+		Reason:   ReasonExitAll,
+	}}
+}
+
+// none 只靠跟随卖出和风控退出
+type none struct{}
+
+func (none) Name() string { return StrategyNone }
+
+func (none) Evaluate(*Position, *Quote, float64) []*SellInstruction { return nil }
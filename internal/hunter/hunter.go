@@ -30,6 +30,7 @@ type Hunter struct {
 	collector       ccllector.Collector
 	collectorParams map[string]any
 	collectDuration int64
+	launches        map[string]*launchEvent
 }
 
 func NewHunter(chainName, taskName string, collector ccllector.Collector, collectorParams map[string]any, collectorSeconds int64) *Hunter {
@@ -40,6 +41,7 @@ func NewHunter(chainName, taskName string, collector ccllector.Collector, collec
 		db:              model.GetDB(),
 		collectorParams: collectorParams,
 		collectDuration: collectorSeconds,
+		launches:        make(map[string]*launchEvent),
 	}
 }
 
@@ -137,8 +139,8 @@ func (h *Hunter) getBuyTokens(address string) ([]string, error) {
 						return err
 					}
 					df := holdingAmountDf.Mul(decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal))))
-					amount := df.BigInt().Int64()
-					if amount < 0 {
+					amount := df.BigInt()
+					if amount.Sign() < 0 {
 						continue
 					}
 
@@ -289,6 +291,10 @@ func (h *Hunter) recordTokenTransactionOfAddress(address, tokenAddress string) e
 						SellAddress: sellToken.TokenContractAddress,
 						BuySymbol:   buyToken.Symbol,
 						SellSymbol:  sellToken.Symbol,
+						PoolAddress: buyTxFrom,
+					}
+					if len(detail.OutputDetails) > 0 {
+						tt.RouterAddress = detail.OutputDetails[0].OutputHash
 					}
 					txTime, _ := strconv.Atoi(tx.TransactionTime)
 					tt.TxTime = uint64(txTime)
@@ -462,5 +468,9 @@ func (h *Hunter) makeAddressTrader(address, token string) (*model.AddressTrade,
 	addressTrade.SellTotalUsd, _ = sellTotalUsd.Float64()
 	addressTrade.Profit = addressTrade.SellTotalUsd - addressTrade.BuyTotalUsd
 
+	if err = h.fillEntryTiming(addressTrade, buyTxs[0]); err != nil {
+		log.Warnf("address: %s, token: %s, fill entry timing error: %v", address, token, err)
+	}
+
 	return addressTrade, nil
 }
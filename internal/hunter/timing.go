@@ -0,0 +1,102 @@
+package hunter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/model"
+	"smart-money/pkg/oklink"
+	"smart-money/pkg/util"
+)
+
+type launchEvent struct {
+	blockHeight int64
+	txTime      uint64
+}
+
+// getLaunchEvent 以池子收到该代币的第一笔交易作为首次添加流动性的时间
+func (h *Hunter) getLaunchEvent(poolAddress, tokenAddress string) (*launchEvent, error) {
+	key := strings.ToLower(poolAddress + tokenAddress)
+	if le, ok := h.launches[key]; ok {
+		return le, nil
+	}
+
+	resp, err := oklink.Api.GetToken20TransactionListByAddressAndToken(h.chainName, poolAddress, tokenAddress, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 || len(resp.Data[0].TransactionLists) == 0 {
+		return nil, fmt.Errorf("pool %s has no tx of token %s", poolAddress, tokenAddress)
+	}
+
+	// 列表按时间倒序，最后一页最后一条就是最早的交易
+	totalPage, _ := strconv.Atoi(resp.Data[0].TotalPage)
+	if totalPage > 1 {
+		resp, err = oklink.Api.GetToken20TransactionListByAddressAndToken(h.chainName, poolAddress, tokenAddress, totalPage, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) == 0 || len(resp.Data[0].TransactionLists) == 0 {
+			return nil, fmt.Errorf("pool %s has no tx of token %s in page %d", poolAddress, tokenAddress, totalPage)
+		}
+	}
+
+	txs := resp.Data[0].TransactionLists
+	first := txs[len(txs)-1]
+	height, _ := strconv.Atoi(first.Height)
+	txTime, _ := strconv.Atoi(first.TransactionTime)
+	le := &launchEvent{blockHeight: int64(height), txTime: uint64(txTime)}
+	h.launches[key] = le
+	return le, nil
+}
+
+// fillEntryTiming 计算首次买入距离代币上线的区块数、分钟数和买入时的市值
+func (h *Hunter) fillEntryTiming(addressTrade *model.AddressTrade, firstBuyTx *model.TokenTransactionCollect) error {
+	addressTrade.EntryBlockHeight = firstBuyTx.BlockHeight
+
+	if firstBuyTx.BuyAmount > 0 {
+		quoteUsd := decimal.NewFromFloat(firstBuyTx.SellAmount)
+		if !util.IsStableToken(firstBuyTx.SellSymbol) {
+			ethPrice := util.GetMainTokenPriceInDate(h.chainName, int64(firstBuyTx.TxTime))
+			quoteUsd = quoteUsd.Mul(decimal.NewFromFloat(ethPrice))
+		}
+		priceDf := quoteUsd.Div(decimal.NewFromFloat(firstBuyTx.BuyAmount))
+		addressTrade.EntryPriceUsd, _ = priceDf.Float64()
+
+		// 只连接了 web3 配置的链，其它链不计算市值
+		if client, err := eth.ClientOf(h.chainName); err == nil {
+			// 优先用买入区块的总供应量，节点没有历史状态时退回当前供应量，之后有增发或销毁的代币市值只是近似值
+			totalSupply, err := client.GetTokenTotalSupplyAt(firstBuyTx.BuyAddress, big.NewInt(firstBuyTx.BlockHeight))
+			if err != nil {
+				if totalSupply, err = client.GetTokenTotalSupply(firstBuyTx.BuyAddress); err != nil {
+					return err
+				}
+			}
+			tokenDecimal, err := client.GetTokenDecimals(firstBuyTx.BuyAddress)
+			if err != nil {
+				return err
+			}
+			supplyDf := decimal.NewFromBigInt(totalSupply, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(tokenDecimal))))
+			addressTrade.EntryMarketCapUsd, _ = supplyDf.Mul(priceDf).Float64()
+		}
+	}
+
+	if firstBuyTx.PoolAddress == "" {
+		return fmt.Errorf("tx %s has no pool address", firstBuyTx.TxHash)
+	}
+	le, err := h.getLaunchEvent(firstBuyTx.PoolAddress, firstBuyTx.BuyAddress)
+	if err != nil {
+		return err
+	}
+	addressTrade.LaunchBlockHeight = le.blockHeight
+	addressTrade.LaunchTxTime = le.txTime
+	addressTrade.EntryBlocksAfterLaunch = firstBuyTx.BlockHeight - le.blockHeight
+	addressTrade.EntryMinutesAfterLaunch = util.TxTime(firstBuyTx.TxTime).Sub(util.TxTime(le.txTime)).Minutes()
+
+	return nil
+}
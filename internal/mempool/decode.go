@@ -0,0 +1,460 @@
+package mempool
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NativeToken 表示链上原生币，和 1inch 的写法一致
+const NativeToken = "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+
+// Swap 从路由合约调用中解码出的兑换
+type Swap struct {
+	Method   string
+	TokenIn  string
+	TokenOut string
+	// AmountIn 为精确输入或最大输入，AmountOut 为精确输出或最小输出
+	AmountIn  *big.Int
+	AmountOut *big.Int
+}
+
+// PoolTokensFunc 查询池子的 token0 和 token1，用于解析 1inch unoswap 的输出代币
+type PoolTokensFunc func(pool string) (string, string, error)
+
+const v2RouterABI = `[
+	{"name":"swapExactETHForTokens","type":"function","inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapExactETHForTokensSupportingFeeOnTransferTokens","type":"function","inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapETHForExactTokens","type":"function","inputs":[{"name":"amountOut","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapExactTokensForTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapExactTokensForTokensSupportingFeeOnTransferTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapTokensForExactTokens","type":"function","inputs":[{"name":"amountOut","type":"uint256"},{"name":"amountInMax","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapExactTokensForETH","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapExactTokensForETHSupportingFeeOnTransferTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]},
+	{"name":"swapTokensForExactETH","type":"function","inputs":[{"name":"amountOut","type":"uint256"},{"name":"amountInMax","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}]}
+]`
+
+// v3RouterABI 包含 SwapRouter 和 SwapRouter02 的兑换方法
+const v3RouterABI = `[
+	{"name":"exactInputSingle","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"tokenIn","type":"address"},{"name":"tokenOut","type":"address"},{"name":"fee","type":"uint24"},{"name":"recipient","type":"address"},{"name":"deadline","type":"uint256"},{"name":"amountIn","type":"uint256"},{"name":"amountOutMinimum","type":"uint256"},{"name":"sqrtPriceLimitX96","type":"uint160"}]}]},
+	{"name":"exactInput","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"path","type":"bytes"},{"name":"recipient","type":"address"},{"name":"deadline","type":"uint256"},{"name":"amountIn","type":"uint256"},{"name":"amountOutMinimum","type":"uint256"}]}]},
+	{"name":"exactOutputSingle","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"tokenIn","type":"address"},{"name":"tokenOut","type":"address"},{"name":"fee","type":"uint24"},{"name":"recipient","type":"address"},{"name":"deadline","type":"uint256"},{"name":"amountOut","type":"uint256"},{"name":"amountInMaximum","type":"uint256"},{"name":"sqrtPriceLimitX96","type":"uint160"}]}]},
+	{"name":"exactOutput","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"path","type":"bytes"},{"name":"recipient","type":"address"},{"name":"deadline","type":"uint256"},{"name":"amountOut","type":"uint256"},{"name":"amountInMaximum","type":"uint256"}]}]},
+	{"name":"exactInputSingle","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"tokenIn","type":"address"},{"name":"tokenOut","type":"address"},{"name":"fee","type":"uint24"},{"name":"recipient","type":"address"},{"name":"amountIn","type":"uint256"},{"name":"amountOutMinimum","type":"uint256"},{"name":"sqrtPriceLimitX96","type":"uint160"}]}]},
+	{"name":"exactInput","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"path","type":"bytes"},{"name":"recipient","type":"address"},{"name":"amountIn","type":"uint256"},{"name":"amountOutMinimum","type":"uint256"}]}]},
+	{"name":"exactOutputSingle","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"tokenIn","type":"address"},{"name":"tokenOut","type":"address"},{"name":"fee","type":"uint24"},{"name":"recipient","type":"address"},{"name":"amountOut","type":"uint256"},{"name":"amountInMaximum","type":"uint256"},{"name":"sqrtPriceLimitX96","type":"uint160"}]}]},
+	{"name":"exactOutput","type":"function","inputs":[{"name":"params","type":"tuple","components":[{"name":"path","type":"bytes"},{"name":"recipient","type":"address"},{"name":"amountOut","type":"uint256"},{"name":"amountInMaximum","type":"uint256"}]}]},
+	{"name":"swapExactTokensForTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"}]},
+	{"name":"swapTokensForExactTokens","type":"function","inputs":[{"name":"amountOut","type":"uint256"},{"name":"amountInMax","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"}]},
+	{"name":"multicall","type":"function","inputs":[{"name":"data","type":"bytes[]"}]},
+	{"name":"multicall","type":"function","inputs":[{"name":"deadline","type":"uint256"},{"name":"data","type":"bytes[]"}]},
+	{"name":"multicall","type":"function","inputs":[{"name":"previousBlockhash","type":"bytes32"},{"name":"data","type":"bytes[]"}]}
+]`
+
+const universalRouterABI = `[
+	{"name":"execute","type":"function","inputs":[{"name":"commands","type":"bytes"},{"name":"inputs","type":"bytes[]"},{"name":"deadline","type":"uint256"}]},
+	{"name":"execute","type":"function","inputs":[{"name":"commands","type":"bytes"},{"name":"inputs","type":"bytes[]"}]}
+]`
+
+// inchRouterABI 包含 1inch AggregationRouter V4/V5 的兑换方法
+const inchRouterABI = `[
+	{"name":"swap","type":"function","inputs":[{"name":"executor","type":"address"},{"name":"desc","type":"tuple","components":[{"name":"srcToken","type":"address"},{"name":"dstToken","type":"address"},{"name":"srcReceiver","type":"address"},{"name":"dstReceiver","type":"address"},{"name":"amount","type":"uint256"},{"name":"minReturnAmount","type":"uint256"},{"name":"flags","type":"uint256"}]},{"name":"permit","type":"bytes"},{"name":"data","type":"bytes"}]},
+	{"name":"swap","type":"function","inputs":[{"name":"caller","type":"address"},{"name":"desc","type":"tuple","components":[{"name":"srcToken","type":"address"},{"name":"dstToken","type":"address"},{"name":"srcReceiver","type":"address"},{"name":"dstReceiver","type":"address"},{"name":"amount","type":"uint256"},{"name":"minReturnAmount","type":"uint256"},{"name":"flags","type":"uint256"},{"name":"permit","type":"bytes"}]},{"name":"data","type":"bytes"}]},
+	{"name":"unoswap","type":"function","inputs":[{"name":"srcToken","type":"address"},{"name":"amount","type":"uint256"},{"name":"minReturn","type":"uint256"},{"name":"pools","type":"uint256[]"}]},
+	{"name":"unoswap","type":"function","inputs":[{"name":"srcToken","type":"address"},{"name":"amount","type":"uint256"},{"name":"minReturn","type":"uint256"},{"name":"pools","type":"bytes32[]"}]},
+	{"name":"uniswapV3Swap","type":"function","inputs":[{"name":"amount","type":"uint256"},{"name":"minReturn","type":"uint256"},{"name":"pools","type":"uint256[]"}]}
+]`
+
+// universal router 命令，见 Commands.sol
+const (
+	universalV3SwapExactIn  = 0x00
+	universalV3SwapExactOut = 0x01
+	universalV2SwapExactIn  = 0x08
+	universalV2SwapExactOut = 0x09
+
+	universalCommandTypeMask = 0x3f
+)
+
+// 1inch pools 参数的高位标志
+const (
+	inchReverseBit  = 255
+	inchWethBit     = 254
+	inchV3UnwrapBit = 253
+)
+
+var (
+	v2Router        = mustParseABI(v2RouterABI)
+	v3Router        = mustParseABI(v3RouterABI)
+	universalRouter = mustParseABI(universalRouterABI)
+	inchRouter      = mustParseABI(inchRouterABI)
+
+	universalV3SwapArgs = mustArguments("address", "uint256", "uint256", "bytes", "bool")
+	universalV2SwapArgs = mustArguments("address", "uint256", "uint256", "address[]", "bool")
+
+	// universal router 用该值表示使用合约内的全部余额
+	universalContractBalance = new(big.Int).Lsh(big.NewInt(1), 255)
+
+	inchPoolAddressMax = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+)
+
+func mustParseABI(s string) abi.ABI {
+	a, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func mustArguments(types ...string) abi.Arguments {
+	args := make(abi.Arguments, 0, len(types))
+	for _, t := range types {
+		typ, err := abi.NewType(t, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		args = append(args, abi.Argument{Type: typ})
+	}
+	return args
+}
+
+// Decode 按路由合约类型解码交易的兑换调用，不是兑换时返回 nil
+func Decode(dex string, tx *types.Transaction, poolTokens PoolTokensFunc) (*Swap, error) {
+	data := tx.Data()
+	if len(data) < 4 {
+		return nil, nil
+	}
+
+	switch {
+	case strings.HasPrefix(dex, "uniswap_universal"):
+		return decodeUniversal(tx.Value(), data)
+	case dex == "uniswap_v3" || dex == "pancakeswap_v3":
+		return decodeV3(tx.Value(), data)
+	case dex == "1inch":
+		return decodeInch(tx.Value(), data, poolTokens)
+	case strings.HasSuffix(dex, "_v2") || dex == "sushiswap":
+		return decodeV2(tx.Value(), data)
+	}
+	return nil, nil
+}
+
+func unpack(a abi.ABI, data []byte) (*abi.Method, []interface{}, error) {
+	method, err := a.MethodById(data[:4])
+	if err != nil {
+		// 不认识的方法，比如授权或添加流动性
+		return nil, nil, nil
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("unpack %s error: %v", method.Name, err)
+	}
+	return method, args, nil
+}
+
+func decodeV2(value *big.Int, data []byte) (*Swap, error) {
+	method, args, err := unpack(v2Router, data)
+	if method == nil || err != nil {
+		return nil, err
+	}
+
+	swap := &Swap{Method: method.RawName}
+	var path []common.Address
+	if strings.HasPrefix(method.RawName, "swapExactETH") || strings.HasPrefix(method.RawName, "swapETH") {
+		// 原生币输入，path[0] 为 WETH
+		swap.AmountIn = value
+		swap.AmountOut = args[0].(*big.Int)
+		path = args[1].([]common.Address)
+	} else {
+		swap.AmountIn = args[0].(*big.Int)
+		swap.AmountOut = args[1].(*big.Int)
+		path = args[2].([]common.Address)
+		// 精确输出的方法第一个参数是输出数量
+		if strings.HasPrefix(method.RawName, "swapTokensForExact") {
+			swap.AmountIn, swap.AmountOut = swap.AmountOut, swap.AmountIn
+		}
+	}
+	if len(path) < 2 {
+		return nil, fmt.Errorf("%s path too short", method.RawName)
+	}
+	swap.TokenIn = hexAddress(path[0])
+	swap.TokenOut = hexAddress(path[len(path)-1])
+	return swap, nil
+}
+
+func decodeV3(value *big.Int, data []byte) (*Swap, error) {
+	method, args, err := unpack(v3Router, data)
+	if method == nil || err != nil {
+		return nil, err
+	}
+
+	switch method.RawName {
+	case "multicall":
+		// multicall 里取第一笔兑换
+		calls := args[len(args)-1].([][]byte)
+		for _, call := range calls {
+			if len(call) < 4 {
+				continue
+			}
+			swap, err := decodeV3(value, call)
+			if err != nil {
+				return nil, err
+			}
+			if swap != nil {
+				return swap, nil
+			}
+		}
+		return nil, nil
+	case "swapExactTokensForTokens", "swapTokensForExactTokens":
+		path := args[2].([]common.Address)
+		if len(path) < 2 {
+			return nil, fmt.Errorf("%s path too short", method.RawName)
+		}
+		swap := &Swap{
+			Method:    method.RawName,
+			TokenIn:   hexAddress(path[0]),
+			TokenOut:  hexAddress(path[len(path)-1]),
+			AmountIn:  args[0].(*big.Int),
+			AmountOut: args[1].(*big.Int),
+		}
+		if method.RawName == "swapTokensForExactTokens" {
+			swap.AmountIn, swap.AmountOut = swap.AmountOut, swap.AmountIn
+		}
+		return swap, nil
+	case "exactInputSingle", "exactOutputSingle":
+		params := args[0]
+		swap := &Swap{Method: method.RawName, TokenIn: hexAddress(addressField(params, "TokenIn")), TokenOut: hexAddress(addressField(params, "TokenOut"))}
+		if method.RawName == "exactInputSingle" {
+			swap.AmountIn, swap.AmountOut = bigField(params, "AmountIn"), bigField(params, "AmountOutMinimum")
+		} else {
+			swap.AmountIn, swap.AmountOut = bigField(params, "AmountInMaximum"), bigField(params, "AmountOut")
+		}
+		return swap, nil
+	case "exactInput", "exactOutput":
+		params := args[0]
+		first, last, err := v3PathEnds(bytesField(params, "Path"))
+		if err != nil {
+			return nil, err
+		}
+		if method.RawName == "exactInput" {
+			return &Swap{Method: method.RawName, TokenIn: first, TokenOut: last,
+				AmountIn: bigField(params, "AmountIn"), AmountOut: bigField(params, "AmountOutMinimum")}, nil
+		}
+		// 精确输出的 path 是反向的
+		return &Swap{Method: method.RawName, TokenIn: last, TokenOut: first,
+			AmountIn: bigField(params, "AmountInMaximum"), AmountOut: bigField(params, "AmountOut")}, nil
+	}
+	return nil, nil
+}
+
+func decodeUniversal(value *big.Int, data []byte) (*Swap, error) {
+	method, args, err := unpack(universalRouter, data)
+	if method == nil || err != nil {
+		return nil, err
+	}
+
+	commands := args[0].([]byte)
+	inputs := args[1].([][]byte)
+	if len(commands) != len(inputs) {
+		return nil, fmt.Errorf("universal router commands and inputs length mismatch")
+	}
+
+	// 多笔兑换时取第一笔的输入和最后一笔的输出
+	var swap *Swap
+	for i, command := range commands {
+		var (
+			tokenIn, tokenOut   string
+			amountIn, amountOut *big.Int
+		)
+		switch command & universalCommandTypeMask {
+		case universalV3SwapExactIn, universalV3SwapExactOut:
+			decoded, err := universalV3SwapArgs.Unpack(inputs[i])
+			if err != nil {
+				return nil, fmt.Errorf("unpack universal v3 swap error: %v", err)
+			}
+			first, last, err := v3PathEnds(decoded[3].([]byte))
+			if err != nil {
+				return nil, err
+			}
+			if command&universalCommandTypeMask == universalV3SwapExactIn {
+				tokenIn, tokenOut = first, last
+				amountIn, amountOut = decoded[1].(*big.Int), decoded[2].(*big.Int)
+			} else {
+				tokenIn, tokenOut = last, first
+				amountIn, amountOut = decoded[2].(*big.Int), decoded[1].(*big.Int)
+			}
+		case universalV2SwapExactIn, universalV2SwapExactOut:
+			decoded, err := universalV2SwapArgs.Unpack(inputs[i])
+			if err != nil {
+				return nil, fmt.Errorf("unpack universal v2 swap error: %v", err)
+			}
+			path := decoded[3].([]common.Address)
+			if len(path) < 2 {
+				return nil, fmt.Errorf("universal v2 swap path too short")
+			}
+			tokenIn, tokenOut = hexAddress(path[0]), hexAddress(path[len(path)-1])
+			if command&universalCommandTypeMask == universalV2SwapExactIn {
+				amountIn, amountOut = decoded[1].(*big.Int), decoded[2].(*big.Int)
+			} else {
+				amountIn, amountOut = decoded[2].(*big.Int), decoded[1].(*big.Int)
+			}
+		default:
+			continue
+		}
+
+		if swap == nil {
+			if amountIn.Cmp(universalContractBalance) == 0 {
+				amountIn = value
+			}
+			swap = &Swap{Method: method.RawName, TokenIn: tokenIn, AmountIn: amountIn}
+		}
+		swap.TokenOut = tokenOut
+		swap.AmountOut = amountOut
+	}
+	return swap, nil
+}
+
+func decodeInch(value *big.Int, data []byte, poolTokens PoolTokensFunc) (*Swap, error) {
+	method, args, err := unpack(inchRouter, data)
+	if method == nil || err != nil {
+		return nil, err
+	}
+
+	switch method.RawName {
+	case "swap":
+		desc := args[1]
+		return &Swap{
+			Method:    method.RawName,
+			TokenIn:   hexAddress(addressField(desc, "SrcToken")),
+			TokenOut:  hexAddress(addressField(desc, "DstToken")),
+			AmountIn:  bigField(desc, "Amount"),
+			AmountOut: bigField(desc, "MinReturnAmount"),
+		}, nil
+	case "unoswap":
+		srcToken := args[0].(common.Address)
+		pools := inchPools(args[3])
+		if len(pools) == 0 {
+			return nil, fmt.Errorf("unoswap has no pool")
+		}
+		tokenIn := hexAddress(srcToken)
+		if srcToken == (common.Address{}) {
+			tokenIn = NativeToken
+		}
+		last := pools[len(pools)-1]
+		tokenOut, err := inchPoolOutput(last, poolTokens)
+		if err != nil {
+			return nil, err
+		}
+		if last.Bit(inchWethBit) == 1 {
+			tokenOut = NativeToken
+		}
+		return &Swap{Method: method.RawName, TokenIn: tokenIn, TokenOut: tokenOut, AmountIn: args[1].(*big.Int), AmountOut: args[2].(*big.Int)}, nil
+	case "uniswapV3Swap":
+		pools := inchPools(args[2])
+		if len(pools) == 0 {
+			return nil, fmt.Errorf("uniswapV3Swap has no pool")
+		}
+		// 输入代币从第一个池子反推，msg.value 大于 0 时为原生币
+		first, last := pools[0], pools[len(pools)-1]
+		tokenIn := NativeToken
+		if value == nil || value.Sign() == 0 {
+			token0, token1, err := poolTokens(hexAddress(common.BigToAddress(new(big.Int).And(first, inchPoolAddressMax))))
+			if err != nil {
+				return nil, err
+			}
+			tokenIn = token0
+			if first.Bit(inchReverseBit) == 1 {
+				tokenIn = token1
+			}
+		}
+		tokenOut, err := inchPoolOutput(last, poolTokens)
+		if err != nil {
+			return nil, err
+		}
+		if last.Bit(inchV3UnwrapBit) == 1 {
+			tokenOut = NativeToken
+		}
+		return &Swap{Method: method.RawName, TokenIn: tokenIn, TokenOut: tokenOut, AmountIn: args[0].(*big.Int), AmountOut: args[1].(*big.Int)}, nil
+	}
+	return nil, nil
+}
+
+// inchPoolOutput 池子的输出代币，方向位为 1 时是 token1 -> token0
+func inchPoolOutput(pool *big.Int, poolTokens PoolTokensFunc) (string, error) {
+	token0, token1, err := poolTokens(hexAddress(common.BigToAddress(new(big.Int).And(pool, inchPoolAddressMax))))
+	if err != nil {
+		return "", err
+	}
+	if pool.Bit(inchReverseBit) == 1 {
+		return token0, nil
+	}
+	return token1, nil
+}
+
+func inchPools(arg interface{}) []*big.Int {
+	switch pools := arg.(type) {
+	case []*big.Int:
+		return pools
+	case [][32]byte:
+		result := make([]*big.Int, 0, len(pools))
+		for _, p := range pools {
+			result = append(result, new(big.Int).SetBytes(p[:]))
+		}
+		return result
+	}
+	return nil
+}
+
+// v3PathEnds 返回 v3 编码路径(token, fee, token, ...)两端的代币
+func v3PathEnds(path []byte) (string, string, error) {
+	if len(path) < 43 || (len(path)-20)%23 != 0 {
+		return "", "", fmt.Errorf("invalid v3 path length %d", len(path))
+	}
+	first := common.BytesToAddress(path[:20])
+	last := common.BytesToAddress(path[len(path)-20:])
+	return hexAddress(first), hexAddress(last), nil
+}
+
+// abi 解码出的 tuple 是匿名结构体，按字段名读取
+func tupleField(tuple interface{}, name string) reflect.Value {
+	v := reflect.ValueOf(tuple)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
+
+func addressField(tuple interface{}, name string) common.Address {
+	if a, ok := tupleField(tuple, name).Interface().(common.Address); ok {
+		return a
+	}
+	return common.Address{}
+}
+
+func bigField(tuple interface{}, name string) *big.Int {
+	if b, ok := tupleField(tuple, name).Interface().(*big.Int); ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+func bytesField(tuple interface{}, name string) []byte {
+	b, _ := tupleField(tuple, name).Interface().([]byte)
+	return b
+}
+
+func hexAddress(a common.Address) string {
+	return strings.ToLower(a.Hex())
+}
+
+// IsNative 判断是否是原生币地址
+func IsNative(token string) bool {
+	return strings.EqualFold(token, NativeToken) || bytes.Equal(common.HexToAddress(token).Bytes(), common.Address{}.Bytes())
+}
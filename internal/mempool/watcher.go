@@ -0,0 +1,155 @@
+package mempool
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/log"
+	"smart-money/pkg/util"
+)
+
+const (
+	reconnectInterval = 3 * time.Second
+	seenTTL           = 10 * time.Minute
+)
+
+// PendingSwap 关注地址发出的、还未确认的兑换交易
+type PendingSwap struct {
+	*Swap
+	ChainName string
+	TxHash    string
+	From      string
+	Router    string
+	Dex       string
+	SeenAt    time.Time
+}
+
+// Watcher 订阅 pending 交易，解码关注地址发往 dex 路由的兑换
+type Watcher struct {
+	chainName string
+	wsAddr    string
+	signer    types.Signer
+	handler   func(*PendingSwap)
+
+	mu        sync.RWMutex
+	addresses map[string]bool
+
+	// 同一笔交易可能被多次广播
+	seen map[common.Hash]time.Time
+}
+
+func NewWatcher(chainName, wsAddr string, chainID int64, handler func(*PendingSwap)) *Watcher {
+	return &Watcher{
+		chainName: chainName,
+		wsAddr:    wsAddr,
+		signer:    types.LatestSignerForChainID(big.NewInt(chainID)),
+		handler:   handler,
+		addresses: make(map[string]bool),
+		seen:      make(map[common.Hash]time.Time),
+	}
+}
+
+// SetAddresses 更新需要监控的地址
+func (w *Watcher) SetAddresses(addresses []string) {
+	m := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		m[strings.ToLower(address)] = true
+	}
+	w.mu.Lock()
+	w.addresses = m
+	w.mu.Unlock()
+}
+
+func (w *Watcher) isWatched(address string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.addresses[address]
+}
+
+// Run 订阅 pending 交易直到 ctx 结束，连接断开后自动重连
+func (w *Watcher) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		txs := make(chan *types.Transaction, 1024)
+		done := make(chan error, 1)
+		subCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			done <- eth.Client.SubscribePendingTransactions(subCtx, w.wsAddr, txs)
+		}()
+
+	loop:
+		for {
+			select {
+			case tx := <-txs:
+				w.handle(tx)
+			case err := <-done:
+				if err != nil && ctx.Err() == nil {
+					log.Errorf("mempool watcher: subscribe pending tx error: %v", err)
+				}
+				break loop
+			}
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+func (w *Watcher) handle(tx *types.Transaction) {
+	if tx.To() == nil {
+		return
+	}
+	router := strings.ToLower(tx.To().Hex())
+	dex, ok := util.DexRouters[w.chainName][router]
+	if !ok {
+		return
+	}
+
+	sender, err := types.Sender(w.signer, tx)
+	if err != nil {
+		return
+	}
+	from := strings.ToLower(sender.Hex())
+	if !w.isWatched(from) {
+		return
+	}
+
+	now := time.Now()
+	if _, ok = w.seen[tx.Hash()]; ok {
+		return
+	}
+	for hash, at := range w.seen {
+		if now.Sub(at) > seenTTL {
+			delete(w.seen, hash)
+		}
+	}
+	w.seen[tx.Hash()] = now
+
+	swap, err := Decode(dex, tx, eth.Client.GetPoolTokens)
+	if err != nil {
+		log.Errorf("mempool watcher: decode tx %s of %s error: %v", tx.Hash(), from, err)
+		return
+	}
+	if swap == nil {
+		return
+	}
+
+	log.Infof("mempool watcher: pending swap %s from %s on %s, %s -> %s", tx.Hash(), from, dex, swap.TokenIn, swap.TokenOut)
+	w.handler(&PendingSwap{
+		Swap:      swap,
+		ChainName: w.chainName,
+		TxHash:    tx.Hash().Hex(),
+		From:      from,
+		Router:    router,
+		Dex:       dex,
+		SeenAt:    now,
+	})
+}
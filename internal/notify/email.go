@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email 通过 SMTP 发送邮件，服务器支持时使用 STARTTLS，没有配置用户名时不认证
+type Email struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func NewEmail(host string, port int, username, password, from string, to []string) *Email {
+	return &Email{host: host, port: port, username: username, password: password, from: from, to: to}
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Notify(ctx context.Context, event *Event) error {
+	if len(e.to) == 0 {
+		return fmt.Errorf("email to is empty")
+	}
+	var auth smtp.Auth
+	if e.username != "" {
+		auth = smtp.PlainAuth("", e.username, e.password, e.host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s\r\n", event.Type, event.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Unix(event.Time, 0).Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(event.Text(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	// smtp.SendMail 不支持 context，超时由 context 控制调用方等待的时间
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(e.host, strconv.Itoa(e.port)), auth, e.from, e.to, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
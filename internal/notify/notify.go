@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-money/config"
+	"smart-money/pkg/log"
+)
+
+const (
+	EventFollowSignal   = "follow_signal"
+	EventBuy            = "buy"
+	EventSell           = "sell"
+	EventFailure        = "failure"
+	EventRisk           = "risk"
+	EventHunterFinished = "hunter_finished"
+	EventLowBalance     = "low_balance"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Event 通知的事件，Data 是事件的明细
+type Event struct {
+	Type    string                 `json:"type"`
+	Title   string                 `json:"title"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	Time    int64                  `json:"time"`
+}
+
+// Text 纯文本格式，用于 telegram 和邮件
+func (e *Event) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s\n", e.Type, e.Title)
+	if e.Message != "" {
+		b.WriteString(e.Message)
+		b.WriteString("\n")
+	}
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %v\n", k, e.Data[k])
+	}
+	b.WriteString(time.Unix(e.Time, 0).Format(time.DateTime))
+	return b.String()
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event *Event) error
+}
+
+// channel 通知渠道和它接收的事件，events 为空时接收全部事件
+type channel struct {
+	notifier Notifier
+	events   map[string]bool
+}
+
+func (c *channel) accept(eventType string) bool {
+	return len(c.events) == 0 || c.events[eventType]
+}
+
+var channels []*channel
+
+// Init 按配置创建开启的通知渠道
+func Init() {
+	channels = nil
+	if cfg := config.CFG.NotifyWebhook; cfg.Enable {
+		register(NewWebhook(cfg.Url, timeout(cfg.Timeout)), cfg.Events)
+	}
+	if cfg := config.CFG.NotifyTelegram; cfg.Enable {
+		register(NewTelegram(cfg.ApiUrl, cfg.BotToken, cfg.ChatID, timeout(cfg.Timeout)), cfg.Events)
+	}
+	if cfg := config.CFG.NotifyEmail; cfg.Enable {
+		register(NewEmail(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From, splitList(cfg.To)), cfg.Events)
+	}
+}
+
+func register(notifier Notifier, events string) {
+	c := &channel{notifier: notifier, events: make(map[string]bool)}
+	for _, event := range splitList(events) {
+		c.events[event] = true
+	}
+	channels = append(channels, c)
+}
+
+// Send 异步发送到接收该事件的渠道，发送失败只记录日志
+func Send(event *Event) {
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+	for _, c := range channels {
+		if !c.accept(event.Type) {
+			continue
+		}
+		go func(c *channel) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := c.notifier.Notify(ctx, event); err != nil {
+				log.Errorf("notify %s event %s error: %v", c.notifier.Name(), event.Type, err)
+			}
+		}(c)
+	}
+}
+
+func timeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
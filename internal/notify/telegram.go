@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/tidwall/gjson"
+)
+
+const defaultTelegramApiUrl = "https://api.telegram.org"
+
+// Telegram 通过 bot 的 sendMessage 接口发送纯文本消息
+type Telegram struct {
+	c        *req.Client
+	apiUrl   string
+	botToken string
+	chatID   string
+}
+
+// NewTelegram apiUrl 为空时使用官方地址
+func NewTelegram(apiUrl, botToken, chatID string, timeout time.Duration) *Telegram {
+	if apiUrl == "" {
+		apiUrl = defaultTelegramApiUrl
+	}
+	return &Telegram{
+		c:        req.C().SetTimeout(timeout),
+		apiUrl:   strings.TrimSuffix(apiUrl, "/"),
+		botToken: botToken,
+		chatID:   chatID,
+	}
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+func (t *Telegram) Notify(ctx context.Context, event *Event) error {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.apiUrl, t.botToken)
+	resp, err := t.c.R().SetContext(ctx).SetBodyJsonMarshal(map[string]any{
+		"chat_id": t.chatID,
+		"text":    event.Text(),
+	}).Post(url)
+	if err != nil {
+		return err
+	}
+	if resp.IsErrorState() || !gjson.Get(resp.String(), "ok").Bool() {
+		return fmt.Errorf("status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	return nil
+}
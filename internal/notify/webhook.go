@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// Webhook 把事件以 JSON POST 到配置的地址
+type Webhook struct {
+	c   *req.Client
+	url string
+}
+
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{c: req.C().SetTimeout(timeout), url: url}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Notify(ctx context.Context, event *Event) error {
+	resp, err := w.c.R().SetContext(ctx).SetBodyJsonMarshal(event).Post(w.url)
+	if err != nil {
+		return err
+	}
+	if resp.IsErrorState() {
+		return fmt.Errorf("status code:%d, content:%v", resp.GetStatusCode(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,495 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/internal/exchange"
+	"smart-money/internal/sizing"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/util"
+)
+
+const (
+	CheckSimulation = "simulation"
+	CheckBuy        = "buy"
+	CheckSell       = "sell"
+	CheckBuyTax     = "buy_tax"
+	CheckSellTax    = "sell_tax"
+	CheckSellQuote  = "sell_quote"
+	CheckOwnerFunc  = "owner_functions"
+	CheckLiquidity  = "liquidity"
+)
+
+const (
+	defaultSimulateAmount = 0.01
+	defaultMaxTax         = 0.1
+	// 模拟得到的税率超过时认为是转账收税代币
+	fotTaxThreshold = 0.005
+	maxSlotProbe    = 30
+	// 二分查找实际收到数量的精度为千分之一，每次模拟约 10 次 eth_call
+	taxSearchBps     = 1000
+	simulateDeadline = time.Hour
+	simulateGas      = 3000000
+	// 模拟买入时给 holder 覆盖的原生币余额为买入数量的倍数，够支付 gas
+	simulateBalanceMultiple = 1000
+	// 同一代币的检查结果缓存时间，避免连续的跟单信号重复模拟
+	reportCacheTTL = 10 * time.Minute
+)
+
+// Failure 一项未通过的检查
+type Failure struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// Report 代币安全检查结果，未通过时序列化后写入跟单的失败原因
+type Report struct {
+	Token    string     `json:"token"`
+	Passed   bool       `json:"passed"`
+	Failures []*Failure `json:"failures,omitempty"`
+	// 无法执行的检查，例如没有 v2 交易对时无法模拟买卖，没有配置 allow_unknown 时视为未通过
+	Unknown      []*Failure `json:"unknown,omitempty"`
+	BuyTax       float64    `json:"buy_tax"`
+	SellTax      float64    `json:"sell_tax"`
+	IsFOT        bool       `json:"is_fot"`
+	Owner        string     `json:"owner,omitempty"`
+	Functions    []string   `json:"functions,omitempty"`
+	LiquidityUsd float64    `json:"liquidity_usd"`
+}
+
+func (r *Report) fail(check, format string, args ...interface{}) {
+	r.Passed = false
+	r.Failures = append(r.Failures, &Failure{Check: check, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) unknown(check, format string, args ...interface{}) {
+	r.Unknown = append(r.Unknown, &Failure{Check: check, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) String() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+var routerABI, _ = abi.JSON(strings.NewReader(`[
+	{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"path","type":"address[]"}],"name":"getAmountsOut","outputs":[{"name":"amounts","type":"uint256[]"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactETHForTokensSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"payable","type":"function"},
+	{"inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"name":"swapExactTokensForETHSupportingFeeOnTransferTokens","outputs":[],"stateMutability":"nonpayable","type":"function"}
+]`))
+
+var tokenABI, _ = abi.JSON(strings.NewReader(`[
+	{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"name":"allowance","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"owner","outputs":[{"name":"","type":"address"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"getOwner","outputs":[{"name":"","type":"address"}],"stateMutability":"view","type":"function"}
+]`))
+
+// ownerFunctions 所有者可以用来增发、拉黑或暂停交易的常见函数
+var ownerFunctions = map[string][]string{
+	"mint": {"mint(address,uint256)", "mint(uint256)", "mintTo(address,uint256)"},
+	"blacklist": {"blacklist(address)", "addToBlacklist(address)", "addBlacklist(address)", "setBlacklist(address,bool)",
+		"blacklistAddress(address,bool)", "setBots(address[])", "addBots(address[])", "setBot(address,bool)"},
+	"pause": {"pause()", "setPaused(bool)", "setTradingEnabled(bool)"},
+}
+
+var (
+	reportsLock sync.Mutex
+	reports     = make(map[string]*cachedReport)
+)
+
+type cachedReport struct {
+	report    *Report
+	checkTime time.Time
+}
+
+// Check 模拟关注地址 holder 买入再卖出代币，并检查合约权限和流动性，同一代币的结果缓存 reportCacheTTL
+func Check(chainName, tokenAddress, holder string) *Report {
+	key := chainName + ":" + strings.ToLower(tokenAddress)
+	reportsLock.Lock()
+	cached, ok := reports[key]
+	reportsLock.Unlock()
+	if ok && time.Since(cached.checkTime) < reportCacheTTL {
+		return cached.report
+	}
+
+	report := check(chainName, tokenAddress, holder)
+	if len(report.Unknown) > 0 && !config.CFG.Safety.AllowUnknown {
+		report.Passed = false
+	}
+	// 节点出错导致的失败不缓存，下次重新检查
+	for _, failure := range report.Failures {
+		if failure.Check == CheckSimulation {
+			return report
+		}
+	}
+	reportsLock.Lock()
+	reports[key] = &cachedReport{report: report, checkTime: time.Now()}
+	reportsLock.Unlock()
+	return report
+}
+
+func check(chainName, tokenAddress, holder string) *Report {
+	cfg := config.CFG.Safety
+	report := &Report{Token: tokenAddress, Passed: true}
+
+	// 模拟使用 web3 配置的节点，其它链的代币无法检查
+	if chainName != config.CFG.Web3.ChainName {
+		report.fail(CheckSimulation, "chain %s does not match web3 chain %s", chainName, config.CFG.Web3.ChainName)
+		return report
+	}
+	router, factory, wrapped := util.V2Routers[chainName], util.V2Factories[chainName], util.WrappedNativeToken[chainName]
+	if router == "" || factory == "" || wrapped == "" {
+		report.fail(CheckSimulation, "chain %s not supported", chainName)
+		return report
+	}
+	s := &simulator{
+		router: common.HexToAddress(router),
+		token:  common.HexToAddress(tokenAddress),
+		weth:   common.HexToAddress(wrapped),
+		holder: common.HexToAddress(holder),
+	}
+
+	pair, err := eth.Client.GetV2Pair(factory, tokenAddress, wrapped)
+	if err != nil {
+		report.fail(CheckSimulation, "get v2 pair error: %v", err)
+		return report
+	}
+	// 只在 v3 等其它池子有流动性的代币无法通过 v2 路由模拟，只检查合约权限
+	if pair == "0x0000000000000000000000000000000000000000" {
+		report.unknown(CheckSimulation, "token %s has no v2 pair with %s", tokenAddress, wrapped)
+		s.checkOwnerFunctions(report)
+		return report
+	}
+
+	simulateAmount := cfg.SimulateAmount
+	if simulateAmount <= 0 {
+		simulateAmount = defaultSimulateAmount
+	}
+	amountIn := decimal.NewFromFloat(simulateAmount).Mul(decimal.NewFromFloat(math.Pow(10, float64(util.MainTokenInfo[chainName].Decimal)))).BigInt()
+
+	received, buyTax, err := s.simulateBuy(amountIn)
+	switch {
+	case eth.IsRevert(err):
+		report.fail(CheckBuy, "buy reverted: %v", err)
+	case err != nil:
+		report.fail(CheckSimulation, "simulate buy error: %v", err)
+	default:
+		report.BuyTax = buyTax
+		if received.Sign() <= 0 {
+			report.fail(CheckBuy, "buy received nothing")
+		}
+	}
+
+	if received != nil && received.Sign() > 0 {
+		sellTax, err := s.simulateSell(received)
+		switch {
+		case eth.IsRevert(err):
+			report.fail(CheckSell, "sell reverted, honeypot: %v", err)
+		case err != nil:
+			report.fail(CheckSimulation, "simulate sell error: %v", err)
+		default:
+			report.SellTax = sellTax
+		}
+	}
+
+	maxBuyTax, maxSellTax := cfg.MaxBuyTax, cfg.MaxSellTax
+	if maxBuyTax <= 0 {
+		maxBuyTax = defaultMaxTax
+	}
+	if maxSellTax <= 0 {
+		maxSellTax = defaultMaxTax
+	}
+	if report.BuyTax > maxBuyTax {
+		report.fail(CheckBuyTax, "buy tax %.4f over %.4f", report.BuyTax, maxBuyTax)
+	}
+	if report.SellTax > maxSellTax {
+		report.fail(CheckSellTax, "sell tax %.4f over %.4f", report.SellTax, maxSellTax)
+	}
+
+	// 转账收税按模拟的税率判断，再用实际兑换使用的渠道报价卖出，聚合器没有收录的代币用 dex 路由报价，都报价失败时无法卖出
+	if received != nil && received.Sign() > 0 {
+		report.IsFOT = report.BuyTax > fotTaxThreshold || report.SellTax > fotTaxThreshold
+		_, err := exchange.Quote(chainName, &inch.SwapRequest{
+			FromTokenAddress: tokenAddress,
+			ToTokenAddress:   util.MainTokenInfo[chainName].ContractAddress,
+			Amount:           received.String(),
+		})
+		if err != nil {
+			report.unknown(CheckSellQuote, "get quote error: %v", err)
+		}
+	}
+
+	s.checkOwnerFunctions(report)
+
+	if cfg.MinLiquidityUsd > 0 {
+		price, err := sizing.MainTokenPriceUsd(chainName)
+		if err == nil {
+			report.LiquidityUsd, err = sizing.PoolLiquidityUsd(chainName, tokenAddress, price)
+		}
+		if err != nil {
+			report.fail(CheckLiquidity, "get liquidity error: %v", err)
+		} else if report.LiquidityUsd < cfg.MinLiquidityUsd {
+			report.fail(CheckLiquidity, "liquidity %.2f usd less than %.2f usd", report.LiquidityUsd, cfg.MinLiquidityUsd)
+		}
+	}
+	return report
+}
+
+// checkOwnerFunctions 未放弃所有权且带有增发、黑名单、暂停函数时不通过
+func (s *simulator) checkOwnerFunctions(report *Report) {
+	if err := s.checkOwner(report); err != nil {
+		report.fail(CheckSimulation, "check owner error: %v", err)
+	} else if len(report.Functions) > 0 && !config.CFG.Safety.AllowOwnerFunctions {
+		report.fail(CheckOwnerFunc, "owner %s can call %s", report.Owner, strings.Join(report.Functions, ","))
+	}
+}
+
+// simulator 通过 v2 路由的 eth_call 模拟交易，状态覆盖给 holder 提供余额和授权
+type simulator struct {
+	router common.Address
+	token  common.Address
+	weth   common.Address
+	holder common.Address
+}
+
+func (s *simulator) call(to common.Address, value *big.Int, data []byte, overrides map[common.Address]eth.OverrideAccount) ([]byte, error) {
+	return eth.Client.CallContractWithOverrides(ethereum.CallMsg{
+		From:  s.holder,
+		To:    &to,
+		Value: value,
+		Gas:   simulateGas,
+		Data:  data,
+	}, overrides)
+}
+
+func (s *simulator) amountOut(amountIn *big.Int, path []common.Address) (*big.Int, error) {
+	data, _ := routerABI.Pack("getAmountsOut", amountIn, path)
+	out, err := s.call(s.router, nil, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	values, err := routerABI.Unpack("getAmountsOut", out)
+	if err != nil {
+		return nil, err
+	}
+	amounts := values[0].([]*big.Int)
+	return amounts[len(amounts)-1], nil
+}
+
+// searchReceived 二分查找交易不 revert 的最大 amountOutMin，即扣税后实际收到的数量
+func searchReceived(expected *big.Int, swap func(amountOutMin *big.Int) error) (*big.Int, error) {
+	if err := swap(big.NewInt(0)); err != nil {
+		return nil, err
+	}
+	bps := func(n int64) *big.Int {
+		return new(big.Int).Div(new(big.Int).Mul(expected, big.NewInt(n)), big.NewInt(taxSearchBps))
+	}
+	lo, hi := int64(0), int64(taxSearchBps)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		err := swap(bps(mid))
+		if err != nil && !eth.IsRevert(err) {
+			return nil, err
+		}
+		if err == nil {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return bps(lo), nil
+}
+
+func tax(expected, received *big.Int) float64 {
+	if expected.Sign() <= 0 {
+		return 0
+	}
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(received), new(big.Float).SetInt(expected)).Float64()
+	return math.Max(0, 1-ratio)
+}
+
+func (s *simulator) deadline() *big.Int {
+	return big.NewInt(time.Now().Add(simulateDeadline).Unix())
+}
+
+// simulateBuy 用原生币买入，返回实际收到的代币数量和买入税
+func (s *simulator) simulateBuy(amountIn *big.Int) (*big.Int, float64, error) {
+	path := []common.Address{s.weth, s.token}
+	expected, err := s.amountOut(amountIn, path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	balance := new(big.Int).Mul(amountIn, big.NewInt(simulateBalanceMultiple))
+	overrides := map[common.Address]eth.OverrideAccount{s.holder: {Balance: (*hexutil.Big)(balance)}}
+	received, err := searchReceived(expected, func(amountOutMin *big.Int) error {
+		data, _ := routerABI.Pack("swapExactETHForTokensSupportingFeeOnTransferTokens", amountOutMin, path, s.holder, s.deadline())
+		_, err := s.call(s.router, amountIn, data, overrides)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return received, tax(expected, received), nil
+}
+
+// simulateSell 卖出买入得到的代币，holder 余额或授权不够时通过覆盖存储补上
+func (s *simulator) simulateSell(amountIn *big.Int) (float64, error) {
+	path := []common.Address{s.token, s.weth}
+	expected, err := s.amountOut(amountIn, path)
+	if err != nil {
+		return 0, err
+	}
+
+	stateDiff := make(map[common.Hash]common.Hash)
+	balance, err := s.tokenCall("balanceOf", nil, s.holder)
+	if err != nil {
+		return 0, err
+	}
+	if balance.Cmp(amountIn) < 0 {
+		slot, err := s.findSlot(amountIn, func(i int64) common.Hash {
+			return mappingSlot(s.holder.Hash(), common.BigToHash(big.NewInt(i)))
+		}, func(i int64) common.Hash {
+			// vyper 的 mapping 存储位置参数顺序相反
+			return mappingSlot(common.BigToHash(big.NewInt(i)), s.holder.Hash())
+		}, "balanceOf", s.holder)
+		if err != nil {
+			return 0, err
+		}
+		stateDiff[slot] = common.BigToHash(amountIn)
+	}
+
+	allowance, err := s.tokenCall("allowance", nil, s.holder, s.router)
+	if err != nil {
+		return 0, err
+	}
+	if allowance.Cmp(amountIn) < 0 {
+		slot, err := s.findSlot(amountIn, func(i int64) common.Hash {
+			return mappingSlot(s.router.Hash(), mappingSlot(s.holder.Hash(), common.BigToHash(big.NewInt(i))))
+		}, func(i int64) common.Hash {
+			return mappingSlot(mappingSlot(common.BigToHash(big.NewInt(i)), s.holder.Hash()), s.router.Hash())
+		}, "allowance", s.holder, s.router)
+		if err != nil {
+			return 0, err
+		}
+		stateDiff[slot] = common.BigToHash(amountIn)
+	}
+
+	var overrides map[common.Address]eth.OverrideAccount
+	if len(stateDiff) > 0 {
+		overrides = map[common.Address]eth.OverrideAccount{s.token: {StateDiff: stateDiff}}
+	}
+	received, err := searchReceived(expected, func(amountOutMin *big.Int) error {
+		data, _ := routerABI.Pack("swapExactTokensForETHSupportingFeeOnTransferTokens", amountIn, amountOutMin, path, s.holder, s.deadline())
+		_, err := s.call(s.router, nil, data, overrides)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return tax(expected, received), nil
+}
+
+func mappingSlot(key, slot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), slot.Bytes())
+}
+
+func (s *simulator) tokenCall(method string, overrides map[common.Address]eth.OverrideAccount, args ...interface{}) (*big.Int, error) {
+	data, _ := tokenABI.Pack(method, args...)
+	out, err := s.call(s.token, nil, data, overrides)
+	if err != nil {
+		return nil, err
+	}
+	values, err := tokenABI.Unpack(method, out)
+	if err != nil {
+		return nil, err
+	}
+	return values[0].(*big.Int), nil
+}
+
+// findSlot 逐个尝试 mapping 的存储位置，覆盖后读取到的值等于 value 即为找到
+func (s *simulator) findSlot(value *big.Int, solidity, vyper func(i int64) common.Hash, method string, args ...interface{}) (common.Hash, error) {
+	for i := int64(0); i < maxSlotProbe; i++ {
+		for _, slot := range []common.Hash{solidity(i), vyper(i)} {
+			overrides := map[common.Address]eth.OverrideAccount{s.token: {StateDiff: map[common.Hash]common.Hash{slot: common.BigToHash(value)}}}
+			got, err := s.tokenCall(method, overrides, args...)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			if got.Cmp(value) == 0 {
+				return slot, nil
+			}
+		}
+	}
+	return common.Hash{}, fmt.Errorf("%s storage slot not found", method)
+}
+
+// checkOwner 读取合约所有者，未放弃所有权时扫描字节码中的增发、黑名单、暂停函数
+func (s *simulator) checkOwner(report *Report) error {
+	for _, method := range []string{"owner", "getOwner"} {
+		data, _ := tokenABI.Pack(method)
+		out, err := s.call(s.token, nil, data, nil)
+		if err != nil || len(out) < 32 {
+			continue
+		}
+		owner := common.BytesToAddress(out[12:32])
+		if owner != (common.Address{}) && owner != common.HexToAddress("0x000000000000000000000000000000000000dead") {
+			report.Owner = strings.ToLower(owner.Hex())
+		}
+		break
+	}
+	if report.Owner == "" {
+		return nil
+	}
+
+	code, err := eth.Client.GetCode(s.token.Hex())
+	if err != nil {
+		return err
+	}
+	selectors := pushedSelectors(code)
+	for name, signatures := range ownerFunctions {
+		for _, signature := range signatures {
+			var selector [4]byte
+			copy(selector[:], crypto.Keccak256([]byte(signature))[:4])
+			if selectors[selector] {
+				report.Functions = append(report.Functions, name+":"+signature)
+			}
+		}
+	}
+	sort.Strings(report.Functions)
+	return nil
+}
+
+// pushedSelectors 字节码中 PUSH4 的值，函数分发表里的函数选择器都是 PUSH4
+func pushedSelectors(code []byte) map[[4]byte]bool {
+	selectors := make(map[[4]byte]bool)
+	for i := 0; i < len(code); i++ {
+		op := code[i]
+		if op < 0x60 || op > 0x7f {
+			continue
+		}
+		n := int(op-0x60) + 1
+		if n == 4 && i+4 < len(code) {
+			var selector [4]byte
+			copy(selector[:], code[i+1:i+5])
+			selectors[selector] = true
+		}
+		i += n
+	}
+	return selectors
+}
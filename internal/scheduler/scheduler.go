@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"smart-money/pkg/log"
+)
+
+type JobFunc func() error
+
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Spec         string    `json:"spec"`
+	Enabled      bool      `json:"enabled"`
+	Running      bool      `json:"running"`
+	RunCount     int64     `json:"run_count"`
+	LastRunAt    time.Time `json:"last_run_at"`
+	LastDuration float64   `json:"last_duration"`
+	LastError    string    `json:"last_error"`
+	NextRunAt    time.Time `json:"next_run_at"`
+}
+
+type job struct {
+	name     string
+	fn       JobFunc
+	spec     string
+	schedule cron.Schedule
+	status   JobStatus
+}
+
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		jobs: make(map[string]*job),
+		stop: make(chan struct{}),
+	}
+}
+
+// ParseSpec parses a schedule spec, which is either a duration like "10s" for a fixed
+// delay between runs or a standard cron expression like "*/5 * * * *".
+func ParseSpec(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d < time.Second {
+			return nil, fmt.Errorf("interval %s is less than 1s", spec)
+		}
+		return cron.Every(d), nil
+	}
+	return cron.ParseStandard(spec)
+}
+
+// Register adds a job. It must be called before Start.
+func (s *Scheduler) Register(name, spec string, enabled bool, fn JobFunc) error {
+	schedule, err := ParseSpec(spec)
+	if err != nil {
+		return fmt.Errorf("job %s spec %q error: %v", name, spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("scheduler already started")
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s already registered", name)
+	}
+	s.jobs[name] = &job{
+		name:     name,
+		fn:       fn,
+		spec:     spec,
+		schedule: schedule,
+		status: JobStatus{
+			Name:    name,
+			Spec:    spec,
+			Enabled: enabled,
+		},
+	}
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop stops scheduling new runs and waits for the running jobs to finish.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) SetEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("job %s not found", name)
+	}
+	j.status.Enabled = enabled
+	return nil
+}
+
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *Scheduler) loop(j *job) {
+	defer s.wg.Done()
+	for {
+		next := j.schedule.Next(time.Now())
+		s.mu.Lock()
+		j.status.NextRunAt = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		enabled := j.status.Enabled
+		s.mu.Unlock()
+		if enabled {
+			s.run(j)
+		}
+	}
+}
+
+func (s *Scheduler) run(j *job) {
+	s.mu.Lock()
+	j.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return j.fn()
+	}()
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.RunCount++
+	j.status.LastRunAt = start
+	j.status.LastDuration = duration.Seconds()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Errorf("job %s error: %v", j.name, err)
+	}
+}
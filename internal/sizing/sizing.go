@@ -0,0 +1,241 @@
+package sizing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"smart-money/config"
+	"smart-money/internal/analysis"
+	"smart-money/internal/exchange"
+	inch "smart-money/pkg/1inch"
+	"smart-money/pkg/eth"
+	"smart-money/pkg/model"
+	"smart-money/pkg/util"
+)
+
+const (
+	StrategyEachSellAmount   = "each_sell_amount"
+	StrategyFixedUsd         = "fixed_usd"
+	StrategyProportional     = "proportional"
+	StrategyEquityPercent    = "equity_percent"
+	StrategyLeaderboardScore = "leaderboard_score"
+
+	// 仓位被池子流动性限制时策略名的后缀
+	liquidityCapSuffix = "+liquidity_cap"
+
+	defaultScoreDays = 30
+)
+
+// Request 计算一笔跟单买入金额需要的信息
+type Request struct {
+	ChainName     string
+	FollowAddress string
+	TokenAddress  string
+	// 关注地址这笔买入花费的美元，0 表示未知
+	FollowBuyUsd float64
+	Wallet       *model.MyWallet
+	// 策略参数，0 时使用全局配置
+	Value float64
+}
+
+// Result 计算出的仓位
+type Result struct {
+	Strategy        string
+	SizeUsd         float64
+	MainTokenAmount float64
+}
+
+// Strategy 仓位策略，返回买入花费的美元价值
+type Strategy interface {
+	Name() string
+	SizeUsd(req *Request, mainTokenPrice float64) (float64, error)
+}
+
+var strategies = map[string]Strategy{
+	StrategyEachSellAmount:   eachSellAmount{},
+	StrategyFixedUsd:         fixedUsd{},
+	StrategyProportional:     proportional{},
+	StrategyEquityPercent:    equityPercent{},
+	StrategyLeaderboardScore: leaderboardScore{},
+}
+
+// Check 检查策略名是否存在，空字符串表示使用全局配置
+func Check(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := strategies[name]
+	return ok
+}
+
+// Size 按策略计算买入金额，strategy 为空时使用全局配置，配置了流动性上限时再按池子流动性限制
+func Size(strategy string, req *Request) (*Result, error) {
+	if strategy == "" {
+		strategy = config.CFG.Sizing.Strategy
+	}
+	if strategy == "" {
+		strategy = StrategyEachSellAmount
+	}
+	s, ok := strategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sizing strategy %s", strategy)
+	}
+
+	price, err := MainTokenPriceUsd(req.ChainName)
+	if err != nil {
+		return nil, err
+	}
+	sizeUsd, err := s.SizeUsd(req, price)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", strategy, err)
+	}
+
+	result := &Result{Strategy: s.Name(), SizeUsd: sizeUsd}
+	if percent := config.CFG.Sizing.MaxLiquidityPercent; percent > 0 {
+		liquidityUsd, err := PoolLiquidityUsd(req.ChainName, req.TokenAddress, price)
+		if err != nil {
+			return nil, fmt.Errorf("get pool liquidity error: %v", err)
+		}
+		if capUsd := liquidityUsd * percent; capUsd < result.SizeUsd {
+			result.SizeUsd = capUsd
+			result.Strategy += liquidityCapSuffix
+		}
+	}
+	if result.SizeUsd <= 0 {
+		return nil, fmt.Errorf("%s: size is zero", result.Strategy)
+	}
+	result.MainTokenAmount = result.SizeUsd / price
+	return result, nil
+}
+
+// MainTokenPriceUsd 用启用的兑换渠道报价获取主流币的实时美元价格，聚合器都失败时使用 dex 路由报价，再失败时使用日线价格
+func MainTokenPriceUsd(chainName string) (float64, error) {
+	mainToken := util.MainTokenInfo[chainName]
+	usdt := util.USDTContractMap[chainName]
+	if mainToken == nil || usdt == nil {
+		return 0, fmt.Errorf("chain %s not supported", chainName)
+	}
+
+	quote, err := exchange.Quote(chainName, &inch.SwapRequest{
+		FromTokenAddress: mainToken.ContractAddress,
+		ToTokenAddress:   usdt.ContractAddress,
+		Amount:           decimal.New(1, int32(mainToken.Decimal)).String(),
+	})
+	if err == nil {
+		price, _ := decimal.NewFromBigInt(quote.ToAmount, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(usdt.Decimal)))).Float64()
+		if price > 0 {
+			return price, nil
+		}
+	}
+
+	// 当天的日线价格可能还没有
+	now := time.Now()
+	for _, t := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		if price := util.GetMainTokenPriceInDate(chainName, t.Unix()); price > 0 {
+			return price, nil
+		}
+	}
+	return 0, fmt.Errorf("get %s main token price error: %v", chainName, err)
+}
+
+// PoolLiquidityUsd 代币和原生币 v2 交易对中原生币一侧的美元价值
+func PoolLiquidityUsd(chainName, tokenAddress string, mainTokenPrice float64) (float64, error) {
+	factory, wrapped := util.V2Factories[chainName], util.WrappedNativeToken[chainName]
+	if factory == "" || wrapped == "" {
+		return 0, fmt.Errorf("chain %s not supported", chainName)
+	}
+	client, err := eth.ClientOf(chainName)
+	if err != nil {
+		return 0, err
+	}
+	pair, err := client.GetV2Pair(factory, tokenAddress, wrapped)
+	if err != nil {
+		return 0, err
+	}
+	if pair == "0x0000000000000000000000000000000000000000" {
+		return 0, fmt.Errorf("token %s has no v2 pair", tokenAddress)
+	}
+	balance, err := client.GetTokenBalance(wrapped, pair)
+	if err != nil {
+		return 0, err
+	}
+	amount, _ := decimal.NewFromBigInt(balance, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(util.MainTokenInfo[chainName].Decimal)))).Float64()
+	return amount * mainTokenPrice, nil
+}
+
+func valueOr(v, defaultValue float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return defaultValue
+}
+
+// eachSellAmount 钱包配置的固定主流币数量，即原来的跟单方式
+type eachSellAmount struct{}
+
+func (eachSellAmount) Name() string { return StrategyEachSellAmount }
+
+func (eachSellAmount) SizeUsd(req *Request, mainTokenPrice float64) (float64, error) {
+	return valueOr(req.Value, req.Wallet.EachSellAmount) * mainTokenPrice, nil
+}
+
+type fixedUsd struct{}
+
+func (fixedUsd) Name() string { return StrategyFixedUsd }
+
+func (fixedUsd) SizeUsd(req *Request, _ float64) (float64, error) {
+	return valueOr(req.Value, config.CFG.Sizing.FixedUsd), nil
+}
+
+// proportional 关注地址买入金额的固定比例
+type proportional struct{}
+
+func (proportional) Name() string { return StrategyProportional }
+
+func (proportional) SizeUsd(req *Request, _ float64) (float64, error) {
+	if req.FollowBuyUsd <= 0 {
+		return 0, fmt.Errorf("followed buy usd unknown")
+	}
+	return req.FollowBuyUsd * valueOr(req.Value, config.CFG.Sizing.ProportionalRatio), nil
+}
+
+// equityPercent 钱包原生币余额的固定比例
+type equityPercent struct{}
+
+func (equityPercent) Name() string { return StrategyEquityPercent }
+
+func (equityPercent) SizeUsd(req *Request, mainTokenPrice float64) (float64, error) {
+	client, err := eth.ClientOf(req.ChainName)
+	if err != nil {
+		return 0, err
+	}
+	balance, err := client.GetNativeBalance(req.Wallet.Address)
+	if err != nil {
+		return 0, err
+	}
+	equity, _ := decimal.NewFromBigInt(balance, 0).Div(decimal.NewFromFloat(math.Pow(10, float64(util.MainTokenInfo[req.ChainName].Decimal)))).Float64()
+	return equity * mainTokenPrice * valueOr(req.Value, config.CFG.Sizing.EquityPercent), nil
+}
+
+// leaderboardScore 按地址的排行榜评分(胜率置信下限)缩放
+type leaderboardScore struct{}
+
+func (leaderboardScore) Name() string { return StrategyLeaderboardScore }
+
+func (leaderboardScore) SizeUsd(req *Request, _ float64) (float64, error) {
+	days := config.CFG.Sizing.ScoreDays
+	if days <= 0 {
+		days = defaultScoreDays
+	}
+	var trades []*model.AddressTrade
+	err := model.GetDB().Where("chain_name = ? and lower(address) = ? and first_tx_time >= ?", req.ChainName, strings.ToLower(req.FollowAddress),
+		time.Now().AddDate(0, 0, -days).Unix()).Find(&trades).Error
+	if err != nil {
+		return 0, err
+	}
+	summary := analysis.Summarize(analysis.DedupeTrades(trades))
+	return valueOr(req.Value, config.CFG.Sizing.ScoreBaseUsd) * summary.WinRateLower, nil
+}
@@ -2,7 +2,9 @@ package inch
 
 import (
 	"fmt"
+	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/imroc/req/v3"
@@ -15,6 +17,15 @@ const (
 	BroadcastBASE = "https://tx-gateway.1inch.io/v1.1"
 )
 
+// apiBase 默认为 APIBASE，可以通过配置替换成代理地址
+var apiBase = APIBASE
+
+func SetAPIBase(base string) {
+	if base != "" {
+		apiBase = strings.TrimSuffix(base, "/")
+	}
+}
+
 var reqC = req.C().DevMode().
 	SetCommonRetryCount(3).
 	SetCommonRetryBackoffInterval(3*time.Second, time.Minute).
@@ -22,12 +33,13 @@ var reqC = req.C().DevMode().
 		return resp.GetStatusCode() != http.StatusOK
 	})
 
-func Quote(chainName, fromTokenAddress, toTokenAddress string, amount int64) (*QuoteResp, error) {
-	url := fmt.Sprintf("%s/%d/%s", APIBASE, util.ChainIDMap[chainName], "quote")
+// Quote amount 为未除以精度的数量，按十进制字符串传给 api，避免超过 int64
+func Quote(chainName, fromTokenAddress, toTokenAddress string, amount *big.Int) (*QuoteResp, error) {
+	url := fmt.Sprintf("%s/%d/%s", apiBase, util.ChainIDMap[chainName], "quote")
 	resp := reqC.Get(url).SetQueryParamsAnyType(map[string]interface{}{
 		"fromTokenAddress": fromTokenAddress,
 		"toTokenAddress":   toTokenAddress,
-		"amount":           amount,
+		"amount":           amount.String(),
 	}).Do()
 
 	if resp.Err != nil {
@@ -73,7 +85,7 @@ func Swap(chainName string, req *SwapRequest) (*SwapResp, error) {
 	if err := mapstructure.Decode(req, &reqm); err != nil {
 		return nil, err
 	}
-	url := fmt.Sprintf("%s/%d/%s", APIBASE, util.ChainIDMap[chainName], "swap")
+	url := fmt.Sprintf("%s/%d/%s", apiBase, util.ChainIDMap[chainName], "swap")
 	resp := reqC.Get(url).SetQueryParamsAnyType(reqm).Do()
 
 	if resp.Err != nil {
@@ -92,7 +104,7 @@ func Swap(chainName string, req *SwapRequest) (*SwapResp, error) {
 }
 
 func CheckAllowance(chainName, tokenAddress, walletAddress string) (string, error) {
-	url := fmt.Sprintf("%s/%d/%s", APIBASE, util.ChainIDMap[chainName], "approve/allowance")
+	url := fmt.Sprintf("%s/%d/%s", apiBase, util.ChainIDMap[chainName], "approve/allowance")
 	resp := reqC.Get(url).SetQueryParamsAnyType(map[string]interface{}{
 		"tokenAddress":  tokenAddress,
 		"walletAddress": walletAddress,
@@ -114,7 +126,7 @@ func CheckAllowance(chainName, tokenAddress, walletAddress string) (string, erro
 }
 
 func ApproveTransaction(chainName, tokenAddress, amount string) (*ApproveTransactionResp, error) {
-	url := fmt.Sprintf("%s/%d/%s", APIBASE, util.ChainIDMap[chainName], "approve/transaction")
+	url := fmt.Sprintf("%s/%d/%s", apiBase, util.ChainIDMap[chainName], "approve/transaction")
 	resp := reqC.Get(url).SetQueryParamsAnyType(map[string]interface{}{
 		"tokenAddress": tokenAddress,
 		"amount":       amount,
@@ -136,7 +148,7 @@ func ApproveTransaction(chainName, tokenAddress, amount string) (*ApproveTransac
 }
 
 func GetSpender(chainName string) (string, error) {
-	url := fmt.Sprintf("%s/%d/%s", APIBASE, util.ChainIDMap[chainName], "approve/spender")
+	url := fmt.Sprintf("%s/%d/%s", apiBase, util.ChainIDMap[chainName], "approve/spender")
 	resp := reqC.Get(url).Do()
 
 	if resp.Err != nil {
@@ -19,5 +19,22 @@ const (
 	SaveFollowAddressNotExistError = 13003
 	ListFollowAddressParamsError   = 13004
 
-	ListFollowTradeParamsError = 14000
+	ListFollowTradeParamsError     = 14000
+	ListFollowTradeExitParamsError = 14004
+	ListFollowTradePnlParamsError  = 14005
+	ExitFollowTradeParamsError     = 14006
+	ExitFollowTradeNotExistError   = 14007
+	ListExecutionStatsParamsError  = 14008
+
+	AddressProfileParamsError   = 15000
+	AddressProfileNotExistError = 15003
+
+	ListConsensusSignalParamsError = 16000
+
+	UpdateJobParamsError   = 17000
+	UpdateJobNotExistError = 17003
+
+	BacktestParamsError = 18000
+
+	UpdateTradingControlParamsError = 19000
 )
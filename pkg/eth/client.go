@@ -3,45 +3,88 @@ package eth
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	inch "smart-money/pkg/1inch"
 	"smart-money/pkg/eth/erc20"
+	"smart-money/pkg/eth/uniswapv2"
+	"smart-money/pkg/eth/uniswapv3"
 	"smart-money/pkg/log"
+	"smart-money/pkg/util"
 )
 
 var (
 	Client *client
+
+	// resultHook 每次 http rpc 请求结束后调用，用于统计错误率
+	resultHook func(err error)
 )
 
+// SetResultHook 需要在 InitClient 之后、发起请求之前设置
+func SetResultHook(fn func(err error)) {
+	resultHook = fn
+}
+
+// hookTransport 网络错误和 5xx 算作失败，合约 revert 等 json-rpc 错误不算
+type hookTransport struct {
+	base http.RoundTripper
+}
+
+func (t *hookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if hook := resultHook; hook != nil {
+		result := err
+		if result == nil && resp.StatusCode >= http.StatusInternalServerError {
+			result = fmt.Errorf("rpc status code %d", resp.StatusCode)
+		}
+		hook(result)
+	}
+	return resp, err
+}
+
 type client struct {
 	ethClient *ethclient.Client
+	rpcClient *rpc.Client
 	chainID   int64
 }
 
 func InitClient(rpcAddr string, chainID int64) error {
-	var err error
-	c, err := ethclient.Dial(rpcAddr)
+	rpcClient, err := rpc.DialOptions(context.Background(), rpcAddr,
+		rpc.WithHTTPClient(&http.Client{Transport: &hookTransport{base: http.DefaultTransport}}))
 	if err != nil {
 		return err
 	}
 	Client = &client{
-		ethClient: c,
+		ethClient: ethclient.NewClient(rpcClient),
+		rpcClient: rpcClient,
 		chainID:   chainID,
 	}
 	return nil
 }
 
+// ClientOf 返回链对应的客户端，目前只连接 web3 配置的一条链，其它链返回错误
+func ClientOf(chainName string) (*client, error) {
+	if Client == nil || util.ChainIDMap[chainName] != Client.chainID {
+		return nil, fmt.Errorf("no rpc client for chain %s", chainName)
+	}
+	return Client, nil
+}
+
 func (c *client) GetEthClient() *ethclient.Client {
 	return c.ethClient
 }
@@ -54,6 +97,230 @@ func (c *client) GetTokenDecimals(tokenAddress string) (uint8, error) {
 	return contract.Decimals(nil)
 }
 
+func (c *client) GetTokenTotalSupply(tokenAddress string) (*big.Int, error) {
+	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	return contract.TotalSupply(nil)
+}
+
+// GetTokenTotalSupplyAt 查询指定区块的总供应量，需要节点保留历史状态
+func (c *client) GetTokenTotalSupplyAt(tokenAddress string, blockNumber *big.Int) (*big.Int, error) {
+	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	return contract.TotalSupply(&bind.CallOpts{BlockNumber: blockNumber})
+}
+
+func (c *client) GetTokenBalance(tokenAddress, owner string) (*big.Int, error) {
+	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	return contract.BalanceOf(nil, common.HexToAddress(owner))
+}
+
+func (c *client) GetTokenAllowance(tokenAddress, owner, spender string) (*big.Int, error) {
+	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	return contract.Allowance(nil, common.HexToAddress(owner), common.HexToAddress(spender))
+}
+
+func (c *client) GetTokenSymbol(tokenAddress string) (string, error) {
+	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
+	if err != nil {
+		return "", err
+	}
+	return contract.Symbol(nil)
+}
+
+func (c *client) GetNativeBalance(address string) (*big.Int, error) {
+	return c.ethClient.BalanceAt(context.Background(), common.HexToAddress(address), nil)
+}
+
+var v2FactoryABI, _ = abi.JSON(strings.NewReader(`[
+	{"inputs":[{"name":"tokenA","type":"address"},{"name":"tokenB","type":"address"}],"name":"getPair","outputs":[{"name":"pair","type":"address"}],"stateMutability":"view","type":"function"}
+]`))
+
+// GetV2Pair 查询 uniswap v2 类 factory 中两个代币的交易对，不存在时返回零地址
+func (c *client) GetV2Pair(factory, tokenA, tokenB string) (string, error) {
+	contract := bind.NewBoundContract(common.HexToAddress(factory), v2FactoryABI, c.ethClient, nil, nil)
+	var out []interface{}
+	if err := contract.Call(nil, &out, "getPair", common.HexToAddress(tokenA), common.HexToAddress(tokenB)); err != nil {
+		return "", err
+	}
+	return strings.ToLower(out[0].(common.Address).Hex()), nil
+}
+
+// GetPoolTokens 获取 uniswap v2/v3 类池子的 token0 和 token1
+func (c *client) GetPoolTokens(poolAddress string) (string, string, error) {
+	pool, err := uniswapv2.NewUniswapV2PairCaller(common.HexToAddress(poolAddress), c.ethClient)
+	if err != nil {
+		return "", "", err
+	}
+	token0, err := pool.Token0(nil)
+	if err != nil {
+		return "", "", err
+	}
+	token1, err := pool.Token1(nil)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.ToLower(token0.Hex()), strings.ToLower(token1.Hex()), nil
+}
+
+// GetV2AmountsOut uniswap v2 类路由按路径报价，返回每一跳的数量
+func (c *client) GetV2AmountsOut(router string, amountIn *big.Int, path []common.Address) ([]*big.Int, error) {
+	contract, err := uniswapv2.NewUniswapV2RouterCaller(common.HexToAddress(router), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	return contract.GetAmountsOut(nil, amountIn, path)
+}
+
+// QuoteV3ExactInput uniswap v3 quoter 按编码后的路径报价，quoter 的报价函数不是 view，只能通过 eth_call 调用
+func (c *client) QuoteV3ExactInput(quoter string, path []byte, amountIn *big.Int) (*big.Int, error) {
+	contract, err := uniswapv3.NewQuoterCaller(common.HexToAddress(quoter), c.ethClient)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	if err = (&uniswapv3.QuoterCallerRaw{Contract: contract}).Call(nil, &out, "quoteExactInput", path, amountIn); err != nil {
+		return nil, err
+	}
+	return out[0].(*big.Int), nil
+}
+
+// SubscribePendingTransactions 通过 websocket rpc 订阅 pending 交易，阻塞直到 ctx 结束或订阅出错
+func (c *client) SubscribePendingTransactions(ctx context.Context, wsAddr string, ch chan<- *types.Transaction) error {
+	rpcClient, err := rpc.DialContext(ctx, wsAddr)
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
+
+	// 优先订阅完整交易，节点不支持时订阅交易哈希再逐个查询
+	sub, err := rpcClient.EthSubscribe(ctx, ch, "newPendingTransactions", true)
+	if err == nil {
+		defer sub.Unsubscribe()
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-sub.Err():
+			return err
+		}
+	}
+
+	hashes := make(chan common.Hash, 1024)
+	sub, err = rpcClient.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	wsClient := ethclient.NewClient(rpcClient)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-sub.Err():
+			return err
+		case hash := <-hashes:
+			tx, isPending, err := wsClient.TransactionByHash(ctx, hash)
+			if err != nil || !isPending {
+				continue
+			}
+			select {
+			case ch <- tx:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// OverrideAccount eth_call 的状态覆盖，用于模拟交易时修改余额和合约存储
+type OverrideAccount struct {
+	Balance   *hexutil.Big                `json:"balance,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// CallContractWithOverrides 带状态覆盖的 eth_call，合约 revert 时返回的错误 IsRevert 为 true
+func (c *client) CallContractWithOverrides(msg ethereum.CallMsg, overrides map[common.Address]OverrideAccount) ([]byte, error) {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+		"data": hexutil.Bytes(msg.Data),
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas > 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	var result hexutil.Bytes
+	if err := c.rpcClient.CallContext(context.Background(), &result, "eth_call", arg, "latest", overrides); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// IsRevert 判断 eth_call 的错误是否是合约 revert，而不是网络等其他错误
+func IsRevert(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "revert")
+}
+
+func (c *client) GetCode(address string) ([]byte, error) {
+	return c.ethClient.CodeAt(context.Background(), common.HexToAddress(address), nil)
+}
+
+// TransferEventTopic ERC-20 Transfer(address,address,uint256) 事件签名
+var TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// GetTransferLogs 获取区块范围内转出或转入指定地址的 ERC-20 Transfer 日志
+func (c *client) GetTransferLogs(fromBlock, toBlock int64, addresses []string) ([]types.Log, error) {
+	topics := make([]common.Hash, 0, len(addresses))
+	for _, address := range addresses {
+		topics = append(topics, common.BytesToHash(common.HexToAddress(address).Bytes()))
+	}
+
+	seen := make(map[string]bool)
+	var logs []types.Log
+	// 第一次查转出，第二次查转入
+	for _, q := range [][][]common.Hash{
+		{{TransferEventTopic}, topics},
+		{{TransferEventTopic}, nil, topics},
+	} {
+		result, err := c.ethClient.FilterLogs(context.Background(), ethereum.FilterQuery{
+			FromBlock: big.NewInt(fromBlock),
+			ToBlock:   big.NewInt(toBlock),
+			Topics:    q,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range result {
+			key := fmt.Sprintf("%s-%d", l.TxHash, l.Index)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
 func (c *client) Approve(opts *bind.TransactOpts, tokenAddress, spender string, amount *big.Int) (common.Hash, error) {
 	contract, err := erc20.NewErc20(common.HexToAddress(tokenAddress), c.ethClient)
 	if err != nil {
@@ -151,6 +418,9 @@ func (c *client) GetAuth(pk, fromAddress string) (*bind.TransactOpts, error) {
 	return auth, nil
 }
 
+// ErrTxFailed 交易已打包但执行失败，其他错误时交易可能还会成功
+var ErrTxFailed = errors.New("tx status failed")
+
 func (c *client) WaitTxHashReceipt(txHash common.Hash) (*types.Receipt, error) {
 	tryTimes := 30
 	for tryTimes > 0 {
@@ -167,7 +437,7 @@ func (c *client) WaitTxHashReceipt(txHash common.Hash) (*types.Receipt, error) {
 			return receipt, nil
 		}
 		if receipt.Status == types.ReceiptStatusFailed {
-			return nil, fmt.Errorf("tx status failed")
+			return nil, ErrTxFailed
 		}
 		tryTimes -= 1
 		time.Sleep(3 * time.Second)
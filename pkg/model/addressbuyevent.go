@@ -0,0 +1,26 @@
+package model
+
+import "gorm.io/gorm"
+
+type AddressBuyEvent struct {
+	gorm.Model
+	ChainName    string `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	Address      string `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
+	TokenAddress string `json:"token_address" gorm:"column:token_address;type:varchar(255);not null;default:'';comment:买入代币地址"`
+	Symbol       string `json:"symbol" gorm:"column:symbol;type:varchar(255);not null;default:'';comment:买入代币符号"`
+	Amount       string `json:"amount" gorm:"column:amount;type:varchar(255);not null;default:'';comment:买入数量"`
+	TxHash       string `json:"tx_hash" gorm:"column:tx_hash;type:varchar(255);not null;default:'';comment:交易哈希"`
+	TxTime       int64  `json:"tx_time" gorm:"column:tx_time;type:bigint(20);not null;default:0;comment:交易时间(秒)"`
+}
+
+func (a *AddressBuyEvent) TableName() string {
+	return "address_buy_event"
+}
+
+func CreateAddressBuyEvent(a *AddressBuyEvent) error {
+	return db.Create(a).Error
+}
+
+func init() {
+	registerTable(&AddressBuyEvent{})
+}
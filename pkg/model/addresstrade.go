@@ -4,17 +4,24 @@ import "gorm.io/gorm"
 
 type AddressTrade struct {
 	gorm.Model
-	Address      string  `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
-	ChainName    string  `json:"chain_name" gorm:"column:chain_name"`
-	FirstTxTime  uint64  `json:"first_tx_time" gorm:"column:first_tx_time"`
-	LastTxTime   uint64  `json:"last_tx_time" gorm:"column:last_tx_time"`
-	BuyAddress   string  `json:"buy_address" gorm:"column:buy_address"`
-	BuySymbol    string  `json:"buy_symbol" gorm:"column:buy_symbol"`
-	SellAddress  string  `json:"sell_address" gorm:"column:sell_address"`
-	SellSymbol   string  `json:"sell_symbol" gorm:"column:sell_symbol"`
-	BuyTotalUsd  float64 `json:"buy_total_usd" gorm:"column:buy_total_usd"`
-	SellTotalUsd float64 `json:"sell_total_usd" gorm:"column:sell_total_usd"`
-	Profit       float64 `json:"profit" gorm:"column:profit"`
+	Address                 string  `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
+	ChainName               string  `json:"chain_name" gorm:"column:chain_name"`
+	FirstTxTime             uint64  `json:"first_tx_time" gorm:"column:first_tx_time"`
+	LastTxTime              uint64  `json:"last_tx_time" gorm:"column:last_tx_time"`
+	BuyAddress              string  `json:"buy_address" gorm:"column:buy_address"`
+	BuySymbol               string  `json:"buy_symbol" gorm:"column:buy_symbol"`
+	SellAddress             string  `json:"sell_address" gorm:"column:sell_address"`
+	SellSymbol              string  `json:"sell_symbol" gorm:"column:sell_symbol"`
+	BuyTotalUsd             float64 `json:"buy_total_usd" gorm:"column:buy_total_usd"`
+	SellTotalUsd            float64 `json:"sell_total_usd" gorm:"column:sell_total_usd"`
+	Profit                  float64 `json:"profit" gorm:"column:profit"`
+	EntryBlockHeight        int64   `json:"entry_block_height" gorm:"column:entry_block_height"`
+	LaunchBlockHeight       int64   `json:"launch_block_height" gorm:"column:launch_block_height"`
+	LaunchTxTime            uint64  `json:"launch_tx_time" gorm:"column:launch_tx_time"`
+	EntryBlocksAfterLaunch  int64   `json:"entry_blocks_after_launch" gorm:"column:entry_blocks_after_launch"`
+	EntryMinutesAfterLaunch float64 `json:"entry_minutes_after_launch" gorm:"column:entry_minutes_after_launch"`
+	EntryPriceUsd           float64 `json:"entry_price_usd" gorm:"column:entry_price_usd"`
+	EntryMarketCapUsd       float64 `json:"entry_market_cap_usd" gorm:"column:entry_market_cap_usd"`
 }
 
 func (a *AddressTrade) TableName() string {
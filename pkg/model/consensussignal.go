@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	ConsensusSignalStatusNew      = 1
+	ConsensusSignalStatusFollowed = 2
+	ConsensusSignalStatusFail     = 3
+)
+
+type ConsensusSignal struct {
+	gorm.Model
+	ChainName     string `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	TokenAddress  string `json:"token_address" gorm:"column:token_address;type:varchar(255);not null;default:'';comment:代币地址"`
+	Symbol        string `json:"symbol" gorm:"column:symbol;type:varchar(255);not null;default:'';comment:代币符号"`
+	AddressCount  int    `json:"address_count" gorm:"column:address_count;type:int(11);not null;default:0;comment:买入地址数"`
+	Addresses     string `json:"addresses" gorm:"column:addresses;type:text;comment:买入地址,逗号分隔"`
+	TxHashes      string `json:"tx_hashes" gorm:"column:tx_hashes;type:text;comment:买入交易哈希,逗号分隔"`
+	FirstBuyTime  int64  `json:"first_buy_time" gorm:"column:first_buy_time;type:bigint(20);not null;default:0;comment:窗口内第一笔买入时间"`
+	LastBuyTime   int64  `json:"last_buy_time" gorm:"column:last_buy_time;type:bigint(20);not null;default:0;comment:窗口内最后一笔买入时间"`
+	WindowSeconds int64  `json:"window_seconds" gorm:"column:window_seconds;type:bigint(20);not null;default:0;comment:窗口时长"`
+	Status        int    `json:"status" gorm:"column:status;type:tinyint(1);not null;default:0;comment:状态"`
+	FailReason    string `json:"fail_reason" gorm:"column:fail_reason;type:text;comment:失败原因"`
+}
+
+func (c *ConsensusSignal) TableName() string {
+	return "consensus_signal"
+}
+
+func CreateConsensusSignal(c *ConsensusSignal) error {
+	return db.Create(c).Error
+}
+
+func SaveConsensusSignal(c *ConsensusSignal) error {
+	return db.Save(c).Error
+}
+
+func init() {
+	registerTable(&ConsensusSignal{})
+}
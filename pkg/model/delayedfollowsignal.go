@@ -0,0 +1,37 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	DelayedFollowSignalStatusPending = 1
+	DelayedFollowSignalStatusRunning = 2
+	DelayedFollowSignalStatusDone    = 3
+)
+
+// DelayedFollowSignal 配置了跟单延迟的信号，到时间前保存在数据库，服务重启后重新定时
+type DelayedFollowSignal struct {
+	gorm.Model
+	ChainName     string `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	FollowAddress string `json:"follow_address" gorm:"column:follow_address;type:varchar(255);not null;default:'';comment:关注地址"`
+	TxHash        string `json:"tx_hash" gorm:"column:tx_hash;type:varchar(255);not null;default:'';comment:关注地址交易哈希"`
+	Data          string `json:"data" gorm:"column:data;type:text;comment:跟单信号"`
+	ExecuteAt     int64  `json:"execute_at" gorm:"column:execute_at;type:bigint(20);not null;default:0;comment:执行时间,毫秒"`
+	Status        int    `json:"status" gorm:"column:status;type:tinyint(1);not null;default:0;comment:状态"`
+	FailReason    string `json:"fail_reason" gorm:"column:fail_reason;type:text;comment:失败原因"`
+}
+
+func (d *DelayedFollowSignal) TableName() string {
+	return "delayed_follow_signal"
+}
+
+func CreateDelayedFollowSignal(d *DelayedFollowSignal) error {
+	return db.Create(d).Error
+}
+
+func SaveDelayedFollowSignal(d *DelayedFollowSignal) error {
+	return db.Save(d).Error
+}
+
+func init() {
+	registerTable(&DelayedFollowSignal{})
+}
@@ -9,11 +9,26 @@ const (
 
 type FollowAddress struct {
 	gorm.Model
-	Address         string `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
-	ChainName       string `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
-	LastErc20TxHash string `json:"last_erc20_tx_hash" gorm:"column:last_erc20_tx_hash;type:varchar(255);not null;default:'';comment:最后一次erc20交易hash"`
-	LastErc20TxTime int64  `json:"last_erc20_tx_time" gorm:"column:last_erc20_tx_time;type:bigint(20);not null;default:0;comment:最后一次erc20交易时间"`
-	Status          int    `json:"status" gorm:"column:status;type:int(11);not null;default:0;comment:状态"`
+	Address         string  `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
+	ChainName       string  `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	LastErc20TxHash string  `json:"last_erc20_tx_hash" gorm:"column:last_erc20_tx_hash;type:varchar(255);not null;default:'';comment:最后一次erc20交易hash"`
+	LastErc20TxTime int64   `json:"last_erc20_tx_time" gorm:"column:last_erc20_tx_time;type:bigint(20);not null;default:0;comment:最后一次erc20交易时间"`
+	Status          int     `json:"status" gorm:"column:status;type:int(11);not null;default:0;comment:状态"`
+	StopReason      string  `json:"stop_reason" gorm:"column:stop_reason;type:text;comment:停止跟单原因"`
+	SizingStrategy  string  `json:"sizing_strategy" gorm:"column:sizing_strategy;type:varchar(255);not null;default:'';comment:仓位策略,为空时使用全局配置"`
+	SizingValue     float64 `json:"sizing_value" gorm:"column:sizing_value;type:decimal(20,8);not null;default:0;comment:仓位策略参数,0时使用全局配置"`
+	ExitStrategy    string  `json:"exit_strategy" gorm:"column:exit_strategy;type:varchar(255);not null;default:'';comment:退出策略,为空时使用全局配置"`
+	ExitValue       float64 `json:"exit_value" gorm:"column:exit_value;type:decimal(20,8);not null;default:0;comment:退出策略参数,0时使用全局配置"`
+	IsPaper         int     `json:"is_paper" gorm:"column:is_paper;type:tinyint(1);not null;default:0;comment:是否模拟盘跟单"`
+	MaxTradeUsd     float64 `json:"max_trade_usd" gorm:"column:max_trade_usd;type:decimal(20,8);not null;default:0;comment:单笔最多买入美元,0不限制"`
+	MaxDailyUsd     float64 `json:"max_daily_usd" gorm:"column:max_daily_usd;type:decimal(20,8);not null;default:0;comment:每天最多买入美元,0不限制"`
+	Slippage        float64 `json:"slippage" gorm:"column:slippage;type:decimal(10,4);not null;default:0;comment:兑换滑点百分比,0时使用默认值"`
+	TokenWhitelist  string  `json:"token_whitelist" gorm:"column:token_whitelist;type:text;comment:只跟这些代币,逗号分隔,为空不限制"`
+	TokenBlacklist  string  `json:"token_blacklist" gorm:"column:token_blacklist;type:text;comment:不跟这些代币,逗号分隔"`
+	MinFollowBuyUsd float64 `json:"min_follow_buy_usd" gorm:"column:min_follow_buy_usd;type:decimal(20,8);not null;default:0;comment:关注地址买入不低于多少美元才跟单"`
+	MaxPriceMove    float64 `json:"max_price_move" gorm:"column:max_price_move;type:decimal(10,4);not null;default:0;comment:跟单价格相对关注地址买入价的最大涨幅,0不限制"`
+	CopyDelay       int64   `json:"copy_delay" gorm:"column:copy_delay;type:bigint(20);not null;default:0;comment:关注地址交易后延迟多少秒跟单"`
+	WalletGroup     string  `json:"wallet_group" gorm:"column:wallet_group;type:varchar(255);not null;default:'';comment:使用的钱包分组,为空时使用所有钱包"`
 }
 
 func (f *FollowAddress) TableName() string {
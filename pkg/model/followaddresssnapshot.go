@@ -0,0 +1,30 @@
+package model
+
+import "gorm.io/gorm"
+
+type FollowAddressSnapshot struct {
+	gorm.Model
+	FollowAddressID uint    `json:"follow_address_id" gorm:"column:follow_address_id;type:int(11);not null;default:0;comment:关注地址id"`
+	ChainName       string  `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	Address         string  `json:"address" gorm:"column:address;type:varchar(255);not null;default:'';comment:地址"`
+	WindowDays      int     `json:"window_days" gorm:"column:window_days;type:int(11);not null;default:0;comment:统计窗口天数"`
+	TradeCount      int     `json:"trade_count" gorm:"column:trade_count;type:int(11);not null;default:0;comment:交易数"`
+	WinTotal        int     `json:"win_total" gorm:"column:win_total;type:int(11);not null;default:0;comment:盈利交易数"`
+	WinRate         float64 `json:"win_rate" gorm:"column:win_rate;type:decimal(20,8);not null;default:0;comment:胜率"`
+	WinRateLower    float64 `json:"win_rate_lower" gorm:"column:win_rate_lower;type:decimal(20,8);not null;default:0;comment:胜率置信下限"`
+	ProfitTotalUsd  float64 `json:"profit_total_usd" gorm:"column:profit_total_usd;type:decimal(20,8);not null;default:0;comment:总利润"`
+	MeanReturn      float64 `json:"mean_return" gorm:"column:mean_return;type:decimal(20,8);not null;default:0;comment:平均收益率"`
+	SnapshotTime    int64   `json:"snapshot_time" gorm:"column:snapshot_time;type:bigint(20);not null;default:0;comment:快照时间"`
+}
+
+func (f *FollowAddressSnapshot) TableName() string {
+	return "follow_address_snapshot"
+}
+
+func CreateFollowAddressSnapshot(f *FollowAddressSnapshot) error {
+	return db.Create(f).Error
+}
+
+func init() {
+	registerTable(&FollowAddressSnapshot{})
+}
@@ -2,12 +2,30 @@ package model
 
 import "gorm.io/gorm"
 
+// 跟单的状态依次为 detected -> quoted -> approving -> submitted -> confirmed/failed -> exiting -> closed，
+// 每次状态变化都先保存再执行下一步，崩溃后启动时根据状态恢复
 const (
+	// FollowTradeStatusSuccess confirmed，买入成功，持仓中
 	FollowTradeStatusSuccess = 1
-	FollowTradeStatusFail    = 2
-	FollowTradeStatusFinish  = 3
+	// FollowTradeStatusFail failed，买入失败
+	FollowTradeStatusFail = 2
+	// FollowTradeStatusFinish closed，持仓已全部卖出或价值过低
+	FollowTradeStatusFinish = 3
+	// FollowTradeStatusDetected 收到关注地址的买入信号
+	FollowTradeStatusDetected = 4
+	// FollowTradeStatusQuoted 已分配钱包并报价
+	FollowTradeStatusQuoted = 5
+	// FollowTradeStatusApproving 正在授权
+	FollowTradeStatusApproving = 6
+	// FollowTradeStatusSubmitted 正在发送或等待兑换交易确认
+	FollowTradeStatusSubmitted = 7
+	// FollowTradeStatusExiting 正在卖出，卖出结束后回到 confirmed 或 closed
+	FollowTradeStatusExiting = 8
 )
 
+// FollowTradeOpenStatuses 已经发出买入交易且没有结束的状态，用于计算敞口
+var FollowTradeOpenStatuses = []int{FollowTradeStatusSubmitted, FollowTradeStatusSuccess, FollowTradeStatusExiting}
+
 type FollowTrade struct {
 	gorm.Model
 	ChainName               string  `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
@@ -28,12 +46,48 @@ type FollowTrade struct {
 	SellPrincipalTxHash     string  `json:"sell_principal_tx_hash" gorm:"column:sell_principal_tx_hash;type:varchar(255);not null;default:'';comment:卖出本金交易哈希"`
 	Status                  int     `json:"status" gorm:"column:status;type:tinyint(1);not null;default:0;comment:状态"`
 	FailReason              string  `json:"fail_reason" gorm:"column:fail_reason;type:text;comment:失败原因"`
+	ConsensusSignalID       uint    `json:"consensus_signal_id" gorm:"column:consensus_signal_id;type:int(11);not null;default:0;comment:触发的共识信号id"`
+	ExitedAmount            float64 `json:"exited_amount" gorm:"column:exited_amount;type:decimal(20,8);not null;default:0;comment:已卖出数量"`
+	SizingStrategy          string  `json:"sizing_strategy" gorm:"column:sizing_strategy;type:varchar(255);not null;default:'';comment:仓位策略"`
+	SizeUsd                 float64 `json:"size_usd" gorm:"column:size_usd;type:decimal(20,8);not null;default:0;comment:买入金额(美元)"`
+	HighPrice               float64 `json:"high_price" gorm:"column:high_price;type:decimal(30,18);not null;default:0;comment:买入后的最高价(主流币计价)"`
+	TakeProfitLevel         int     `json:"take_profit_level" gorm:"column:take_profit_level;type:int(11);not null;default:0;comment:已执行的止盈档位数"`
+	IsPaper                 int     `json:"is_paper" gorm:"column:is_paper;type:tinyint(1);not null;default:0;comment:是否模拟盘"`
+	Proceeds                float64 `json:"proceeds" gorm:"column:proceeds;type:decimal(20,8);not null;default:0;comment:卖出收到的主流币数量(扣除卖出gas)"`
+	RealizedPnl             float64 `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8);not null;default:0;comment:结束时的已实现盈亏(主流币计价)"`
+	DustAmount              float64 `json:"dust_amount" gorm:"column:dust_amount;type:decimal(30,18);not null;default:0;comment:价值太低没有卖出就结束的剩余持仓数量"`
+	DustValue               float64 `json:"dust_value" gorm:"column:dust_value;type:decimal(30,18);not null;default:0;comment:结束时剩余持仓的估值(主流币计价),不计入已实现盈亏"`
+	ClosedAt                int64   `json:"closed_at" gorm:"column:closed_at;type:bigint(20);not null;default:0;comment:结束时间"`
+	// 执行质量，时间都是毫秒时间戳
+	FollowTxBlockTimeMs int64   `json:"follow_tx_block_time_ms" gorm:"column:follow_tx_block_time_ms;type:bigint(20);not null;default:0;comment:关注地址交易的区块时间"`
+	SignalTimeMs        int64   `json:"signal_time_ms" gorm:"column:signal_time_ms;type:bigint(20);not null;default:0;comment:收到跟单信号的时间"`
+	SubmitTimeMs        int64   `json:"submit_time_ms" gorm:"column:submit_time_ms;type:bigint(20);not null;default:0;comment:发出兑换交易的时间"`
+	ConfirmTimeMs       int64   `json:"confirm_time_ms" gorm:"column:confirm_time_ms;type:bigint(20);not null;default:0;comment:兑换交易确认的时间"`
+	QuotedAmount        float64 `json:"quoted_amount" gorm:"column:quoted_amount;type:decimal(30,18);not null;default:0;comment:报价的买入数量"`
+	EntryPriceUsd       float64 `json:"entry_price_usd" gorm:"column:entry_price_usd;type:decimal(30,18);not null;default:0;comment:实际买入价格(美元)"`
+	FollowEntryPriceUsd float64 `json:"follow_entry_price_usd" gorm:"column:follow_entry_price_usd;type:decimal(30,18);not null;default:0;comment:关注地址买入价格(美元),未知时为0"`
+	SwapProvider        string  `json:"swap_provider" gorm:"column:swap_provider;type:varchar(255);not null;default:'';comment:买入使用的兑换渠道"`
+	// 正在卖出的信息，exiting 时保存，崩溃后由 ReconcileFollowTradeJob 按卖出交易恢复
+	ExitingAmount        float64 `json:"exiting_amount" gorm:"column:exiting_amount;type:decimal(30,18);not null;default:0;comment:正在卖出的数量"`
+	ExitingReceiveAmount float64 `json:"exiting_receive_amount" gorm:"column:exiting_receive_amount;type:decimal(30,18);not null;default:0;comment:正在卖出的报价收到的主流币数量"`
+	ExitingReason        string  `json:"exiting_reason" gorm:"column:exiting_reason;type:varchar(255);not null;default:'';comment:正在卖出的原因"`
+	ExitTxHash           string  `json:"exit_tx_hash" gorm:"column:exit_tx_hash;type:varchar(255);not null;default:'';comment:正在卖出的交易哈希"`
 }
 
 func (f *FollowTrade) TableName() string {
 	return "follow_trade"
 }
 
+// Cost 买入花费的主流币，包括买入 gas
+func (f *FollowTrade) Cost() float64 {
+	return f.WalletAddressSellAmount + f.WalletAddressBuyGas
+}
+
+// IsLoss 已结束且没有出本的跟单视为亏损
+func (f *FollowTrade) IsLoss() bool {
+	return f.Status == FollowTradeStatusFinish && f.IsSellPrincipal == 0
+}
+
 func CreateFollowTrade(followTrade *FollowTrade) error {
 	return db.Create(followTrade).Error
 }
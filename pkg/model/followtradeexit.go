@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	FollowTradeExitStatusSuccess = 1
+	FollowTradeExitStatusFail    = 2
+)
+
+// FollowTradeExit 跟单的一次(部分)卖出
+type FollowTradeExit struct {
+	gorm.Model
+	FollowTradeID       uint    `json:"follow_trade_id" gorm:"column:follow_trade_id;type:int(11);not null;default:0;comment:跟单id"`
+	ChainName           string  `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	WalletAddress       string  `json:"wallet_address" gorm:"column:wallet_address;type:varchar(255);not null;default:'';comment:钱包地址"`
+	TokenAddress        string  `json:"token_address" gorm:"column:token_address;type:varchar(255);not null;default:'';comment:卖出币种地址"`
+	Reason              string  `json:"reason" gorm:"column:reason;type:varchar(255);not null;default:'';comment:卖出原因"`
+	FollowAddressTxHash string  `json:"follow_address_tx_hash" gorm:"column:follow_address_tx_hash;type:varchar(255);not null;default:'';comment:关注地址卖出交易哈希"`
+	Fraction            float64 `json:"fraction" gorm:"column:fraction;type:decimal(20,8);not null;default:0;comment:卖出持仓比例"`
+	SellAmount          float64 `json:"sell_amount" gorm:"column:sell_amount;type:decimal(20,8);not null;default:0;comment:卖出数量"`
+	ReceiveAmount       float64 `json:"receive_amount" gorm:"column:receive_amount;type:decimal(20,8);not null;default:0;comment:预计收到的主流币数量"`
+	TxHash              string  `json:"tx_hash" gorm:"column:tx_hash;type:varchar(255);not null;default:'';comment:卖出交易哈希"`
+	Gas                 float64 `json:"gas" gorm:"column:gas;type:decimal(20,8);not null;default:0;comment:手续费"`
+	Status              int     `json:"status" gorm:"column:status;type:tinyint(1);not null;default:0;comment:状态"`
+	FailReason          string  `json:"fail_reason" gorm:"column:fail_reason;type:text;comment:失败原因"`
+	ExitTime            int64   `json:"exit_time" gorm:"column:exit_time;type:bigint(20);not null;default:0;comment:卖出时间"`
+	SwapProvider        string  `json:"swap_provider" gorm:"column:swap_provider;type:varchar(255);not null;default:'';comment:卖出使用的兑换渠道"`
+}
+
+func (f *FollowTradeExit) TableName() string {
+	return "follow_trade_exit"
+}
+
+func CreateFollowTradeExit(f *FollowTradeExit) error {
+	return db.Create(f).Error
+}
+
+func init() {
+	registerTable(&FollowTradeExit{})
+}
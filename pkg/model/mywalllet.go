@@ -15,6 +15,7 @@ type MyWallet struct {
 	PrivateKey     string  `json:"private_key" gorm:"column:private_key;type:varchar(255);not null;default:'';comment:私钥"`
 	EachSellAmount float64 `json:"each_sell_amount" gorm:"column:each_sell_amount;type:decimal(10,5);not null;default:0.00;comment:每次卖出数量"`
 	Status         int     `json:"status" gorm:"column:status;type:int(11);not null;default:0;comment:状态"`
+	GroupName      string  `json:"group_name" gorm:"column:group_name;type:varchar(255);not null;default:'';comment:钱包分组"`
 }
 
 func (m *MyWallet) TableName() string {
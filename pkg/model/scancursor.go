@@ -0,0 +1,23 @@
+package model
+
+import "gorm.io/gorm"
+
+// ScanCursor 区块扫描进度，每条链一条
+type ScanCursor struct {
+	gorm.Model
+	ChainName   string `json:"chain_name" gorm:"column:chain_name;type:varchar(255);not null;default:'';comment:链名称"`
+	BlockHeight int64  `json:"block_height" gorm:"column:block_height;type:bigint(20);not null;default:0;comment:已扫描到的区块高度"`
+	BlockHash   string `json:"block_hash" gorm:"column:block_hash;type:varchar(255);not null;default:'';comment:已扫描到的区块哈希"`
+}
+
+func (s *ScanCursor) TableName() string {
+	return "scan_cursor"
+}
+
+func SaveScanCursor(s *ScanCursor) error {
+	return db.Save(s).Error
+}
+
+func init() {
+	registerTable(&ScanCursor{})
+}
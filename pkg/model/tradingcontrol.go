@@ -0,0 +1,38 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// TradingControl 全局交易开关，只有一条记录
+type TradingControl struct {
+	gorm.Model
+	Halted    int    `json:"halted" gorm:"column:halted;type:tinyint(1);not null;default:0;comment:是否停止买入"`
+	Source    string `json:"source" gorm:"column:source;type:varchar(255);not null;default:'';comment:停止来源,manual或熔断器名称"`
+	Reason    string `json:"reason" gorm:"column:reason;type:text;comment:停止原因"`
+	Liquidate int    `json:"liquidate" gorm:"column:liquidate;type:tinyint(1);not null;default:0;comment:是否等待清仓"`
+	HaltedAt  int64  `json:"halted_at" gorm:"column:halted_at;type:bigint(20);not null;default:0;comment:停止时间"`
+}
+
+func (t *TradingControl) TableName() string {
+	return "trading_control"
+}
+
+// GetTradingControl 没有记录时返回未停止的状态
+func GetTradingControl() (*TradingControl, error) {
+	t := new(TradingControl)
+	if err := db.Order("id").First(t).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return t, nil
+}
+
+func SaveTradingControl(t *TradingControl) error {
+	return db.Save(t).Error
+}
+
+func init() {
+	registerTable(&TradingControl{})
+}
@@ -9,7 +9,17 @@ import (
 	"github.com/imroc/req/v3"
 )
 
-var Api *API
+var (
+	Api *API
+
+	// resultHook 每次请求(包括重试)结束后调用，用于统计错误率
+	resultHook func(err error)
+)
+
+// SetResultHook 需要在发起请求之前设置
+func SetResultHook(fn func(err error)) {
+	resultHook = fn
+}
 
 type API struct {
 	c      *req.Client
@@ -24,6 +34,16 @@ func InitAPI(apiKey, host string) {
 			SetCommonRetryBackoffInterval(5*time.Second, time.Minute).
 			SetCommonRetryCondition(func(resp *req.Response, err error) bool {
 				return resp.GetStatusCode() != http.StatusOK
+			}).
+			OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+				if hook := resultHook; hook != nil {
+					err := resp.Err
+					if err == nil && resp.GetStatusCode() != http.StatusOK {
+						err = fmt.Errorf("oklink status code %d", resp.GetStatusCode())
+					}
+					hook(err)
+				}
+				return nil
 			}),
 		apiKey: apiKey,
 		host:   host,
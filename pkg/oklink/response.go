@@ -10,16 +10,17 @@ type TransactionListResp struct {
 		ChainFullName    string `json:"chainFullName"`
 		ChainShortName   string `json:"chainShortName"`
 		TransactionLists []struct {
-			TxId              string `json:"txId"`
-			BlockHash         string `json:"blockHash"`
-			Height            string `json:"height"`
-			TransactionTime   string `json:"transactionTime"`
-			From              string `json:"from"`
-			To                string `json:"to"`
-			Amount            string `json:"amount"`
-			TransactionSymbol string `json:"transactionSymbol"`
-			TxFee             string `json:"txFee"`
-			State             string `json:"state"`
+			TxId                 string `json:"txId"`
+			BlockHash            string `json:"blockHash"`
+			Height               string `json:"height"`
+			TransactionTime      string `json:"transactionTime"`
+			From                 string `json:"from"`
+			To                   string `json:"to"`
+			Amount               string `json:"amount"`
+			TransactionSymbol    string `json:"transactionSymbol"`
+			TxFee                string `json:"txFee"`
+			State                string `json:"state"`
+			TokenContractAddress string `json:"tokenContractAddress"`
 		} `json:"transactionLists"`
 	} `json:"data"`
 }
@@ -0,0 +1,114 @@
+package paraswap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+const DefaultHost = "https://apiv5.paraswap.io"
+
+type API struct {
+	c    *req.Client
+	host string
+}
+
+func NewAPI(host string) *API {
+	return &API{
+		c: req.C().
+			SetCommonRetryCount(3).
+			SetCommonRetryBackoffInterval(3*time.Second, time.Minute).
+			SetCommonRetryCondition(func(resp *req.Response, err error) bool {
+				return resp.GetStatusCode() != http.StatusOK
+			}),
+		host: host,
+	}
+}
+
+type PriceRequest struct {
+	Network      int64
+	SrcToken     string
+	SrcDecimals  int
+	DestToken    string
+	DestDecimals int
+	Amount       string
+	UserAddress  string
+}
+
+// Prices 按卖出数量报价，返回的路由用于构造交易
+func (a *API) Prices(r *PriceRequest) (*PriceRoute, error) {
+	url := fmt.Sprintf("%s/prices", a.host)
+	resp := a.c.Get(url).SetQueryParamsAnyType(map[string]interface{}{
+		"srcToken":     r.SrcToken,
+		"srcDecimals":  r.SrcDecimals,
+		"destToken":    r.DestToken,
+		"destDecimals": r.DestDecimals,
+		"amount":       r.Amount,
+		"side":         "SELL",
+		"network":      r.Network,
+		"userAddress":  r.UserAddress,
+	}).Do()
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.IsErrorState() {
+		return nil, fmt.Errorf("get url failed, status code:%d", resp.GetStatusCode())
+	}
+
+	response := new(PricesResp)
+	if err := resp.UnmarshalJson(&response); err != nil {
+		return nil, err
+	}
+	route := new(PriceRoute)
+	if err := json.Unmarshal(response.PriceRoute, route); err != nil {
+		return nil, err
+	}
+	// 构造交易时需要原样传回报价路由
+	route.Raw = response.PriceRoute
+	return route, nil
+}
+
+// TransactionRequest slippage 单位为 bps，如 100 表示 1%
+type TransactionRequest struct {
+	Network      int64
+	SrcToken     string
+	SrcDecimals  int
+	DestToken    string
+	DestDecimals int
+	SrcAmount    string
+	Slippage     int
+	UserAddress  string
+	PriceRoute   *PriceRoute
+}
+
+// Transactions 按报价路由构造交易，不检查余额和授权
+func (a *API) Transactions(r *TransactionRequest) (*TransactionResp, error) {
+	url := fmt.Sprintf("%s/transactions/%d", a.host, r.Network)
+	resp := a.c.Post(url).SetQueryParam("ignoreChecks", "true").SetBodyJsonMarshal(map[string]interface{}{
+		"srcToken":     r.SrcToken,
+		"srcDecimals":  r.SrcDecimals,
+		"destToken":    r.DestToken,
+		"destDecimals": r.DestDecimals,
+		"srcAmount":    r.SrcAmount,
+		"slippage":     r.Slippage,
+		"userAddress":  r.UserAddress,
+		"priceRoute":   r.PriceRoute.Raw,
+	}).Do()
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.IsErrorState() {
+		return nil, fmt.Errorf("post url failed, status code:%d", resp.GetStatusCode())
+	}
+
+	response := new(TransactionResp)
+	if err := resp.UnmarshalJson(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
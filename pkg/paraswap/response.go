@@ -0,0 +1,29 @@
+package paraswap
+
+import "encoding/json"
+
+type PricesResp struct {
+	PriceRoute json.RawMessage `json:"priceRoute"`
+}
+
+type PriceRoute struct {
+	SrcToken           string `json:"srcToken"`
+	SrcAmount          string `json:"srcAmount"`
+	DestToken          string `json:"destToken"`
+	DestAmount         string `json:"destAmount"`
+	GasCost            string `json:"gasCost"`
+	ContractAddress    string `json:"contractAddress"`
+	TokenTransferProxy string `json:"tokenTransferProxy"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+type TransactionResp struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Data     string `json:"data"`
+	GasPrice string `json:"gasPrice"`
+	Gas      string `json:"gas"`
+	ChainID  int64  `json:"chainId"`
+}
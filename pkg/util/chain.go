@@ -51,6 +51,18 @@ var MainTokenInfo = map[string]*MainToken{
 	},
 }
 
+// NativeTokenSymbol 原生币符号
+var NativeTokenSymbol = map[string]string{
+	"eth": "ETH",
+	"bsc": "BNB",
+}
+
+// WrappedNativeToken 原生币的包装代币，用于查询池子流动性
+var WrappedNativeToken = map[string]string{
+	"eth": "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+	"bsc": "0xbb4cdb9cbd36b01bd1cbaebf2de08d9173bc095c",
+}
+
 var ChainIDMap = map[string]int64{
 	"eth": 1,
 	"bsc": 56,
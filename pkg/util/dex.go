@@ -0,0 +1,59 @@
+package util
+
+import "strings"
+
+// DexRouters 常见 dex 路由合约，key 为小写地址
+var DexRouters = map[string]map[string]string{
+	"eth": {
+		"0x7a250d5630b4cf539739df2c5dacb4c659f2488d": "uniswap_v2",
+		"0xe592427a0aece92de3edee1f18e0157c05861564": "uniswap_v3",
+		"0x68b3465833fb72a70ecdf485e0e4c7bd8665fc45": "uniswap_v3",
+		"0xef1c6e67703c7bd7107eed8303fbe6ec2554bf6b": "uniswap_universal",
+		"0x3fc91a3afd70395cd496c647d5a6cc9d4b2b7fad": "uniswap_universal",
+		"0xd9e1ce17f2641f24ae83637ab66a2cca9c378b9f": "sushiswap",
+		"0x1111111254eeb25477b68fb85ed929f73a960582": "1inch",
+		"0x1111111254fb6c44bac0bed2854e76f90643097d": "1inch",
+		"0xdef1c0ded9bec7f1a1670819833240f027b25eff": "0x",
+	},
+	"bsc": {
+		"0x10ed43c718714eb63d5aa57b78b54704e256024e": "pancakeswap_v2",
+		"0x13f4ea83d0bd40e75c8222255bc855a974568dd4": "pancakeswap_v3",
+		"0x1b81d678ffb9c0263b24a97847620c99d213eb14": "pancakeswap_v3",
+		"0x1111111254eeb25477b68fb85ed929f73a960582": "1inch",
+		"0x1111111254fb6c44bac0bed2854e76f90643097d": "1inch",
+		"0xdef1c0ded9bec7f1a1670819833240f027b25eff": "0x",
+	},
+}
+
+// V2Routers uniswap v2 类 dex 的路由合约，用于模拟交易
+var V2Routers = map[string]string{
+	"eth": "0x7a250d5630b4cf539739df2c5dacb4c659f2488d",
+	"bsc": "0x10ed43c718714eb63d5aa57b78b54704e256024e",
+}
+
+// V2Factories uniswap v2 类 dex 的 factory 合约
+var V2Factories = map[string]string{
+	"eth": "0x5c69bee701ef814a2b6a3edd4b1652cb9cc5aa6f",
+	"bsc": "0xca143ce32fe78f1f7019d7d551a6402fc5350c73",
+}
+
+// V3Routers uniswap v3 SwapRouter 合约，bsc 上的 pancakeswap v3 路由接口不同，需要配置兼容的路由
+var V3Routers = map[string]string{
+	"eth": "0xe592427a0aece92de3edee1f18e0157c05861564",
+}
+
+// V3Quoters uniswap v3 Quoter 合约
+var V3Quoters = map[string]string{
+	"eth": "0xb27308f9f90d607463bb33ea1bebb41c27ce5ab6",
+}
+
+// DexName returns the dex name of a router address, unknown routers return the address itself.
+func DexName(chainName, routerAddress string) string {
+	if routerAddress == "" {
+		return "unknown"
+	}
+	if name, ok := DexRouters[chainName][strings.ToLower(routerAddress)]; ok {
+		return name
+	}
+	return strings.ToLower(routerAddress)
+}
@@ -0,0 +1,12 @@
+package util
+
+import "time"
+
+// TxTime converts an oklink transaction time, which may be in seconds or milliseconds, to time.Time.
+func TxTime(ts uint64) time.Time {
+	// 大于 1e12 的认为是毫秒
+	if ts > 1e12 {
+		return time.UnixMilli(int64(ts))
+	}
+	return time.Unix(int64(ts), 0)
+}
@@ -0,0 +1,86 @@
+package zerox
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// DefaultHosts 0x 每条链使用不同的域名
+var DefaultHosts = map[string]string{
+	"eth": "https://api.0x.org",
+	"bsc": "https://bsc.api.0x.org",
+}
+
+type API struct {
+	c      *req.Client
+	apiKey string
+	host   string
+}
+
+func NewAPI(apiKey, host string) *API {
+	return &API{
+		c: req.C().
+			SetCommonRetryCount(3).
+			SetCommonRetryBackoffInterval(3*time.Second, time.Minute).
+			SetCommonRetryCondition(func(resp *req.Response, err error) bool {
+				return resp.GetStatusCode() != http.StatusOK
+			}),
+		apiKey: apiKey,
+		host:   host,
+	}
+}
+
+// SwapRequest slippagePercentage 为小数，如 0.01 表示 1%
+type SwapRequest struct {
+	SellToken          string
+	BuyToken           string
+	SellAmount         string
+	TakerAddress       string
+	SlippagePercentage float64
+}
+
+func (r *SwapRequest) params() map[string]interface{} {
+	params := map[string]interface{}{
+		"sellToken":  r.SellToken,
+		"buyToken":   r.BuyToken,
+		"sellAmount": r.SellAmount,
+	}
+	if r.TakerAddress != "" {
+		params["takerAddress"] = r.TakerAddress
+	}
+	if r.SlippagePercentage > 0 {
+		params["slippagePercentage"] = r.SlippagePercentage
+	}
+	return params
+}
+
+// Price 只报价，不返回交易数据
+func (a *API) Price(r *SwapRequest) (*QuoteResp, error) {
+	return a.get("/swap/v1/price", r)
+}
+
+// Quote 报价并返回可以直接发送的交易
+func (a *API) Quote(r *SwapRequest) (*QuoteResp, error) {
+	return a.get("/swap/v1/quote", r)
+}
+
+func (a *API) get(path string, r *SwapRequest) (*QuoteResp, error) {
+	url := fmt.Sprintf("%s%s", a.host, path)
+	resp := a.c.Get(url).SetHeader("0x-api-key", a.apiKey).SetQueryParamsAnyType(r.params()).Do()
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.IsErrorState() {
+		return nil, fmt.Errorf("get url failed, status code:%d", resp.GetStatusCode())
+	}
+
+	response := new(QuoteResp)
+	if err := resp.UnmarshalJson(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,21 @@
+package zerox
+
+type QuoteResp struct {
+	ChainID          int    `json:"chainId"`
+	Price            string `json:"price"`
+	To               string `json:"to"`
+	Data             string `json:"data"`
+	Value            string `json:"value"`
+	Gas              string `json:"gas"`
+	EstimatedGas     string `json:"estimatedGas"`
+	GasPrice         string `json:"gasPrice"`
+	BuyTokenAddress  string `json:"buyTokenAddress"`
+	SellTokenAddress string `json:"sellTokenAddress"`
+	BuyAmount        string `json:"buyAmount"`
+	SellAmount       string `json:"sellAmount"`
+	AllowanceTarget  string `json:"allowanceTarget"`
+	Sources          []struct {
+		Name       string `json:"name"`
+		Proportion string `json:"proportion"`
+	} `json:"sources"`
+}